@@ -175,6 +175,44 @@ func (dRoot *Directory) RemoveTimeBucket(tbk *io.TimeBucketKey) (err error) {
 	return nil
 }
 
+// ReplaceTimeBucket replaces the year files of the bucket dst with those of
+// the bucket src, and removes src.  Both buckets must exist.  The directory of
+// src is renamed over that of dst while the catalog entry of dst is locked, so
+// that its lookups find either all the year files it had or all those of src.
+func (dRoot *Directory) ReplaceTimeBucket(src, dst *io.TimeBucketKey) (err error) {
+	root := dRoot.GetPath()
+	srcPath, dstPath := src.GetPathToYearFiles(root), dst.GetPathToYearFiles(root)
+	if srcPath == dstPath {
+		return fmt.Errorf("cannot replace %s with itself", dst.String())
+	}
+	if _, err = dRoot.GetOwningSubDirectory(filepath.Join(srcPath, "1970.bin")); err != nil {
+		return err
+	}
+	dstDir, err := dRoot.GetOwningSubDirectory(filepath.Join(dstPath, "1970.bin"))
+	if err != nil {
+		return err
+	}
+
+	replaced := dstPath + ".replaced"
+	dstDir.Lock()
+	if err = os.Rename(dstPath, replaced); err != nil {
+		dstDir.Unlock()
+		return fmt.Errorf(io.GetCallerFileContext(0) + err.Error())
+	}
+	if err = os.Rename(srcPath, dstPath); err != nil {
+		os.Rename(replaced, dstPath)
+		dstDir.Unlock()
+		return fmt.Errorf(io.GetCallerFileContext(0) + err.Error())
+	}
+	// the year files dst now holds are those of src
+	dstDir.datafile = NewDirectory(dstPath).datafile
+	dstDir.Unlock()
+
+	os.RemoveAll(replaced)
+	// the directory of src is gone, its empty parents are removed with it
+	return dRoot.RemoveTimeBucket(src)
+}
+
 func (d *Directory) GetTimeBucketInfoSlice() (tbinfolist []*io.TimeBucketInfo) {
 	// Returns a list of fileinfo for all datafiles in this directory or nil if there are none
 	d.RLock()
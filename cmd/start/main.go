@@ -74,8 +74,8 @@ func executeStart(cmd *cobra.Command, args []string) error {
 			case syscall.SIGUSR1:
 				Log(INFO, "dumping stack traces due to SIGUSR1 request")
 				pprof.Lookup("goroutine").WriteTo(os.Stdout, 1)
-			case syscall.SIGINT:
-				Log(INFO, "initiating graceful shutdown due to SIGINT request")
+			case syscall.SIGINT, syscall.SIGTERM:
+				Log(INFO, "initiating graceful shutdown due to %v request", s)
 				atomic.StoreUint32(&frontend.Queryable, uint32(0))
 				Log(INFO, "waiting a grace period of %v to shutdown...", utils.InstanceConfig.StopGracePeriod)
 				time.Sleep(utils.InstanceConfig.StopGracePeriod)
@@ -85,6 +85,7 @@ func executeStart(cmd *cobra.Command, args []string) error {
 	}()
 	signal.Notify(signalChan, syscall.SIGUSR1)
 	signal.Notify(signalChan, syscall.SIGINT)
+	signal.Notify(signalChan, syscall.SIGTERM)
 
 	// Initialize marketstore services.
 	// --------------------------------
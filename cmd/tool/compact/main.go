@@ -0,0 +1,56 @@
+package compact
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usage   = "compact"
+	short   = "Rewrite a bucket without superseded rows"
+	long    = "This command rewrites a bucket keeping only the last row written for each time. Stop marketstore before running it."
+	example = "marketstore tool compact --dir <path> --key BINANCE_BNB_EOS/1Min/OHLCV"
+
+	// Flag descriptions.
+	rootDirPathDesc = "set filesystem path of the marketstore root directory"
+	keyDesc         = "set the bucket to compact, e.g. BINANCE_BNB_EOS/1Min/OHLCV"
+)
+
+var (
+	// Available flags.
+	rootDirPath, key string
+
+	// Cmd is the compact command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeCompact,
+	}
+)
+
+func init() {
+	// Parse flags.
+	Cmd.Flags().StringVarP(&rootDirPath, "dir", "d", "", rootDirPathDesc)
+	Cmd.MarkFlagRequired("dir")
+	Cmd.Flags().StringVarP(&key, "key", "k", "", keyDesc)
+	Cmd.MarkFlagRequired("key")
+}
+
+func executeCompact(cmd *cobra.Command, args []string) error {
+	// Write straight to the bucket files, the WAL of the stopped server is
+	// left for it to replay.
+	executor.NewInstanceSetup(filepath.Clean(rootDirPath), true, true, false, true)
+
+	removed, err := executor.CompactBucket(io.NewTimeBucketKey(key))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d rows from %s\n", removed, key)
+	return nil
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usage   = "export"
+	short   = "Export a bucket for offline analysis"
+	long    = "This command exports the rows of a bucket in a time range to a file"
+	example = "marketstore tool export --dir <path> --key BINANCE_BNB_EOS/1Min/OHLCV --start 2018-08-01T00:00:00Z --output eos.csv"
+
+	// Flag descriptions.
+	rootDirPathDesc = "set filesystem path of the marketstore root directory"
+	keyDesc         = "set the bucket to export, e.g. BINANCE_BNB_EOS/1Min/OHLCV"
+	startDesc       = "export rows from this RFC3339 time (inclusive), defaults to the first row"
+	endDesc         = "export rows up to this RFC3339 time (inclusive), defaults to the last row"
+	formatDesc      = "set the output format, csv or parquet"
+	outputDesc      = "set the file to write, defaults to stdout"
+)
+
+var (
+	// Available flags.
+	rootDirPath, key, start, end, format, output string
+
+	// Cmd is the export command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeExport,
+	}
+)
+
+func init() {
+	// Parse flags.
+	Cmd.Flags().StringVarP(&rootDirPath, "dir", "d", "", rootDirPathDesc)
+	Cmd.MarkFlagRequired("dir")
+	Cmd.Flags().StringVarP(&key, "key", "k", "", keyDesc)
+	Cmd.MarkFlagRequired("key")
+	Cmd.Flags().StringVar(&start, "start", "", startDesc)
+	Cmd.Flags().StringVar(&end, "end", "", endDesc)
+	Cmd.Flags().StringVar(&format, "format", "csv", formatDesc)
+	Cmd.Flags().StringVarP(&output, "output", "o", "", outputDesc)
+}
+
+func executeExport(cmd *cobra.Command, args []string) error {
+	startTime, endTime := time.Unix(planner.MinEpoch, 0), time.Unix(planner.MaxEpoch, 0)
+	var err error
+	if start != "" {
+		if startTime, err = time.Parse(time.RFC3339, start); err != nil {
+			return fmt.Errorf("invalid start %q: %v", start, err)
+		}
+	}
+	if end != "" {
+		if endTime, err = time.Parse(time.RFC3339, end); err != nil {
+			return fmt.Errorf("invalid end %q: %v", end, err)
+		}
+	}
+
+	// Read the catalog only, leaving the WAL alone.
+	executor.NewInstanceSetup(filepath.Clean(rootDirPath), true, false, false, false)
+
+	var w goio.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return executor.ExportBucket(io.NewTimeBucketKey(key), startTime, endTime, format, w)
+}
@@ -1,7 +1,10 @@
 package tool
 
 import (
+	"github.com/alpacahq/marketstore/cmd/tool/compact"
+	"github.com/alpacahq/marketstore/cmd/tool/export"
 	"github.com/alpacahq/marketstore/cmd/tool/integrity"
+	"github.com/alpacahq/marketstore/cmd/tool/replay"
 	"github.com/alpacahq/marketstore/cmd/tool/wal"
 	"github.com/spf13/cobra"
 )
@@ -19,12 +22,15 @@ var (
 		Use:        usage,
 		Short:      short,
 		Long:       long,
-		SuggestFor: []string{"wal", "integrity"},
+		SuggestFor: []string{"wal", "integrity", "export", "compact", "replay"},
 		Example:    example,
 	}
 )
 
 func init() {
+	Cmd.AddCommand(compact.Cmd)
+	Cmd.AddCommand(export.Cmd)
 	Cmd.AddCommand(integrity.Cmd)
+	Cmd.AddCommand(replay.Cmd)
 	Cmd.AddCommand(wal.Cmd)
 }
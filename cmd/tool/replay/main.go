@@ -0,0 +1,81 @@
+package replay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/alpacahq/marketstore/cmd/start"
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usage   = "replay"
+	short   = "Fire the triggers of a bucket over its stored rows"
+	long    = "This command passes the rows of a bucket in a time range to the triggers configured on it, e.g. to build the aggregates of a trigger added after the data. Stop marketstore before running it."
+	example = "marketstore tool replay --config mkts.yml --key BINANCE_BNB_EOS/1Min/OHLCV --start 2018-08-01T00:00:00Z --end 2018-09-01T00:00:00Z"
+
+	// Flag descriptions.
+	configDesc = "set the path for the marketstore YAML configuration file with the triggers"
+	keyDesc    = "set the bucket to replay, e.g. BINANCE_BNB_EOS/1Min/OHLCV"
+	startDesc  = "replay rows from this RFC3339 time (inclusive)"
+	endDesc    = "replay rows up to this RFC3339 time (inclusive)"
+)
+
+var (
+	// Available flags.
+	configFilePath, key, from, to string
+
+	// Cmd is the replay command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeReplay,
+	}
+)
+
+func init() {
+	// Parse flags.
+	Cmd.Flags().StringVarP(&configFilePath, "config", "c", "./mkts.yml", configDesc)
+	Cmd.Flags().StringVarP(&key, "key", "k", "", keyDesc)
+	Cmd.MarkFlagRequired("key")
+	Cmd.Flags().StringVar(&from, "start", "", startDesc)
+	Cmd.MarkFlagRequired("start")
+	Cmd.Flags().StringVar(&to, "end", "", endDesc)
+	Cmd.MarkFlagRequired("end")
+}
+
+func executeReplay(cmd *cobra.Command, args []string) error {
+	startTime, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		return fmt.Errorf("invalid start %q: %v", from, err)
+	}
+	endTime, err := time.Parse(time.RFC3339, to)
+	if err != nil {
+		return fmt.Errorf("invalid end %q: %v", to, err)
+	}
+	data, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file error: %s", err.Error())
+	}
+	if err := utils.InstanceConfig.Parse(data); err != nil {
+		return fmt.Errorf("failed to parse configuration file error: %v", err.Error())
+	}
+
+	// The triggers write straight to the bucket files, the WAL of the
+	// stopped server is left for it to replay.
+	executor.NewInstanceSetup(utils.InstanceConfig.RootDirectory, true, true, false, true)
+	start.InitializeTriggers()
+
+	replayed, err := executor.ReplayWrites(io.NewTimeBucketKey(key), startTime, endTime)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Replayed %d rows of %s\n", replayed, key)
+	return nil
+}
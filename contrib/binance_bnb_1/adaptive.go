@@ -0,0 +1,70 @@
+package main
+
+import (
+	"time"
+
+	binance "github.com/adshao/go-binance"
+)
+
+// maxSpanFactor caps the adaptive span of a symbol, in times request_span
+const maxSpanFactor = 20
+
+// adaptiveWindow returns the backfill request of symbol from start to end
+// with adaptive_span: start moves past the candles its previous request
+// fetched, and end extends to its learned span, but not beyond query_end nor
+// the last closed candle.  Without adaptive_span the window is left as it is.
+func (bn *BinanceFetcher) adaptiveWindow(symbol string, start, end time.Time) (time.Time, time.Time) {
+	if !bn.adaptiveSpan {
+		return start, end
+	}
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	if through, ok := bn.fetchedThrough[symbol]; ok && start.Before(through) {
+		start = through
+	}
+	if span, ok := bn.spans[symbol]; ok {
+		tf := bn.timeframeOf(symbol)
+		e := start.Add(time.Duration(span) * bn.passTimeframe().Duration)
+		if closed := candleOpen(bn.clock.Now().UTC(), tf).Add(-tf.Duration); e.After(closed) {
+			e = closed
+		}
+		if e.After(end) {
+			end = e
+		}
+	}
+	if !bn.queryEnd.IsZero() && end.After(bn.queryEnd) {
+		end = bn.queryEnd
+	}
+	return start, end
+}
+
+// learnSpan adapts the span of symbol to the density of the rates written of
+// its request from start to end, so that its next requests return about as
+// many candles as request_span: sparse symbols take bigger jumps, up to
+// maxSpanFactor times request_span, and dense ones keep request_span.  A
+// response of the API limit may be truncated, so the next request starts
+// from its last candle with request_span again.
+func (bn *BinanceFetcher) learnSpan(symbol string, start, end time.Time, rates []*binance.Kline) {
+	if !bn.adaptiveSpan {
+		return
+	}
+	tf := bn.passTimeframe().Duration
+	target := int(bn.requestSpan / tf)
+	through, span := end, target*maxSpanFactor
+	if n := len(rates); n >= defaultKlinesLimit {
+		through = time.Unix(0, rates[n-1].OpenTime*int64(time.Millisecond)).UTC()
+		span = target
+	} else if n > 0 {
+		span = int(float64(end.Sub(start)) / float64(tf) * float64(target) / float64(n))
+	}
+	if span < target {
+		span = target
+	}
+	if span > target*maxSpanFactor {
+		span = target * maxSpanFactor
+	}
+	bn.mu.Lock()
+	bn.fetchedThrough[symbol] = through
+	bn.spans[symbol] = span
+	bn.mu.Unlock()
+}
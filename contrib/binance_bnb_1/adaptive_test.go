@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestAdaptiveSpan(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	config := `{
+        "symbols": ["EOS", "TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 10:00",
+        "bucket_name_template": "%s_{base}",
+        "request_span": 100,
+        "advance_step": 100,
+        "adaptive_span": %v
+        }`
+	run := func(name string, adaptive bool) (*BinanceFetcher, int) {
+		// EOS trades every minute, TRX every 10 minutes
+		client := &requestRecorder{klinesClient: &fixtureClient{klines: map[string][]*binance.Kline{
+			"EOSBNB": syntheticKlines(start, 601, time.Minute),
+			"TRXBNB": syntheticKlines(start, 61, 10*time.Minute),
+		}}}
+		worker := s.newWorker(c, client, fmt.Sprintf(config, name, adaptive))
+		worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+		worker.Run()
+		c.Assert(readBucket(c, name+"_EOS/1Min/OHLCV").GetEpoch(), HasLen, 601)
+		c.Assert(readBucket(c, name+"_TRX/1Min/OHLCV").GetEpoch(), HasLen, 61)
+		return worker, len(client.ranges)
+	}
+
+	_, fixed := run("FIXEDSPAN", false)
+	c.Assert(fixed, Equals, 12)
+	// TRX is caught up in 2 requests, from 00:00 and from 01:40 to query_end
+	worker, adaptive := run("ADAPTIVE", true)
+	c.Assert(adaptive, Equals, 6+2)
+	state := worker.state(start)
+	c.Assert(state.Symbols["EOS"].Span, Equals, 100)
+	c.Assert(state.Symbols["TRX"].Span, Equals, 500*100/51)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/golang/glog"
+)
+
+// defaultAuditMaxBytes bounds the audit file unless audit_max_bytes is set
+const defaultAuditMaxBytes = 10 << 20
+
+// auditRecord is a line of the audit file, the kline as the API returned it
+type auditRecord struct {
+	Symbol string         `json:"symbol"`
+	Epoch  int64          `json:"epoch"`
+	Kline  *binance.Kline `json:"kline"`
+}
+
+// auditLog appends the klines whose High/Low ratio reaches spread to a file
+// of JSON lines, until it holds maxBytes
+type auditLog struct {
+	mu       sync.Mutex
+	path     string
+	spread   float64
+	maxBytes int64
+	size     int64
+	// seen holds the records written, as the same kline is returned by
+	// overlapping requests
+	seen map[string]bool
+	full bool
+}
+
+func newAuditLog(path string, spread float64, maxBytes int64) (*auditLog, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit_spread requires audit_file")
+	}
+	if spread <= 1 {
+		return nil, fmt.Errorf("invalid audit_spread %v, must be above 1", spread)
+	}
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid audit_max_bytes %d", maxBytes)
+	}
+	a := &auditLog{path: path, spread: spread, maxBytes: maxBytes, seen: map[string]bool{}}
+	if fi, err := os.Stat(path); err == nil {
+		a.size = fi.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return a, nil
+}
+
+// anomalous tells whether the kline spans spread or more from low to high
+func (a *auditLog) anomalous(k *binance.Kline) bool {
+	high, err := strconv.ParseFloat(k.High, 64)
+	if err != nil {
+		return false
+	}
+	low, err := strconv.ParseFloat(k.Low, 64)
+	if err != nil || low <= 0 {
+		return false
+	}
+	return high/low >= a.spread
+}
+
+// record appends the anomalous klines of symbol not recorded yet
+func (a *auditLog) record(symbol string, rates []*binance.Kline, epochSource string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var lines []byte
+	for _, k := range rates {
+		if !a.anomalous(k) {
+			continue
+		}
+		line, err := json.Marshal(auditRecord{Symbol: symbol, Epoch: klineEpoch(k, epochSource), Kline: k})
+		if err != nil || a.seen[string(line)] {
+			continue
+		}
+		if a.size+int64(len(lines)+len(line)+1) > a.maxBytes {
+			if !a.full {
+				glog.Warningf("Audit file %s reached %d bytes, not recording more klines", a.path, a.maxBytes)
+				a.full = true
+			}
+			break
+		}
+		a.seen[string(line)] = true
+		lines = append(append(lines, line...), '\n')
+	}
+	if len(lines) == 0 {
+		return
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		glog.Errorf("Cannot open the audit file: %v", err)
+		return
+	}
+	defer f.Close()
+	n, err := f.Write(lines)
+	a.size += int64(n)
+	if err != nil {
+		glog.Errorf("Cannot write the audit file: %v", err)
+	}
+}
+
+// readAudit returns the records of symbol in the audit file, only those of
+// epoch unless it is 0
+func readAudit(path, symbol string, epoch int64) ([]auditRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []auditRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []auditRecord{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		if r.Symbol == symbol && (epoch == 0 || r.Epoch == epoch) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// auditPath is where the audited klines are queried, e.g.
+//
+//	curl 'localhost:5993/binance/bnb/audit?symbol=EOS&epoch=1533081600'
+func auditPath(quote string) string {
+	return "/binance/" + strings.ToLower(quote) + "/audit"
+}
+
+// registerAudit installs the audit handler on the default HTTP mux, for the
+// workers registered by registerBackfills
+func registerAudit(bn *BinanceFetcher) {
+	registerHandler(auditPath(bn.baseCurrency), handleAudit)
+}
+
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	bn, timeframe := workerFor(r)
+	if bn == nil || bn.audit == nil {
+		http.Error(w, fmt.Sprintf("no audit for timeframe %q", timeframe), http.StatusNotFound)
+		return
+	}
+	var epoch int64
+	if s := r.FormValue("epoch"); s != "" {
+		var err error
+		if epoch, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid epoch %q", s), http.StatusBadRequest)
+			return
+		}
+	}
+	bn.audit.mu.Lock()
+	records, err := readAudit(bn.audit.path, r.FormValue("symbol"), epoch)
+	bn.audit.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// maxQueuedBackfills bounds the requested backfills waiting for a worker
+const maxQueuedBackfills = 10
+
+// backfillRequest asks a worker to fetch the candles of a symbol between
+// start and end inclusive, concurrently with its live loop
+type backfillRequest struct {
+	symbol string
+	start  time.Time
+	end    time.Time
+}
+
+var (
+	workersMu sync.Mutex
+	// workers maps the quote and timeframe to the worker requests are
+	// handled by, see workerKey
+	workers = map[string]*BinanceFetcher{}
+	// handled are the paths installed by registerHandler
+	handled = map[string]bool{}
+)
+
+// workerKey is the key of the worker of quote and timeframe in workers
+func workerKey(quote, timeframe string) string {
+	return strings.ToUpper(quote) + "/" + timeframe
+}
+
+// workerFor returns the worker of the quote in the path of r, e.g.
+// /binance/bnb/backfill, and of its timeframe parameter, 1Min by default,
+// along with the timeframe
+func workerFor(r *http.Request) (*BinanceFetcher, string) {
+	timeframe := r.FormValue("timeframe")
+	if timeframe == "" {
+		timeframe = "1Min"
+	}
+	quote := ""
+	if parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/"); len(parts) > 1 {
+		quote = parts[1]
+	}
+	workersMu.Lock()
+	defer workersMu.Unlock()
+	return workers[workerKey(quote, timeframe)], timeframe
+}
+
+// registerHandler installs handler on the default HTTP mux next to
+// /heartbeat, once for each path
+func registerHandler(path string, handler http.HandlerFunc) {
+	workersMu.Lock()
+	defer workersMu.Unlock()
+	if handled[path] {
+		return
+	}
+	handled[path] = true
+	// another Binance plugin for the same quote may own the path already
+	if _, pattern := http.DefaultServeMux.Handler(&http.Request{URL: &url.URL{Path: path}}); pattern == path {
+		glog.Warningf("%s is already handled by another plugin", path)
+		return
+	}
+	http.HandleFunc(path, handler)
+}
+
+// backfillPath is where backfills are requested, e.g.
+//
+//	curl -X POST 'localhost:5993/binance/bnb/backfill?symbol=EOS&start=2018-08-01+00:00&end=2018-08-01+06:00'
+func backfillPath(quote string) string {
+	return "/binance/" + strings.ToLower(quote) + "/backfill"
+}
+
+// registerBackfills makes bn the target of the requests for its quote and
+// each of its timeframes and installs the backfill handler
+func registerBackfills(bn *BinanceFetcher) {
+	workersMu.Lock()
+	for _, tf := range bn.timeframes() {
+		workers[workerKey(bn.baseCurrency, tf.String)] = bn
+	}
+	workersMu.Unlock()
+	registerHandler(backfillPath(bn.baseCurrency), handleBackfill)
+}
+
+func handleBackfill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	bn, timeframe := workerFor(r)
+	if bn == nil {
+		http.Error(w, fmt.Sprintf("no worker for timeframe %q", timeframe), http.StatusNotFound)
+		return
+	}
+
+	req, err := bn.parseBackfill(r.FormValue("symbol"), r.FormValue("start"), r.FormValue("end"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if tf := bn.timeframeOf(req.symbol); tf.String != timeframe {
+		http.Error(w, fmt.Sprintf("%s is collected in %s, not %s", req.symbol, tf.String, timeframe), http.StatusBadRequest)
+		return
+	}
+	select {
+	case bn.backfills <- req:
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "queued backfill of %s from %v to %v\n", req.symbol, req.start, req.end)
+	default:
+		http.Error(w, "too many backfills queued", http.StatusServiceUnavailable)
+	}
+}
+
+// parseBackfill validates a backfill of one of the worker's symbols, with
+// start and end in the query_start format and end before the forming candle
+func (bn *BinanceFetcher) parseBackfill(symbol, start, end string) (backfillRequest, error) {
+	req := backfillRequest{symbol: symbol, start: queryTime(start), end: queryTime(end)}
+	known := false
+	for _, s := range bn.symbols {
+		known = known || s == symbol
+	}
+	switch {
+	case !known:
+		return req, fmt.Errorf("unknown symbol %q", symbol)
+	case req.start.IsZero():
+		return req, fmt.Errorf("invalid start %q", start)
+	case req.end.IsZero():
+		return req, fmt.Errorf("invalid end %q", end)
+	case req.end.Before(req.start):
+		return req, fmt.Errorf("end %v is before start %v", req.end, req.start)
+	case !req.end.Before(bn.clock.Now().Add(-bn.timeframeOf(symbol).Duration)):
+		return req, fmt.Errorf("end %v is not in the past", req.end)
+	}
+	return req, nil
+}
+
+// serveBackfills runs the queued backfills one at a time
+func (bn *BinanceFetcher) serveBackfills() {
+	for req := range bn.backfills {
+		bn.backfill(req)
+	}
+}
+
+// backfill fetches the requested range in windows of request_span
+// intervals, advancing by advance_step like Run, and writes the candles
+// missing from the bucket
+func (bn *BinanceFetcher) backfill(req backfillRequest) {
+	glog.Infof("Backfilling %s from %v to %v", req.symbol, req.start, req.end)
+	interval := bn.intervalOf(req.symbol)
+	var end time.Time
+	for start := req.start; end.Before(req.end); start = start.Add(bn.advanceStep) {
+		end = start.Add(bn.requestSpan)
+		if end.After(req.end) {
+			end = req.end
+		}
+		rates, err := bn.klines(context.Background(), req.symbol, interval, timeToMillis(start), timeToMillis(end))
+		if err != nil {
+			glog.Errorf("Backfill of %s stopped at %v: %v", req.symbol, start, err)
+			return
+		}
+		cs, err := bn.toColumnSeries(req.symbol, rates, false)
+		if err != nil {
+			glog.Errorf("Conversion error for %s: %v", req.symbol, err)
+			return
+		}
+		if cs != nil {
+			if err := bn.write(req.symbol, cs, true); err != nil {
+				glog.Errorf("Backfill of %s stopped at %v: %v", req.symbol, start, err)
+				return
+			}
+		}
+	}
+	glog.Infof("Backfilled %s from %v to %v", req.symbol, req.start, req.end)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestBackfillOpenEnded(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start.Add(10 * time.Hour)}
+	client := &requestRecorder{klinesClient: &clockClient{&fixtureClient{klines: map[string][]*binance.Kline{
+		"EOSBNB": syntheticKlines(start, 11*60, time.Minute),
+	}}, clk}}
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 10:02",
+        "bucket_name_template": "OPENENDED_{base}",
+        "backfill_open_ended": true
+        }`)
+	worker.clock = clk
+	worker.Run()
+
+	// the limit of 500 candles, then the rest up to the forming candle
+	c.Assert(client.ranges[:2], DeepEquals, [][2]int64{
+		{timeToMillis(start), 0},
+		{timeToMillis(start.Add(500 * time.Minute)), 0},
+	})
+	epoch := readBucket(c, "OPENENDED_EOS/1Min/OHLCV").GetEpoch()
+	c.Assert(epoch, HasLen, 10*60+2)
+	for i := range epoch {
+		c.Assert(epoch[i], Equals, start.Add(time.Duration(i)*time.Minute).Unix())
+	}
+}
+
+func (s *RunTestSuite) TestBackfill(c *C) {
+	client := newFixtureClient(c, "EOSBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "BACKFILL_{base}"
+        }`)
+	worker.Run()
+	c.Assert(readBucket(c, "BACKFILL_EOS/1Min/OHLCV").Len(), Equals, 110)
+
+	// the exchange has since filled the 10 minute gap
+	klines := client.klines["EOSBNB"]
+	for m := int64(30); m < 40; m++ {
+		k := *klines[29]
+		k.OpenTime = 1533081600000 + m*60000
+		k.CloseTime = k.OpenTime + 59999
+		klines = append(klines, &k)
+	}
+	sort.Slice(klines, func(i, j int) bool { return klines[i].OpenTime < klines[j].OpenTime })
+	client.klines["EOSBNB"] = klines
+
+	// requests are validated and queued
+	post := func(method, query string) int {
+		w := httptest.NewRecorder()
+		handleBackfill(w, httptest.NewRequest(method, backfillPath("BNB")+"?"+query, nil))
+		return w.Code
+	}
+	c.Assert(post("GET", "symbol=EOS&start=2018-08-01+00:00&end=2018-08-01+02:00"), Equals, http.StatusMethodNotAllowed)
+	c.Assert(post("POST", "symbol=XRP&start=2018-08-01+00:00&end=2018-08-01+02:00"), Equals, http.StatusBadRequest)
+	c.Assert(post("POST", "symbol=EOS&start=2018-08-01+02:00&end=2018-08-01+00:00"), Equals, http.StatusBadRequest)
+	c.Assert(post("POST", "symbol=EOS&start=2018-08-01+00:00&end=2018-08-01+02:00&timeframe=1H"), Equals, http.StatusNotFound)
+	c.Assert(post("POST", "symbol=EOS&start=2018-08-01+00:00&end=2018-08-01+02:00"), Equals, http.StatusAccepted)
+
+	// Run serves the queue, writing the missing candles
+	for i := 0; i < 100 && readBucket(c, "BACKFILL_EOS/1Min/OHLCV").Len() < len(klines); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assertKlines(c, readBucket(c, "BACKFILL_EOS/1Min/OHLCV"), klines)
+}
+
+// TestBackfillDuringRun writes from a backfill request while Run writes the
+// same symbol, for go test -race to check the state they share
+func (s *RunTestSuite) TestBackfillDuringRun(c *C) {
+	client := newFixtureClient(c, "EOSBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "CONCURRENT_{base}",
+        "write_latency_threshold": "1ns",
+        "max_write_delay": "0s"
+        }`)
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.backfill(backfillRequest{symbol: "EOS", start: start, end: start.Add(2 * time.Hour)})
+		}()
+	}
+	worker.Run()
+	wg.Wait()
+	assertKlines(c, readBucket(c, "CONCURRENT_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+}
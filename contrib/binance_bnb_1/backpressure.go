@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// minWriteDelay is the first step of the delay once writes become slow
+const minWriteDelay = 100 * time.Millisecond
+
+// writeBackpressure keeps a sliding window of WriteCSM latencies and grows the
+// delay between API requests while the average latency is above the threshold,
+// shrinking it back to zero once the storage engine catches up.  It is
+// shared by the writes of Run and of the backfill requests.
+type writeBackpressure struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	maxDelay  time.Duration
+	window    []time.Duration
+	next      int
+	count     int
+	delay     time.Duration
+}
+
+func newWriteBackpressure(threshold, maxDelay time.Duration, windowSize int) *writeBackpressure {
+	return &writeBackpressure{
+		threshold: threshold,
+		maxDelay:  maxDelay,
+		window:    make([]time.Duration, windowSize),
+	}
+}
+
+// observe records the latency of one write and returns the delay
+// to wait before the next request
+func (wb *writeBackpressure) observe(latency time.Duration) time.Duration {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wb.window[wb.next] = latency
+	wb.next = (wb.next + 1) % len(wb.window)
+	if wb.count < len(wb.window) {
+		wb.count++
+	}
+
+	var total time.Duration
+	for _, l := range wb.window[:wb.count] {
+		total += l
+	}
+	average := total / time.Duration(wb.count)
+
+	if average > wb.threshold {
+		if wb.delay == 0 {
+			wb.delay = minWriteDelay
+		} else {
+			wb.delay *= 2
+		}
+		if wb.delay > wb.maxDelay {
+			wb.delay = wb.maxDelay
+		}
+	} else if wb.delay > 0 {
+		wb.delay /= 2
+		if wb.delay < minWriteDelay {
+			wb.delay = 0
+		}
+	}
+	return wb.delay
+}
@@ -0,0 +1,28 @@
+package main
+
+import "github.com/golang/glog"
+
+// backfillBackward fetches the candles of each symbol from query_start up to
+// its first stored candle, when the bucket starts later.  The rows are older
+// than the last written ones, so they are written like a requested backfill.
+func (bn *BinanceFetcher) backfillBackward(symbols []string) {
+	for _, symbol := range symbols {
+		if bn.paused[symbol] {
+			continue
+		}
+		if bn.shuttingDown() {
+			return
+		}
+		tbk := bn.tbkFor(symbol)
+		first, err := firstStoredTime(bn.store, tbk)
+		if err != nil {
+			glog.Errorf("Cannot read the first timestamp of %s: %v", symbol, err)
+			continue
+		}
+		start := bn.capBackfill(bn.symbolStart(symbol, bn.queryStart), bn.clock.Now().UTC())
+		if first.IsZero() || !first.After(start) {
+			continue
+		}
+		bn.backfill(backfillRequest{symbol: symbol, start: start, end: first.Add(-bn.timeframeOf(symbol).Duration)})
+	}
+}
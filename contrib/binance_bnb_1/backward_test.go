@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestBackfillBackward(c *C) {
+	client := newFixtureClient(c, "EOSBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 01:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "BACKWARD_{base}"
+        }`)
+	worker.Run()
+	first, err := firstStoredTime(worker.store, io.NewTimeBucketKey("BACKWARD_EOS/1Min/OHLCV"))
+	c.Assert(err, IsNil)
+	c.Assert(first.Before(time.Date(2018, 8, 1, 1, 0, 0, 0, time.UTC)), Equals, false)
+
+	worker = s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "BACKWARD_{base}",
+        "backfill_backward": true
+        }`)
+	worker.Run()
+	assertKlines(c, readBucket(c, "BACKWARD_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+}
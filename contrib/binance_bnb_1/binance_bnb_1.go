@@ -1,37 +1,99 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
-	"math"
+	"fmt"
+	goio "io"
+	"math/rand"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	binance "github.com/adshao/go-binance"
-	"github.com/alpacahq/marketstore/executor"
-	"github.com/alpacahq/marketstore/planner"
 	"github.com/alpacahq/marketstore/plugins/bgworker"
 	"github.com/alpacahq/marketstore/utils"
 	"github.com/alpacahq/marketstore/utils/io"
 	"github.com/golang/glog"
 )
 
+// defaultBucketNameTemplate keeps the historical BINANCE_<quote>_<base> bucket naming
+const defaultBucketNameTemplate = "{exchange}_{quote}_{base}"
+
+var bucketNamePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+const defaultAttributeGroup = "OHLCV"
+
+// defaultBaseCurrency is the quote asset of this plugin when base_currency is
+// not set
+const defaultBaseCurrency = "BNB"
+
+// klineColumns are the columns written for each candle besides Epoch
+var klineColumns = []string{"Open", "High", "Low", "Close", "Volume"}
+
+// knownAttributeGroups maps attribute group names the planner recognizes
+// as candles to the columns they imply
+var knownAttributeGroups = map[string][]string{
+	"OHLC":  {"Open", "High", "Low", "Close"},
+	"OHLCV": {"Open", "High", "Low", "Close", "Volume"},
+}
+
+// reservedAttributeGroups are those of the other buckets the worker writes
+// next to the candles
+var reservedAttributeGroups = map[string]bool{
+	"STATUS":   true,
+	"TICKER24": true,
+	"QUOTE":    true,
+	"CAUGHTUP": true,
+	"SNAPSHOT": true,
+	"FUNDING":  true,
+}
+
+// timeframePattern matches a positive count of one of the utils timeframe units
+var timeframePattern = regexp.MustCompile(`^[0-9]+(S|Sec|T|Min|H|D|W|M|Y)$`)
+
+// defaultBackfillSleep is the pause between the requests of past candles
+const defaultBackfillSleep = 10 * time.Second
+
+// defaultRequestSpan and defaultAdvanceStep are the intervals covered by a
+// backfill request and those the next one starts after, see request_span
+const (
+	defaultRequestSpan = 300
+	defaultAdvanceStep = 300
+)
+
+// maxConcurrentProbes bounds the symbols probed at once by getAllSymbols
+const maxConcurrentProbes = 4
+
 var suffixBinanceDefs = map[string]string{
 	"Min": "m",
 	"H":   "h",
 	"D":   "d",
 	"W":   "w",
+	"M":   "M",
 }
 
-// ExchangeInfo exchange info
+// binanceIntervals are the kline intervals served by Binance
+var binanceIntervals = []string{"1m", "3m", "5m", "15m", "30m", "1h", "2h", "4h", "6h", "8h", "12h", "1d", "3d", "1w", "1M"}
+
+// ExchangeInfo is the /exchangeInfo response of the venues.  It is decoded
+// here rather than through go-binance: the version pinned in go.mod does not
+// cover the rateLimits requestWeightLimit reads, nor the exchangeInfo paths of
+// binanceus and binancefutures.  The request still goes through the transport
+// of the klines requests, proxy and weight accounting included, see
+// jsonHTTPClient.
 type ExchangeInfo struct {
 	Timezone   string `json:"timezone"`
 	ServerTime int64  `json:"serverTime"`
 	RateLimits []struct {
 		RateLimitType string `json:"rateLimitType"`
 		Interval      string `json:"interval"`
+		IntervalNum   int    `json:"intervalNum"`
 		Limit         int    `json:"limit"`
 	} `json:"rateLimits"`
 	ExchangeFilters []interface{} `json:"exchangeFilters"`
@@ -59,27 +121,324 @@ type ExchangeInfo struct {
 	} `json:"symbols"`
 }
 
-// Get JSON via http request and decodes it using NewDecoder. Sets target interface to decoded json
-func getJson(url string, target interface{}) error {
-	var myClient = &http.Client{Timeout: 10 * time.Second}
-	r, err := myClient.Get(url)
+// jsonClient is shared by the getJson requests of the venues without an
+// http client of their own.  Its transport asks for gzip
+// and decodes it transparently as long as requests do not set Accept-Encoding
+// themselves, which shrinks the exchangeInfo payload several times over.
+var jsonClient = &http.Client{Timeout: 10 * time.Second}
+
+// Get JSON via http request and decodes it using NewDecoder. Sets target interface to decoded json.
+// A response other than 200 OK, e.g. an error page during an outage, is an error.
+func getJson(client *http.Client, url string, target interface{}) error {
+	r, err := client.Get(url)
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, r.Status)
+	}
 
-	return json.NewDecoder(r.Body).Decode(target)
+	body := goio.Reader(r.Body)
+	// the transport leaves the body compressed if it did not ask for gzip
+	if !r.Uncompressed && r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	return json.NewDecoder(body).Decode(target)
 }
 
-// For ConvertStringToFloat function and Run() function to making exiting easier
-var errorsConversion []error
+// on_error policies for candles whose values cannot be parsed
+const (
+	// onErrorSkip drops the candle and carries on
+	onErrorSkip = "skip"
+	// onErrorAbort stops the worker
+	onErrorAbort = "abort"
+	// onErrorZero writes 0 for the value and logs it
+	onErrorZero = "zero"
+)
+
+// modes of the worker
+const (
+	// modeLoop catches up from query_start and polls the candles as they
+	// close, until query_end if set
+	modeLoop = "loop"
+	// modeOneshot catches up to the time the worker starts and exits
+	modeOneshot = "oneshot"
+	// modeSchedule fetches daily and coarser candles once after each of them
+	// closes instead of polling, see runSchedule
+	modeSchedule = "schedule"
+)
+
+// reload policies for the candles already stored after query_start
+const (
+	// reloadSkip fetches each symbol from its last stored candle
+	reloadSkip = "skip"
+	// reloadDedup fetches from query_start and drops the stored candles,
+	// unless allow_updates rewrites them
+	reloadDedup = "dedup"
+	// reloadOverwrite fetches from query_start and writes every candle
+	reloadOverwrite = "overwrite"
+)
 
 // FetcherConfig is a structure of binancefeeder's parameters
 type FetcherConfig struct {
-	Symbols       []string `json:"symbols"`
-	BaseCurrency  string   `json:"base_currency"`
-	QueryStart    string   `json:"query_start"`
-	BaseTimeframe string   `json:"base_timeframe"`
+	Symbols []string `json:"symbols"`
+	// BaseCurrency is the quote asset of the pairs collected.  defaults to
+	// "BNB"
+	BaseCurrency  string `json:"base_currency"`
+	QueryStart    string `json:"query_start"`
+	BaseTimeframe string `json:"base_timeframe"`
+	// QueryEnd stops the fetcher once data up to this time is written
+	QueryEnd string `json:"query_end"`
+	// Venue selects the Binance-compatible exchange, one of "binance",
+	// "binanceus" and "binancefutures".  defaults to "binance"
+	Venue string `json:"venue"`
+	// BucketNameTemplate is the Symbol part of the bucket key with {base},
+	// {quote} and {exchange} placeholders, e.g. "BINANCE_{base}_{quote}"
+	BucketNameTemplate string `json:"bucket_name_template"`
+	// AttributeGroup is the last part of the bucket key.  defaults to "OHLCV"
+	AttributeGroup string `json:"attribute_group"`
+	// WriteLatencyThreshold is the average WriteCSM latency, e.g. "1s", above
+	// which the fetcher starts slowing down its requests
+	WriteLatencyThreshold string `json:"write_latency_threshold"`
+	// MaxWriteDelay caps the delay added between requests under write pressure
+	MaxWriteDelay string `json:"max_write_delay"`
+	// AllowUpdates overwrites stored candles when a re-fetch returns
+	// different values, e.g. after the exchange corrects them
+	AllowUpdates bool `json:"allow_updates"`
+	// MaxBackfill limits how far back the fetcher starts, e.g. "30d", so a
+	// neglected bucket does not stall collection.  unlimited by default
+	MaxBackfill string `json:"max_backfill"`
+	// AllowedStatuses are the exchangeInfo statuses symbols are collected in,
+	// others such as BREAK pause them.  defaults to ["TRADING"]
+	AllowedStatuses []string `json:"allowed_statuses"`
+	// EpochSource is "open" or "close", whether the candle open or close time
+	// is written as Epoch.  defaults to "open"
+	EpochSource string `json:"epoch_source"`
+	// OnError is what happens to a candle with a value that cannot be parsed:
+	// "skip" drops it, "abort" stops the worker and "zero" writes 0 for the
+	// value.  defaults to "skip"
+	OnError string `json:"on_error"`
+	// Verify re-fetches a random sample of the stored candles and compares
+	// them with the buckets, see verify
+	Verify bool `json:"verify"`
+	// VerifySamples is the number of candles of each symbol verified.
+	// defaults to 20
+	VerifySamples int `json:"verify_samples"`
+	// VerifyTolerance is the relative difference allowed between the stored
+	// and fetched values.  defaults to 1e-9
+	VerifyTolerance float64 `json:"verify_tolerance"`
+	// StatusInterval is how often the collection status of each symbol is
+	// written to its STATUS bucket, e.g. "1m".  off by default
+	StatusInterval string `json:"status_interval"`
+	// BackfillSleep is the pause between the requests of past candles, e.g.
+	// "0s" to rely on the request weight limit alone.  defaults to "10s"
+	BackfillSleep string `json:"backfill_sleep"`
+	// Ticker24Interval is how often the 24hr ticker statistics of each
+	// symbol are written to its TICKER24 bucket, e.g. "5m".  off by default
+	Ticker24Interval string `json:"ticker24_interval"`
+	// FinishPassOnShutdown fetches the remaining symbols of the current pass
+	// when the server shuts down, instead of stopping after the symbol being
+	// fetched
+	FinishPassOnShutdown bool `json:"finish_pass_on_shutdown"`
+	// Mode is how the worker runs, see the mode constants
+	Mode string `json:"mode"`
+	// ProxyURL sends all Binance requests through the proxy instead of the
+	// one of HTTP_PROXY and HTTPS_PROXY
+	ProxyURL string `json:"proxy_url"`
+	// CAFile is a PEM bundle trusted next to the system roots, e.g. the CA
+	// of a TLS-intercepting proxy
+	CAFile string `json:"ca_file"`
+	// SymbolSource lists the symbols when symbols is not set: "api" for all
+	// the symbols of the venue, "file:<path>" or "bucket:<key>"
+	SymbolSource string `json:"symbol_source"`
+	// RemoteEndpoint is the URL of the marketstore server the candles are
+	// read from and written to through its RPC API, e.g.
+	// http://storage:5993, instead of the instance the plugin is loaded into
+	RemoteEndpoint string `json:"remote_endpoint"`
+	// WeightWarnFraction is the fraction of the request weight limit used,
+	// according to the venue, above which a warning is logged
+	WeightWarnFraction float64 `json:"weight_warn_fraction"`
+	// CatchupTolerance is the number of intervals behind now within which the
+	// backfill turns live, e.g. 2 for coarse timeframes.  defaults to 0,
+	// once a backfill pass reaches now
+	CatchupTolerance int `json:"catchup_tolerance"`
+	// AuditSpread records the raw klines whose High/Low ratio is at least
+	// this, e.g. 1.5, to AuditFile.  off by default
+	AuditSpread float64 `json:"audit_spread"`
+	// AuditFile is the file of JSON lines the audited klines are appended to
+	AuditFile string `json:"audit_file"`
+	// AuditMaxBytes bounds the audit file.  defaults to 10MB
+	AuditMaxBytes int64 `json:"audit_max_bytes"`
+	// Columns restricts the columns written besides Epoch to these of
+	// Open, High, Low, Close and Volume, e.g. ["Close"].  defaults to all
+	Columns []string `json:"columns"`
+	// Shard fetches a deterministic part of the symbols, for several workers
+	// to share a universe too large for one
+	Shard *ShardConfig `json:"shard"`
+	// MaxSymbols is the number of symbols above which the worker warns and
+	// suggests a shard count.  off by default
+	MaxSymbols int `json:"max_symbols"`
+	// SkipSchemaMismatch drops the symbols whose bucket exists with other
+	// columns than the ones written, instead of failing to start
+	SkipSchemaMismatch bool `json:"skip_schema_mismatch"`
+	// BackfillOpenEnded catches up with requests from the last candle
+	// fetched without endTime, letting Binance return up to its limit
+	BackfillOpenEnded bool `json:"backfill_open_ended"`
+	// LeaseTTL claims the buckets with leases lasting this long without
+	// renewal, e.g. "5m", so that a second worker writing one of them fails
+	// to start.  off by default
+	LeaseTTL string `json:"lease_ttl"`
+	// SanityRejectNonPositive drops the candles with an Open, High, Low or
+	// Close of 0 or less
+	SanityRejectNonPositive bool `json:"sanity_reject_non_positive"`
+	// SanityAllowHighBelowLow writes the candles with a High below the Low,
+	// which are dropped by default
+	SanityAllowHighBelowLow bool `json:"sanity_allow_high_below_low"`
+	// SanityMaxValue drops the candles with a value above it, e.g. 1e12.
+	// off by default
+	SanityMaxValue float64 `json:"sanity_max_value"`
+	// SymbolStarts overrides query_start and the last stored candle for
+	// some symbols, e.g. {"EOS": "2018-07-01"}, which are not fetched before
+	// their start time
+	SymbolStarts map[string]string `json:"symbol_starts"`
+	// ClockDriftWarn is the offset between the local clock and the server
+	// time above which a warning is logged.  defaults to "1s"
+	ClockDriftWarn string `json:"clock_drift_warn"`
+	// CorrectClockDrift shifts the local time by the offset of the server
+	// time where the worker aligns its requests to the candles
+	CorrectClockDrift bool `json:"correct_clock_drift"`
+	// BaseCurrencies collects the pairs quoted in each of these, e.g.
+	// ["USDT", "BTC"], instead of base_currency.  The symbols apply to each
+	// of them.
+	BaseCurrencies []string `json:"base_currencies"`
+	// QuietIntervals flags the symbols returning no candles for this many
+	// intervals in a row while polling, which may be halted or delisted.
+	// off by default
+	QuietIntervals int `json:"quiet_intervals"`
+	// QuarantineQuiet also skips the quiet symbols until their exchangeInfo
+	// status is refreshed
+	QuarantineQuiet bool `json:"quarantine_quiet"`
+	// BackfillBackward fetches the candles between query_start and the
+	// first stored candle of each symbol on start, which the passes skip as
+	// they only append
+	BackfillBackward bool `json:"backfill_backward"`
+	// WriteRetries is the number of times a failed write is retried, with
+	// the delays of the failed requests.  defaults to 3
+	WriteRetries int `json:"write_retries"`
+	// OnWriteError is what happens to a batch still failing to write after
+	// the retries: "abort" stops the worker and "skip" drops it, to be
+	// fetched again by the next pass.  defaults to "abort"
+	OnWriteError string `json:"on_write_error"`
+	// ProgressInterval is how often the progress of each symbol is logged
+	// while backfilling, e.g. "1m".  off by default
+	ProgressInterval string `json:"progress_interval"`
+	// BookTicker writes the best bid and ask of each symbol to its QUOTE
+	// bucket after each pass while polling, see collectBookTicker
+	BookTicker bool `json:"book_ticker"`
+	// CompressionHints overrides the compression hint of columns, e.g.
+	// {"Volume": "none"}, see CompressionHints
+	CompressionHints map[string]string `json:"compression_hints"`
+	// WriteOnlyOnChange skips the writes whose newest candle is the last one
+	// written for the symbol, unchanged, see unchangedWrite
+	WriteOnlyOnChange bool `json:"write_only_on_change"`
+	// Reload is what happens to the candles stored after query_start:
+	// "skip" does not fetch them again, "dedup" fetches and drops them and
+	// "overwrite" fetches and writes them.  defaults to "skip"
+	Reload string `json:"reload"`
+	// CaughtUpEvents writes a row to the CAUGHTUP bucket of each symbol the
+	// first time it is caught up, see checkCaughtUp
+	CaughtUpEvents bool `json:"caught_up_events"`
+	// RequestTimeout is how long a klines request may take, e.g. "5s", before
+	// it fails and is retried.  defaults to 10s
+	RequestTimeout string `json:"request_timeout"`
+	// Snapshot writes the latest candle of every symbol to a single SNAPSHOT
+	// bucket once per interval while polling, see writeSnapshot
+	Snapshot bool `json:"snapshot"`
+	// SystemStatus checks the system status of the venue before the passes
+	// and waits out its maintenance, see waitMaintenance
+	SystemStatus bool `json:"system_status"`
+	// MaintenanceSleep is the wait between the checks of the system status
+	// during maintenance.  defaults to 5m
+	MaintenanceSleep string `json:"maintenance_sleep"`
+	// CloseDelay is how long after a candle closes it is fetched while
+	// polling, e.g. "2s", so that late trades have settled it.  defaults to
+	// 0, and to 10s in the schedule mode
+	CloseDelay string `json:"close_delay"`
+	// AttributeGroups restricts attribute_group to these, e.g. the ones the
+	// consumers of the buckets know.  any valid one by default
+	AttributeGroups []string `json:"attribute_groups"`
+	// StateAddr is the address the state of the worker is served on as JSON,
+	// e.g. "localhost:6061", see serveState.  off by default
+	StateAddr string `json:"state_addr"`
+	// RequestSpan is the number of intervals between the start and end time
+	// of a backfill request, at most 499 so that the candles of both ends
+	// fit in a response.  defaults to 300
+	RequestSpan int `json:"request_span"`
+	// AdvanceStep is the number of intervals the next backfill request starts
+	// after the previous one, at most request_span so that the windows leave
+	// no gap.  defaults to 300
+	AdvanceStep int `json:"advance_step"`
+	// Funding writes the mark price, index price and funding rate of each
+	// symbol to its FUNDING bucket, on the binancefutures venue only, see
+	// collectFunding
+	Funding bool `json:"funding"`
+	// FundingInterval is how often the funding is collected, e.g. "30s".
+	// defaults to 1m
+	FundingInterval string `json:"funding_interval"`
+	// WindowRetries is the number of failed requests in a row after which
+	// a backfill window of a symbol is given up and left as a gap, see
+	// windowFailed.  0, the default, retries it until it succeeds
+	WindowRetries int `json:"window_retries"`
+	// AdaptiveSpan widens the backfill requests of each symbol to the
+	// density of its candles, see learnSpan
+	AdaptiveSpan bool `json:"adaptive_span"`
+	// Staging writes the candles to a staging bucket of each symbol,
+	// promoted to its bucket once query_end is reached and the candles are
+	// verified, see promoteStaged.  requires query_end or the oneshot mode
+	Staging bool `json:"staging"`
+	// CompleteDaysOnly ends the candles at the last midnight UTC, so that
+	// the candle of the current day is never written, see completeDaysEnd.
+	// requires the 1D base_timeframe
+	CompleteDaysOnly bool `json:"complete_days_only"`
+	// Transforms are the names of the transforms applied in order to the
+	// candles before they are written, e.g. ["vwap"], see transforms
+	Transforms []string `json:"transforms"`
+	// CacheDir is the directory the klines of the backfill are cached in,
+	// and read from instead of the API when requested again, see klineCache
+	CacheDir string `json:"cache_dir"`
+	// CacheMaxBytes bounds the kline cache.  defaults to 100MB
+	CacheMaxBytes int64 `json:"cache_max_bytes"`
+	// CheckpointFile is the file the progress of each worker is written to
+	// atomically, with the quote and timeframe added to its name, see
+	// checkpoint
+	CheckpointFile string `json:"checkpoint_file"`
+	// CheckpointInterval is how often the checkpoint is written, e.g.
+	// "30s".  defaults to 1m
+	CheckpointInterval string `json:"checkpoint_interval"`
+	// SymbolTimeframes overrides base_timeframe for some symbols, e.g.
+	// {"BTC": "5Min"}, collected along with the others in their own
+	// timeframe, see passTimeframe
+	SymbolTimeframes map[string]string `json:"symbol_timeframes"`
+	// StatusRefreshInterval is how often the symbol statuses are refreshed
+	// from exchangeInfo, e.g. "30m".  defaults to 10m, raised to
+	// min_refresh_interval
+	StatusRefreshInterval string `json:"status_refresh_interval"`
+	// MinRefreshInterval is the shortest status_refresh_interval allowed,
+	// and the shortest time between two refreshes, failed ones included.
+	// defaults to 1h
+	MinRefreshInterval string `json:"min_refresh_interval"`
+	// IncludeTradeCount adds the number of trades of each candle as the
+	// INT64 TradeNum column, see writtenColumns
+	IncludeTradeCount bool `json:"include_trade_count"`
+	// AppendOnly drops the re-fetched candles at or before the last one
+	// written instead of writing them over the stored ones, see filterWritten
+	AppendOnly bool `json:"append_only"`
 }
 
 // BinanceFetcher is the main worker for Binance
@@ -88,7 +447,159 @@ type BinanceFetcher struct {
 	symbols       []string
 	baseCurrency  string
 	queryStart    time.Time
+	queryEnd      time.Time
 	baseTimeframe *utils.Timeframe
+	interval      string
+	venue         venue
+	client        klinesClient
+	// bucketNameTemplate is resolved into the bucket name at write time
+	bucketNameTemplate string
+	attributeGroup     string
+	backpressure       *writeBackpressure
+	allowUpdates       bool
+	maxBackfill        time.Duration
+	allowedStatuses    map[string]bool
+	epochSource        string
+	onError            string
+	verifyEnabled      bool
+	verifySamples      int
+	verifyTolerance    float64
+	// paused are the symbols skipped while in a status that is not allowed
+	paused            map[string]bool
+	statusRefreshedAt time.Time
+	// mu guards lastWritten and the collection statuses between the live
+	// loop and requested backfills
+	mu sync.Mutex
+	// lastWritten is the last epoch written for each symbol
+	lastWritten map[string]int64
+	// collectionStatuses are written to the STATUS buckets every
+	// statusInterval, see writeCollectionStatuses
+	collectionStatuses map[string]*collectionStatus
+	statusInterval     time.Duration
+	statusWrittenAt    time.Time
+	backfillSleep      time.Duration
+	ticker24Interval   time.Duration
+	ticker24FetchedAt  time.Time
+	// shutdown is closed when the server shuts down gracefully
+	shutdown             <-chan struct{}
+	finishPassOnShutdown bool
+	mode                 string
+	backfills            chan backfillRequest
+	// limiter accounts for the request weight of the klines requests
+	limiter   *weightLimiter
+	serveOnce sync.Once
+
+	// transientErrors counts the requests failed in a row with errors that
+	// are retried, see retryDelay
+	transientErrors int
+	// symbolRequests serializes the requests of each symbol, symbolWrites
+	// the writes to its bucket
+	symbolRequests keyedMutex
+	symbolWrites   keyedMutex
+	// store holds the buckets, the marketstore instance the plugin is
+	// loaded into unless remote_endpoint is set
+	store store
+	// catchupTolerance is the number of intervals behind now at which the
+	// Run loop turns live, see nextPhase
+	catchupTolerance int
+	// audit records the anomalous klines, nil unless audit_spread is set
+	audit *auditLog
+	// columns are the columns written besides Epoch, in klineColumns order
+	columns []string
+	// clock is the time source of Run, the real one but in tests
+	clock clock
+	// backfillOpenEnded catches up with backfillForward before the Run loop
+	backfillOpenEnded bool
+	// suspension pauses the Run loop through the pause and resume handlers
+	suspension suspension
+	// leaseTTL is how long the leases on the buckets last without renewal,
+	// 0 without leases.  leaseOwner identifies the worker in them.
+	leaseTTL   time.Duration
+	leaseOwner int64
+	// sanity are the bounds of the candles written, see toColumnSeries
+	sanity sanityBounds
+	// symbolStarts are the times before which the symbols are not fetched,
+	// see symbolStart
+	symbolStarts map[string]time.Time
+	// clockOffset is the server time minus the local time at the last
+	// exchangeInfo request, see observeServerTime
+	clockOffset    time.Duration
+	clockDriftWarn time.Duration
+	// quietIntervals is the number of empty live passes after which a
+	// symbol is quiet, 0 to not track them.  see trackQuiet
+	quietIntervals  int
+	quarantineQuiet bool
+	// backwardFill is backfill_backward, see backfillBackward
+	backwardFill bool
+	writeRetries int
+	onWriteError string
+	// progress logs the progress of the backfills, see logProgress
+	progress   progressLog
+	bookTicker bool
+	// compressionHints are compression_hints
+	compressionHints  map[string]string
+	writeOnlyOnChange bool
+	// lastRows are the newest rows written of each symbol, kept with
+	// write_only_on_change or snapshot
+	lastRows map[string]writtenRow
+	reload   string
+	// caughtUp are the symbols whose caught up event is written, with
+	// caught_up_events
+	caughtUpEvents bool
+	caughtUp       map[string]bool
+	requestTimeout time.Duration
+	snapshot       bool
+	// snapshotAt is the interval of the last snapshot written
+	snapshotAt time.Time
+	// checkSystemStatus is system_status, systemStatusAt the time of the
+	// last check
+	checkSystemStatus bool
+	systemStatusAt    time.Time
+	maintenanceSleep  time.Duration
+	closeDelay        time.Duration
+	// phase is that of the Run loop, guarded by mu
+	phase phase
+	// requestSpan and advanceStep are the window of the backfill requests and
+	// the stride of their start
+	requestSpan time.Duration
+	advanceStep time.Duration
+	// fundingInterval is 0 without funding
+	fundingInterval  time.Duration
+	fundingFetchedAt time.Time
+	// windowFailures counts the failed requests in a row of the backfill
+	// window of each symbol, gaps are the windows given up, guarded by mu
+	windowRetries  int
+	windowFailures map[string]int
+	gaps           map[string][]gap
+	// spans are the learned spans of the backfill requests of each symbol
+	// in intervals, and fetchedThrough the ends of the last ones written,
+	// with adaptive_span.  guarded by mu
+	adaptiveSpan   bool
+	spans          map[string]int
+	fetchedThrough map[string]time.Time
+	// staging writes the candles to the staging buckets, see stagingKey
+	staging bool
+	// completeDaysOnly drops the candles closing after the last midnight UTC
+	completeDaysOnly bool
+	// transforms are applied to the candles before they are written, and
+	// transformColumns are the columns they add
+	transforms       []Transform
+	transformColumns []string
+	// cache holds the klines of the backfill, nil unless cache_dir is set
+	cache *klineCache
+	// checkpointer writes the checkpoint, nil unless checkpoint_file is set
+	checkpointer *checkpointer
+	// statusRefresh spaces the refreshes of the symbol statuses, see
+	// refreshStatuses
+	statusRefresh statusRefresh
+	// includeTradeCount writes the TradeNum column
+	includeTradeCount bool
+	// appendOnly drops the re-fetched candles already written
+	appendOnly bool
+	// symbolTimeframes and symbolIntervals are the timeframes and Binance
+	// intervals of the symbols of symbol_timeframes, see timeframeOf
+	symbolTimeframes map[string]*utils.Timeframe
+	symbolIntervals  map[string]string
 }
 
 // recast changes parsed JSON-encoded data represented as an interface to FetcherConfig structure
@@ -99,15 +610,23 @@ func recast(config map[string]interface{}) *FetcherConfig {
 	return &ret
 }
 
-//Convert string to float64 using strconv
-func convertStringToFloat(str string) float64 {
-	convertedString, err := strconv.ParseFloat(str, 64)
-	//Store error in string array which will be checked in main fucntion later to see if there is a need to exit
-	if err != nil {
-		glog.Errorf("String to float error: %v", err)
-		errorsConversion = append(errorsConversion, err)
+// parseKline returns the Open, High, Low, Close and Volume of the candle.
+// With the zero on_error policy unparsable values are logged and returned as 0.
+func parseKline(rate *binance.Kline, onError string) ([]float64, error) {
+	values := []string{rate.Open, rate.High, rate.Low, rate.Close, rate.Volume}
+	parsed := make([]float64, len(values))
+	for i, str := range values {
+		f, err := strconv.ParseFloat(str, 64)
+		if err == nil {
+			parsed[i] = f
+			continue
+		}
+		if onError != onErrorZero {
+			return nil, fmt.Errorf("invalid %s of the candle at %d: %v", klineColumns[i], rate.OpenTime, err)
+		}
+		glog.Warningf("Writing 0 for the invalid %s %q of the candle at %d", klineColumns[i], str, rate.OpenTime)
 	}
-	return convertedString
+	return parsed, nil
 }
 
 //Checks time string and returns correct time format
@@ -130,10 +649,201 @@ func queryTime(query string) time.Time {
 	return time.Time{}
 }
 
-//Convert time from milliseconds to Unix
-func convertMillToTime(originalTime int64) time.Time {
-	i := time.Unix(0, originalTime*int64(time.Millisecond))
-	return i
+// parseDays parses a duration like time.ParseDuration, also accepting whole
+// days such as "30d"
+func parseDays(str string) (time.Duration, error) {
+	if strings.HasSuffix(str, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(str, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", str)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(str)
+}
+
+// millisToEpochSec converts Binance milliseconds since the Unix epoch to the
+// seconds written to the Epoch column, rounding down like time.Unix
+func millisToEpochSec(ms int64) int64 {
+	sec := ms / 1000
+	if ms%1000 < 0 {
+		sec--
+	}
+	return sec
+}
+
+// timeToMillis converts t to the milliseconds since the Unix epoch Binance
+// expects, without going through UnixNano which overflows after 2262
+func timeToMillis(t time.Time) int64 {
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
+
+// binanceWeekOffset is the offset from the Unix epoch, a Thursday, of the
+// Monday the weekly candles open on
+const binanceWeekOffset = 4 * utils.Day
+
+// candleOpen returns the open time of the candle of tf containing t, aligned
+// to UTC like the candles of the exchange whatever the instance timezone:
+// days start at midnight UTC, weeks on Monday and months on the first.
+func candleOpen(t time.Time, tf *utils.Timeframe) time.Time {
+	if tf.Duration == utils.Week {
+		return utils.TruncateToTimeframe(t.UTC().Add(-binanceWeekOffset), tf).Add(binanceWeekOffset)
+	}
+	return utils.TruncateToTimeframe(t.UTC(), tf)
+}
+
+// candleClose returns the close time of the candle of tf opening at open, the
+// open time of the next one.  Months do not all last utils.Month.
+func candleClose(open time.Time, tf *utils.Timeframe) time.Time {
+	if strings.HasSuffix(tf.String, "M") {
+		return open.AddDate(0, int(tf.Duration/utils.Month), 0)
+	}
+	return open.Add(tf.Duration)
+}
+
+// completeDaysEnd returns the last midnight UTC at now, or query_end if
+// earlier, the end of the last complete day written with complete_days_only
+func (bn *BinanceFetcher) completeDaysEnd(now time.Time) time.Time {
+	end := candleOpen(now, bn.baseTimeframe)
+	if !bn.queryEnd.IsZero() && bn.queryEnd.Before(end) {
+		return bn.queryEnd
+	}
+	return end
+}
+
+// parseTimeframe parses base_timeframe, rejecting unknown units and
+// non-positive durations
+func parseTimeframe(str string) (*utils.Timeframe, error) {
+	if !timeframePattern.MatchString(str) {
+		return nil, fmt.Errorf("invalid base_timeframe %q", str)
+	}
+	tf := utils.NewTimeframe(str)
+	if tf == nil || tf.Duration <= 0 {
+		return nil, fmt.Errorf("invalid base_timeframe %q", str)
+	}
+	return tf, nil
+}
+
+// expandBucketName resolves the placeholders of a bucket name template
+func expandBucketName(template, exchange, base, quote string) string {
+	return strings.NewReplacer(
+		"{exchange}", exchange,
+		"{base}", base,
+		"{quote}", quote,
+	).Replace(template)
+}
+
+// validateBucketNameTemplate makes sure the template only uses known placeholders,
+// keeps symbols apart and expands to a legal Symbol item of a TimeBucketKey
+func validateBucketNameTemplate(template string) error {
+	for _, ph := range bucketNamePlaceholder.FindAllString(template, -1) {
+		switch ph {
+		case "{base}", "{quote}", "{exchange}":
+		default:
+			return fmt.Errorf("unknown placeholder %s in bucket_name_template %q", ph, template)
+		}
+	}
+	if !strings.Contains(template, "{base}") {
+		return fmt.Errorf("bucket_name_template %q must contain {base}", template)
+	}
+	name := expandBucketName(template, "EXCHANGE", "BASE", "QUOTE")
+	if strings.ContainsAny(name, "/:{}, \t") {
+		return fmt.Errorf("bucket_name_template %q does not produce a valid bucket name: %s", template, name)
+	}
+	tbk := io.NewTimeBucketKey(name + "/1Min/OHLCV")
+	if tbk.GetItemInCategory("Symbol") != name {
+		return fmt.Errorf("bucket_name_template %q does not produce a valid bucket name: %s", template, name)
+	}
+	return nil
+}
+
+// selectColumns returns the columns of klineColumns named, in their order, or
+// all of them if none is
+func selectColumns(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return klineColumns, nil
+	}
+	selected := map[string]bool{}
+	for _, name := range names {
+		known := false
+		for _, c := range klineColumns {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q, must be one of %s", name, strings.Join(klineColumns, ", "))
+		}
+		selected[name] = true
+	}
+	columns := []string{}
+	for _, c := range klineColumns {
+		if selected[c] {
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
+
+// validateAttributeGroup makes sure the attribute group is a legal part of a
+// TimeBucketKey, one of allowed if any, and a valid schema for the candles:
+// it agrees with the columns written to it, holds a row per interval and is
+// not that of another bucket of the worker.
+func validateAttributeGroup(group string, columns []string, allowed []string) error {
+	if group == "" || strings.ContainsAny(group, "/:, \t") {
+		return fmt.Errorf("invalid attribute_group %q", group)
+	}
+	if len(allowed) > 0 {
+		found := false
+		for _, a := range allowed {
+			found = found || a == group
+		}
+		if !found {
+			return fmt.Errorf("attribute_group %s is not one of attribute_groups %v", group, allowed)
+		}
+	}
+	if variableAttributeGroups[group] {
+		return fmt.Errorf("attribute_group %s holds variable length records, the candles need one with a row per interval", group)
+	}
+	if reservedAttributeGroups[group] {
+		return fmt.Errorf("attribute_group %s is that of other buckets of the worker", group)
+	}
+	implied, ok := knownAttributeGroups[group]
+	if !ok {
+		return nil
+	}
+	if len(implied) != len(columns) {
+		return fmt.Errorf("attribute_group %s implies columns %v but %v are written", group, implied, columns)
+	}
+	for i := range implied {
+		if implied[i] != columns[i] {
+			return fmt.Errorf("attribute_group %s implies columns %v but %v are written", group, implied, columns)
+		}
+	}
+	return nil
+}
+
+// bucketName returns the Symbol part of the bucket key symbol is written to
+func (bn *BinanceFetcher) bucketName(symbol string) string {
+	return expandBucketName(bn.bucketNameTemplate, bn.venue.bucketPrefix, symbol, bn.baseCurrency)
+}
+
+// candleKey is the key of the candles of the bucket name, e.g.
+// BINANCE_BNB_EOS/1Min/OHLCV
+func candleKey(name string, timeframe *utils.Timeframe, attributeGroup string) *io.TimeBucketKey {
+	return io.NewTimeBucketKey(name + "/" + timeframe.String + "/" + attributeGroup)
+}
+
+// tbkFor is the key of the bucket the candles of symbol are read from and
+// written to, its staging bucket with staging
+func (bn *BinanceFetcher) tbkFor(symbol string) *io.TimeBucketKey {
+	if bn.staging {
+		return bn.stagingKey(symbol)
+	}
+	return bn.liveKey(symbol)
+}
+
+// liveKey is the key of the bucket of the candles of symbol
+func (bn *BinanceFetcher) liveKey(symbol string) *io.TimeBucketKey {
+	return candleKey(bn.bucketName(symbol), bn.timeframeOf(symbol), bn.attributeGroup)
 }
 
 // Append if String is Missing from array
@@ -147,149 +857,1018 @@ func appendIfMissing(slice []string, i string) ([]string, bool) {
 	return append(slice, i), true
 }
 
-//Gets all symbols from binance
-func getAllSymbols(quoteAsset string) []string {
-	client := binance.NewClient("", "")
-	m := ExchangeInfo{}
-	err := getJson("https://api.binance.com/api/v1/exchangeInfo", &m)
+// Gets all symbols from the venue in one of the allowed statuses, sorted so
+// that runs go through them in the same order.  Symbols for which stored
+// returns true already have data and are not probed.  It fails if
+// exchangeInfo cannot be read, rather than guessing the symbols.
+func getAllSymbols(v venue, client klinesClient, quoteAsset string, allowedStatuses map[string]bool, stored func(symbol string) bool) ([]string, error) {
+	m, err := cachedExchangeInfo(v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the symbols, Binance /exchangeInfo API error: %v", err)
+	}
 	symbol := make([]string, 0)
 	status := make([]string, 0)
 	validSymbols := make([]string, 0)
 	tradingSymbols := make([]string, 0)
 	quote := ""
 
-	if err != nil {
-		glog.Errorf("Binance /exchangeInfo API error: %v", err)
-		tradingSymbols = []string{"EOS", "TRX", "ONT", "XRP", "ADA",
-			"LTC", "BCC", "TUSD", "IOTA", "ETC", "ICX", "NEO", "XLM", "QTUM"}
-	} else {
-		for _, info := range m.Symbols {
-			quote = info.QuoteAsset
-			notRepeated := true
-			// Check if data is the right base currency and then check if it's already recorded
-			if quote == quoteAsset {
-				symbol, notRepeated = appendIfMissing(symbol, info.BaseAsset)
-				if notRepeated {
-					status = append(status, info.Status)
-				}
+	for _, info := range m.Symbols {
+		quote = info.QuoteAsset
+		notRepeated := true
+		// Check if data is the right base currency and then check if it's already recorded
+		if quote == quoteAsset {
+			symbol, notRepeated = appendIfMissing(symbol, info.BaseAsset)
+			if notRepeated {
+				status = append(status, info.Status)
 			}
 		}
+	}
 
-		//Check status and append to symbols list if valid
-		for index, s := range status {
-			if s == "TRADING" {
-				tradingSymbols = append(tradingSymbols, symbol[index])
+	//Check status and append to symbols list if valid
+	for index, s := range status {
+		if allowedStatuses[s] {
+			tradingSymbols = append(tradingSymbols, symbol[index])
+		}
+	}
+
+	// Double check each new symbol is working as intended
+	valid := make([]bool, len(tradingSymbols))
+	probes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < maxConcurrentProbes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range probes {
+				_, err := client.Klines(context.Background(), tradingSymbols[i]+quoteAsset, "1m", 0, 0)
+				valid[i] = err == nil
 			}
+		}()
+	}
+	probed := 0
+	for i, s := range tradingSymbols {
+		if stored(s) {
+			valid[i] = true
+			continue
 		}
+		probed++
+		probes <- i
 	}
+	close(probes)
+	wg.Wait()
+	glog.Infof("Probed %d new of %d symbols", probed, len(tradingSymbols))
 
-	// Double check each symbol is working as intended
-	for _, s := range tradingSymbols {
-		_, err := client.NewKlinesService().Symbol(s + quoteAsset).Interval("1m").Do(context.Background())
-		if err == nil {
+	for i, s := range tradingSymbols {
+		if valid[i] {
 			validSymbols = append(validSymbols, s)
 		}
 	}
+	sort.Strings(validSymbols)
+	return validSymbols, nil
+}
 
-	return validSymbols
+// findLastTimestamp returns the time of the last candle in the bucket, or
+// zero if there is none
+func findLastTimestamp(symbol string, tbk *io.TimeBucketKey) (time.Time, error) {
+	return lastStoredTime(localStore{}, tbk)
 }
 
-func findLastTimestamp(symbol string, tbk *io.TimeBucketKey) time.Time {
-	cDir := executor.ThisInstance.CatalogDir
-	query := planner.NewQuery(cDir)
-	query.AddTargetKey(tbk)
-	start := time.Unix(0, 0).In(utils.InstanceConfig.Timezone)
-	end := time.Unix(math.MaxInt64, 0).In(utils.InstanceConfig.Timezone)
-	query.SetRange(start.Unix(), end.Unix())
-	query.SetRowLimit(io.LAST, 1)
-	parsed, err := query.Parse()
-	if err != nil {
-		return time.Time{}
+// klineEpoch returns the Epoch of the candle, its open time by default or
+// the end of the candle, one interval later, with the "close" epoch source
+func klineEpoch(rate *binance.Kline, epochSource string) int64 {
+	if epochSource == "close" {
+		// CloseTime is the last millisecond of the candle
+		return millisToEpochSec(rate.CloseTime + 1)
+	}
+	return millisToEpochSec(rate.OpenTime)
+}
+
+// ratesToColumnSeries converts the klines into an OHLCV ColumnSeries, returning
+// nil if there is nothing to write.  trimLast drops the last candle since it is
+// still being formed when polling live.  epochSource picks the candle time
+// written as Epoch, see klineEpoch.  onError is the on_error policy for
+// candles with unparsable values, only abort returns an error.
+func ratesToColumnSeries(rates []*binance.Kline, trimLast bool, epochSource, onError string) (*io.ColumnSeries, error) {
+	cs, _, err := buildColumnSeries(rates, trimLast, epochSource, onError, nil, false)
+	return cs, err
+}
+
+// buildColumnSeries is ratesToColumnSeries dropping the candles out of the
+// sanity bounds, and returns their number.  The candle trimmed as forming is
+// not counted.  tradeCount adds the TradeNum column after Volume.
+func buildColumnSeries(rates []*binance.Kline, trimLast bool, epochSource, onError string, bounds *sanityBounds, tradeCount bool) (*io.ColumnSeries, int, error) {
+	rejected := 0
+	epoch := make([]int64, 0)
+	open := make([]float64, 0)
+	high := make([]float64, 0)
+	low := make([]float64, 0)
+	close := make([]float64, 0)
+	volume := make([]float64, 0)
+	// closeTime := make([]int64, 0)
+	// quoteAssetVolume := make([]float64, 0)
+	tradeNum := make([]int64, 0)
+	// takerBuyBaseAssetVolume := make([]float64, 0)
+	// takerBuyQuoteAssetVolume := make([]float64, 0)
+	for i, rate := range rates {
+		// if nil, do not append to list
+		if rate.OpenTime == 0 || rate.Open == "" ||
+			rate.High == "" || rate.Low == "" ||
+			rate.Close == "" || rate.Volume == "" {
+			glog.Infof("No value in rate %v", rate)
+			continue
+		}
+		values, err := parseKline(rate, onError)
+		if err != nil {
+			if onError == onErrorAbort {
+				return nil, rejected, err
+			}
+			glog.Errorf("Skipping candle: %v", err)
+			continue
+		}
+		if v := bounds.violation(values); v != "" {
+			// the forming candle is dropped either way
+			if trimLast && i == len(rates)-1 {
+				trimLast = false
+				continue
+			}
+			glog.Warningf("Rejecting the candle at %d: %s", rate.OpenTime, v)
+			rejected++
+			continue
+		}
+		epoch = append(epoch, klineEpoch(rate, epochSource))
+		open = append(open, values[0])
+		high = append(high, values[1])
+		low = append(low, values[2])
+		close = append(close, values[3])
+		volume = append(volume, values[4])
+		// closeTime = append(closeTime, millisToEpochSec(rate.CloseTime))
+		tradeNum = append(tradeNum, rate.TradeNum)
+	}
+
+	if len(epoch) == 0 || len(open) == 0 || len(high) == 0 || len(low) == 0 || len(close) == 0 || len(volume) == 0 {
+		return nil, rejected, nil
 	}
-	reader, err := executor.NewReader(parsed)
-	csm, _, err := reader.Read()
-	cs := csm[*tbk]
-	if cs == nil || cs.Len() == 0 {
-		return time.Time{}
+
+	cs := io.NewColumnSeries()
+	// Remove last incomplete candle if it exists since that is incomplete
+	// Since all are the same length we can just check one
+	// We know that the last one on the list is the incomplete candle because in
+	// the gotCandle loop we only move on when the incomplete candle appears which is the last entry from the API
+	if trimLast && len(epoch) > 1 {
+		epoch = epoch[:len(epoch)-1]
+		open = open[:len(open)-1]
+		high = high[:len(high)-1]
+		low = low[:len(low)-1]
+		close = close[:len(close)-1]
+		volume = volume[:len(volume)-1]
+		// closeTime = closeTime[:len(closeTime)-1]
+		// quoteAssetVolume = quoteAssetVolume[:len(QuoteAssetVolume)-1]
+		tradeNum = tradeNum[:len(tradeNum)-1]
+		// takerBuyBaseAssetVolume = takerBuyBaseAssetVolume[:len(TakerBuyBaseAssetVolume)-1]
+		// takerBuyQuoteAssetVolume = takerBuyQuoteAssetVolume[:len(TakerBuyQuoteAssetVolume)-1]
+	}
+	for _, column := range []struct {
+		name string
+		data interface{}
+	}{
+		{"Epoch", epoch}, {"Open", open}, {"High", high}, {"Low", low}, {"Close", close}, {"Volume", volume},
+	} {
+		if err := cs.AddColumnChecked(column.name, column.data); err != nil {
+			return nil, rejected, err
+		}
 	}
-	ts := cs.GetTime()
-	return ts[0]
+	if tradeCount {
+		if err := cs.AddColumnChecked(tradeNumColumn, tradeNum); err != nil {
+			return nil, rejected, err
+		}
+	}
+	// cs.AddColumn("closeTime", closeTime)
+	// cs.AddColumn("quoteAssetVolume", quoteAssetVolume)
+	// cs.AddColumn("takerBuyBaseAssetVolume", takerBuyBaseAssetVolume)
+	// cs.AddColumn("takerBuyQuoteAssetVolume", takerBuyQuoteAssetVolume)
+	return cs, rejected, nil
 }
 
-// NewBgWorker registers a new background worker
+// configCheck is how far newFetcher goes with a config
+type configCheck int
+
+const (
+	// checkNone builds the worker to run it
+	checkNone configCheck = iota
+	// checkOffline validates the config without requests to the venue or
+	// the buckets, nor anything set up for the worker to run
+	checkOffline
+	// checkProbe validates the config, resolving the symbols and checking
+	// the schemas of their buckets, without anything set up for the worker
+	// to run
+	checkProbe
+)
+
+// NewBgWorker registers a new background worker, a BinanceFetcher or, with
+// several base_currencies, one for each of them
 func NewBgWorker(conf map[string]interface{}) (bgworker.BgWorker, error) {
+	workers, config, err := newWorkers(conf, checkNone)
+	if err != nil {
+		return nil, err
+	}
+	if config.StateAddr != "" {
+		if err := serveState(config.StateAddr, workers); err != nil {
+			return nil, err
+		}
+	}
+	if len(workers) == 1 {
+		return workers[0], nil
+	}
+	return workers, nil
+}
+
+// ValidateConfig runs the checks of NewBgWorker on conf without starting a
+// worker, e.g. before a rollout.  The symbols are not resolved from the venue
+// or the symbol_source, nor checked against their buckets, see ProbeConfig.
+func ValidateConfig(conf map[string]interface{}) error {
+	_, _, err := newWorkers(conf, checkOffline)
+	return err
+}
+
+// ProbeConfig is ValidateConfig, resolving the symbols from the venue or the
+// symbol_source and checking the schemas of their buckets as well
+func ProbeConfig(conf map[string]interface{}) error {
+	_, _, err := newWorkers(conf, checkProbe)
+	return err
+}
+
+// newWorkers returns the workers of the quotes of conf, along with the
+// config recast from it
+func newWorkers(conf map[string]interface{}, check configCheck) (quoteWorkers, *FetcherConfig, error) {
+	conf, err := expandEnv(conf)
+	if err != nil {
+		return nil, nil, err
+	}
 	config := recast(conf)
+	if config.BaseCurrency != "" && len(config.BaseCurrencies) > 0 {
+		return nil, nil, fmt.Errorf("base_currency and base_currencies cannot both be set")
+	}
+	quotes := config.BaseCurrencies
+	if len(quotes) == 0 {
+		quotes = []string{defaultBaseCurrency}
+		if config.BaseCurrency != "" {
+			quotes = []string{config.BaseCurrency}
+		}
+	}
+	if len(quotes) == 1 {
+		bn, err := newFetcher(conf, config, quotes[0], check)
+		if err != nil {
+			return nil, nil, err
+		}
+		return quoteWorkers{bn}, config, nil
+	}
+	workers := quoteWorkers{}
+	for _, quote := range quotes {
+		bn, err := newFetcher(conf, config, quote, check)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot collect the %s pairs: %v", quote, err)
+		}
+		workers = append(workers, bn)
+	}
+	return workers, config, nil
+}
+
+// newFetcher returns the worker of the pairs quoted in baseCurrency, or only
+// validates the config, depending on check
+func newFetcher(conf map[string]interface{}, config *FetcherConfig, baseCurrency string, check configCheck) (*BinanceFetcher, error) {
 	var queryStart time.Time
+	var queryEnd time.Time
 	timeframeStr := "1Min"
 	var symbols []string
-	baseCurrency := "BNB"
+	bucketNameTemplate := defaultBucketNameTemplate
+	venueName := defaultVenue
+	attributeGroup := defaultAttributeGroup
+	writeLatencyThreshold := time.Second
+	maxWriteDelay := time.Minute
 
 	if config.BaseTimeframe != "" {
 		timeframeStr = config.BaseTimeframe
 	}
-
-  // // Creslin - hard coding plugins to a quote currency ... names base in here. :/
-	// if config.BaseCurrency != "" {
-	// 	baseCurrency = config.BaseCurrency
-	// }
+	baseTimeframe, err := parseTimeframe(timeframeStr)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := toBinanceInterval(baseTimeframe)
+	if err != nil {
+		return nil, err
+	}
+	symbolTfs, symbolIntervals, err := symbolTimeframes(config, baseTimeframe)
+	if err != nil {
+		return nil, err
+	}
+	timeframeOf := func(symbol string) *utils.Timeframe {
+		if tf, ok := symbolTfs[symbol]; ok {
+			return tf
+		}
+		return baseTimeframe
+	}
 
 	if config.QueryStart != "" {
 		queryStart = queryTime(config.QueryStart)
 	}
 
+	if config.QueryEnd != "" {
+		queryEnd = queryTime(config.QueryEnd)
+	}
+
+	if config.Venue != "" {
+		venueName = config.Venue
+	}
+	v, ok := venues[venueName]
+	if !ok {
+		return nil, fmt.Errorf("unknown venue %q", venueName)
+	}
+	shared, err := getSharedClient(venueName, v, config.ProxyURL, config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	v = shared.venue
+
+	if config.BucketNameTemplate != "" {
+		bucketNameTemplate = config.BucketNameTemplate
+	}
+	if err := validateBucketNameTemplate(bucketNameTemplate); err != nil {
+		return nil, err
+	}
+
+	if config.AttributeGroup != "" {
+		attributeGroup = config.AttributeGroup
+	}
+	columns, err := selectColumns(config.Columns)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAttributeGroup(attributeGroup, columns, config.AttributeGroups); err != nil {
+		return nil, err
+	}
+
+	if config.WriteLatencyThreshold != "" {
+		d, err := time.ParseDuration(config.WriteLatencyThreshold)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid write_latency_threshold %q", config.WriteLatencyThreshold)
+		}
+		writeLatencyThreshold = d
+	}
+	if config.MaxWriteDelay != "" {
+		d, err := time.ParseDuration(config.MaxWriteDelay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid max_write_delay %q", config.MaxWriteDelay)
+		}
+		maxWriteDelay = d
+	}
+
+	var maxBackfill time.Duration
+	if config.MaxBackfill != "" {
+		d, err := parseDays(config.MaxBackfill)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid max_backfill %q", config.MaxBackfill)
+		}
+		maxBackfill = d
+	}
+
+	allowedStatuses := map[string]bool{"TRADING": true}
+	if len(config.AllowedStatuses) > 0 {
+		allowedStatuses = map[string]bool{}
+		for _, status := range config.AllowedStatuses {
+			allowedStatuses[status] = true
+		}
+	}
+
+	epochSource := "open"
+	if config.EpochSource != "" {
+		epochSource = config.EpochSource
+	}
+	if epochSource != "open" && epochSource != "close" {
+		return nil, fmt.Errorf("invalid epoch_source %q, must be open or close", epochSource)
+	}
+
+	verifySamples := defaultVerifySamples
+	if config.VerifySamples != 0 {
+		verifySamples = config.VerifySamples
+	}
+	verifyTolerance := defaultVerifyTolerance
+	if config.VerifyTolerance != 0 {
+		verifyTolerance = config.VerifyTolerance
+	}
+	if verifySamples < 0 || verifyTolerance < 0 {
+		return nil, fmt.Errorf("invalid verify_samples %d or verify_tolerance %v", verifySamples, verifyTolerance)
+	}
+
+	onError := onErrorSkip
+	if config.OnError != "" {
+		onError = config.OnError
+	}
+	switch onError {
+	case onErrorSkip, onErrorAbort, onErrorZero:
+	default:
+		return nil, fmt.Errorf("invalid on_error %q, must be skip, abort or zero", onError)
+	}
+
+	var statusInterval time.Duration
+	if config.StatusInterval != "" {
+		d, err := time.ParseDuration(config.StatusInterval)
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("invalid status_interval %q, must be at least 1m", config.StatusInterval)
+		}
+		statusInterval = d
+	}
+
+	backfillSleep := defaultBackfillSleep
+	if config.BackfillSleep != "" {
+		d, err := time.ParseDuration(config.BackfillSleep)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid backfill_sleep %q", config.BackfillSleep)
+		}
+		backfillSleep = d
+	}
+
+	var ticker24Interval time.Duration
+	if config.Ticker24Interval != "" {
+		d, err := time.ParseDuration(config.Ticker24Interval)
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("invalid ticker24_interval %q, must be at least 1m", config.Ticker24Interval)
+		}
+		ticker24Interval = d
+	}
+
+	mode := modeLoop
+	if config.Mode != "" {
+		mode = config.Mode
+	}
+	switch mode {
+	case modeLoop, modeOneshot:
+	case modeSchedule:
+		finest := baseTimeframe
+		for _, tf := range symbolTfs {
+			if tf.Duration < finest.Duration {
+				finest = tf
+			}
+		}
+		if finest.Duration < utils.Day {
+			glog.Warningf("The schedule mode applies to daily and coarser timeframes, polling %s candles instead", finest.String)
+			mode = modeLoop
+		}
+	default:
+		return nil, fmt.Errorf("invalid mode %q, must be loop, oneshot or schedule", mode)
+	}
+
+	st, err := newStore(config.RemoteEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// the workers of the venue share the limiter, the last one configured
+	// sets its warning fraction
+	limiter := shared.limiter
+	if config.WeightWarnFraction != 0 {
+		if config.WeightWarnFraction < 0 || config.WeightWarnFraction > 1 {
+			return nil, fmt.Errorf("invalid weight_warn_fraction %v, must be between 0 and 1", config.WeightWarnFraction)
+		}
+		limiter.setWarnFraction(config.WeightWarnFraction)
+	}
+	client := shared.client
+
 	//First see if config has symbols, if not retrieve all from binance as default
-	if len(config.Symbols) > 0 {
+	if len(config.Symbols) > 0 && config.SymbolSource != "" {
+		return nil, fmt.Errorf("symbols and symbol_source cannot both be set")
+	}
+	if config.Shard != nil {
+		if err := config.Shard.validate(); err != nil {
+			return nil, err
+		}
+	}
+	// the symbols of the venue or the symbol_source are not resolved
+	// offline
+	resolved := len(config.Symbols) > 0 || check != checkOffline
+	switch {
+	case len(config.Symbols) > 0:
 		symbols = config.Symbols
-	} else {
-		symbols = getAllSymbols(baseCurrency)
+	case !resolved:
+	case config.SymbolSource != "" && config.SymbolSource != symbolSourceAPI:
+		if symbols, err = loadSymbols(st, config.SymbolSource); err != nil {
+			return nil, err
+		}
+	default:
+		symbols, err = getAllSymbols(v, client, baseCurrency, allowedStatuses, func(symbol string) bool {
+			name := expandBucketName(bucketNameTemplate, v.bucketPrefix, symbol, baseCurrency)
+			last, err := lastStoredTime(st, candleKey(name, timeframeOf(symbol), attributeGroup))
+			return err == nil && !last.IsZero()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Shard != nil && resolved {
+		symbols = shardSymbols(symbols, config.Shard)
+		if len(symbols) == 0 {
+			return nil, fmt.Errorf("no symbols in shard %d of %d", config.Shard.Index, config.Shard.Count)
+		}
+		glog.Infof("Fetching %d symbols of shard %d of %d", len(symbols), config.Shard.Index, config.Shard.Count)
+	}
+	if resolved {
+		warnMaxSymbols(len(symbols), config.MaxSymbols, config.Shard)
+	}
+
+	bn := &BinanceFetcher{
+		config:             conf,
+		store:              st,
+		baseCurrency:       baseCurrency,
+		symbols:            symbols,
+		queryStart:         queryStart,
+		queryEnd:           queryEnd,
+		baseTimeframe:      baseTimeframe,
+		interval:           interval,
+		venue:              v,
+		client:             client,
+		bucketNameTemplate: bucketNameTemplate,
+		attributeGroup:     attributeGroup,
+		backpressure:       newWriteBackpressure(writeLatencyThreshold, maxWriteDelay, 10),
+		allowUpdates:       config.AllowUpdates,
+		maxBackfill:        maxBackfill,
+		allowedStatuses:    allowedStatuses,
+		epochSource:        epochSource,
+		onError:            onError,
+		verifyEnabled:      config.Verify,
+		verifySamples:      verifySamples,
+		verifyTolerance:    verifyTolerance,
+		paused:             map[string]bool{},
+		lastWritten:        map[string]int64{},
+		collectionStatuses: map[string]*collectionStatus{},
+		statusInterval:     statusInterval,
+		backfillSleep:      backfillSleep,
+		ticker24Interval:   ticker24Interval,
+		backfills:          make(chan backfillRequest, maxQueuedBackfills),
+		limiter:            limiter,
+		symbolTimeframes:   symbolTfs,
+		symbolIntervals:    symbolIntervals,
+	}
+	if check == checkNone {
+		bn.shutdown = watchShutdown()
+	}
+	bn.finishPassOnShutdown = config.FinishPassOnShutdown
+	bn.mode = mode
+	if config.CatchupTolerance < 0 {
+		return nil, fmt.Errorf("invalid catchup_tolerance %d", config.CatchupTolerance)
+	}
+	bn.catchupTolerance = config.CatchupTolerance
+	bn.columns = columns
+	bn.includeTradeCount = config.IncludeTradeCount
+	if config.AppendOnly && config.AllowUpdates {
+		return nil, fmt.Errorf("append_only and allow_updates cannot be set together")
+	}
+	bn.appendOnly = config.AppendOnly
+	if bn.transforms, bn.transformColumns, err = selectTransforms(config.Transforms, columns); err != nil {
+		return nil, err
+	}
+	if err := checkCompressionHints(config.CompressionHints, bn.storedColumns()); err != nil {
+		return nil, err
+	}
+	bn.compressionHints = config.CompressionHints
+	bn.writeOnlyOnChange = config.WriteOnlyOnChange
+	bn.lastRows = map[string]writtenRow{}
+	bn.caughtUpEvents = config.CaughtUpEvents
+	bn.caughtUp = map[string]bool{}
+	if config.Snapshot {
+		if err := checkSnapshotColumns(columns); err != nil {
+			return nil, err
+		}
+	}
+	bn.snapshot = config.Snapshot
+	maintenanceSleep, err := maintenanceConfig(config, bn.venue)
+	if err != nil {
+		return nil, err
+	}
+	bn.checkSystemStatus = config.SystemStatus
+	bn.maintenanceSleep = maintenanceSleep
+	if bn.fundingInterval, err = fundingConfig(config, bn.venue); err != nil {
+		return nil, err
+	}
+	if config.WindowRetries < 0 {
+		return nil, fmt.Errorf("invalid window_retries %d", config.WindowRetries)
+	}
+	bn.windowRetries = config.WindowRetries
+	bn.windowFailures = map[string]int{}
+	bn.gaps = map[string][]gap{}
+	bn.adaptiveSpan = config.AdaptiveSpan
+	bn.spans = map[string]int{}
+	bn.fetchedThrough = map[string]time.Time{}
+	if config.Staging {
+		if bn.queryEnd.IsZero() && bn.mode != modeOneshot {
+			return nil, fmt.Errorf("staging requires query_end or the oneshot mode")
+		}
+		if config.RemoteEndpoint != "" {
+			return nil, fmt.Errorf("staging is not supported with remote_endpoint")
+		}
+	}
+	bn.staging = config.Staging
+	if config.CompleteDaysOnly && bn.baseTimeframe.Duration != utils.Day {
+		return nil, fmt.Errorf("complete_days_only requires the 1D base_timeframe, not %s", bn.baseTimeframe.String)
+	}
+	if config.CompleteDaysOnly && len(symbolTfs) > 0 {
+		return nil, fmt.Errorf("complete_days_only and symbol_timeframes cannot both be set")
+	}
+	bn.completeDaysOnly = config.CompleteDaysOnly
+	if config.CloseDelay != "" {
+		d, err := time.ParseDuration(config.CloseDelay)
+		if err != nil || d < 0 || d >= bn.passTimeframe().Duration {
+			return nil, fmt.Errorf("invalid close_delay %q, must be shorter than the timeframe", config.CloseDelay)
+		}
+		bn.closeDelay = d
+	}
+	span, step := defaultRequestSpan, defaultAdvanceStep
+	if config.RequestSpan != 0 {
+		span = config.RequestSpan
+	}
+	if config.AdvanceStep != 0 {
+		step = config.AdvanceStep
+	}
+	if span <= 0 || span >= defaultKlinesLimit {
+		return nil, fmt.Errorf("invalid request_span %d, must be between 1 and %d", span, defaultKlinesLimit-1)
+	}
+	if step <= 0 || step > span {
+		return nil, fmt.Errorf("invalid advance_step %d, must be between 1 and request_span %d", step, span)
+	}
+	// with symbol_timeframes, in intervals of the finest timeframe
+	bn.requestSpan = time.Duration(span) * bn.passTimeframe().Duration
+	bn.advanceStep = time.Duration(step) * bn.passTimeframe().Duration
+	bn.requestTimeout = defaultRequestTimeout
+	if config.RequestTimeout != "" {
+		d, err := time.ParseDuration(config.RequestTimeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid request_timeout %q", config.RequestTimeout)
+		}
+		bn.requestTimeout = d
+	}
+	bn.clock = realClock{}
+	if config.CorrectClockDrift {
+		bn.clock = &driftClock{clock: realClock{}}
+	}
+	if config.QuietIntervals < 0 {
+		return nil, fmt.Errorf("invalid quiet_intervals %d", config.QuietIntervals)
+	}
+	bn.quietIntervals = config.QuietIntervals
+	bn.quarantineQuiet = config.QuarantineQuiet
+	bn.backwardFill = config.BackfillBackward
+	bn.writeRetries = defaultWriteRetries
+	if config.WriteRetries != 0 {
+		bn.writeRetries = config.WriteRetries
+	}
+	if bn.writeRetries < 0 {
+		return nil, fmt.Errorf("invalid write_retries %d", config.WriteRetries)
+	}
+	bn.onWriteError = onErrorAbort
+	if config.OnWriteError != "" {
+		bn.onWriteError = config.OnWriteError
+	}
+	switch bn.onWriteError {
+	case onErrorSkip, onErrorAbort:
+	default:
+		return nil, fmt.Errorf("invalid on_write_error %q, must be skip or abort", bn.onWriteError)
+	}
+	bn.bookTicker = config.BookTicker
+	bn.reload = reloadSkip
+	if config.Reload != "" {
+		bn.reload = config.Reload
+	}
+	switch bn.reload {
+	case reloadSkip, reloadDedup, reloadOverwrite:
+	default:
+		return nil, fmt.Errorf("invalid reload %q, must be skip, dedup or overwrite", bn.reload)
+	}
+	if config.ProgressInterval != "" {
+		d, err := time.ParseDuration(config.ProgressInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid progress_interval %q", config.ProgressInterval)
+		}
+		bn.progress.interval = d
+	}
+	bn.clockDriftWarn = defaultClockDriftWarn
+	if config.ClockDriftWarn != "" {
+		d, err := time.ParseDuration(config.ClockDriftWarn)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid clock_drift_warn %q", config.ClockDriftWarn)
+		}
+		bn.clockDriftWarn = d
+	}
+	bn.backfillOpenEnded = config.BackfillOpenEnded
+	if config.SanityMaxValue < 0 {
+		return nil, fmt.Errorf("invalid sanity_max_value %v", config.SanityMaxValue)
+	}
+	bn.sanity = sanityBounds{
+		nonPositive:  config.SanityRejectNonPositive,
+		highBelowLow: !config.SanityAllowHighBelowLow,
+		maxValue:     config.SanityMaxValue,
+	}
+	bn.symbolStarts = map[string]time.Time{}
+	for symbol, str := range config.SymbolStarts {
+		start := queryTime(str)
+		if start.IsZero() {
+			return nil, fmt.Errorf("invalid symbol_starts time %q of %s", str, symbol)
+		}
+		known := false
+		for _, s := range symbols {
+			known = known || s == symbol
+		}
+		if !known && resolved {
+			glog.Warningf("%s of symbol_starts is not fetched by this worker", symbol)
+		}
+		bn.symbolStarts[symbol] = start
+	}
+	if config.AuditSpread != 0 {
+		maxBytes := int64(defaultAuditMaxBytes)
+		if config.AuditMaxBytes != 0 {
+			maxBytes = config.AuditMaxBytes
+		}
+		if bn.audit, err = newAuditLog(config.AuditFile, config.AuditSpread, maxBytes); err != nil {
+			return nil, err
+		}
+	}
+	if bn.statusRefresh.interval, bn.statusRefresh.minInterval, err = statusRefreshConfig(config); err != nil {
+		return nil, err
+	}
+	if config.CacheDir != "" {
+		maxBytes := int64(defaultCacheMaxBytes)
+		if config.CacheMaxBytes != 0 {
+			maxBytes = config.CacheMaxBytes
+		}
+		if bn.cache, err = newKlineCache(config.CacheDir, maxBytes); err != nil {
+			return nil, err
+		}
+	}
+	if config.CheckpointFile != "" {
+		interval, err := checkpointInterval(config)
+		if err != nil {
+			return nil, err
+		}
+		if check == checkNone {
+			path := checkpointPath(config.CheckpointFile, bn.baseCurrency, bn.baseTimeframe.String)
+			bn.checkpointer = newCheckpointer(path, interval, bn.baseCurrency, bn.baseTimeframe.String)
+			bn.restoreCheckpoint()
+		}
+	}
+	if check != checkOffline {
+		if err := bn.checkSchemas(config.SkipSchemaMismatch); err != nil {
+			return nil, err
+		}
+		bn.warnSiblingTimeframes()
+	}
+	if config.LeaseTTL != "" {
+		d, err := time.ParseDuration(config.LeaseTTL)
+		if err != nil || d < 3*time.Second {
+			return nil, fmt.Errorf("invalid lease_ttl %q, must be at least 3s", config.LeaseTTL)
+		}
+		bn.leaseTTL = d
+	}
+	if check != checkNone {
+		return bn, nil
+	}
+	limiter.addObservers(func(used, remaining int) {
+		setGauge(bn.metricKey("weight_used"), int64(used))
+		setGauge(bn.metricKey("weight_remaining"), int64(remaining))
+	}, func(used int) {
+		setGauge(bn.metricKey("venue_weight_used"), int64(used))
+	})
+	bn.publishLimiterState()
+	if bn.leaseTTL > 0 {
+		bn.leaseOwner = newLeaseOwner()
+		// the leases are acquired by Run once the executor is
+		if err := bn.acquireLeases(bn.clock.Now()); err != nil && err != errExecutorNotInitialized {
+			return nil, err
+		}
 	}
+	registerBackfills(bn)
+	registerControl(bn)
+	if bn.audit != nil {
+		registerAudit(bn)
+	}
+	return bn, nil
+}
 
-	return &BinanceFetcher{
-		config:        conf,
-		baseCurrency:  baseCurrency,
-		symbols:       symbols,
-		queryStart:    queryStart,
-		baseTimeframe: utils.NewTimeframe(timeframeStr),
-	}, nil
+// capBackfill moves start forward to max_backfill before now, skipping
+// older gaps with a warning
+func (bn *BinanceFetcher) capBackfill(start, now time.Time) time.Time {
+	if bn.maxBackfill <= 0 {
+		return start
+	}
+	oldest := now.Add(-bn.maxBackfill)
+	if start.Before(oldest) {
+		glog.Warningf("Skipping data from %v to %v beyond max_backfill %v", start, oldest, bn.maxBackfill)
+		return oldest
+	}
+	return start
+}
+
+// skipStored returns the time of the last stored candle of symbol if it is
+// later than start and the reload policy is skip, so that the candles before
+// it are not fetched again.  The last one is, as it may have been forming.
+func (bn *BinanceFetcher) skipStored(symbol string, start time.Time) time.Time {
+	if bn.reload != reloadSkip {
+		return start
+	}
+	bn.mu.Lock()
+	last, ok := bn.lastWritten[symbol]
+	bn.mu.Unlock()
+	if ok && start.Unix() < last {
+		return time.Unix(last, 0).UTC()
+	}
+	return start
+}
+
+// symbolStart returns the later of start and the symbol_starts time of
+// symbol, if it has one
+func (bn *BinanceFetcher) symbolStart(symbol string, start time.Time) time.Time {
+	if override, ok := bn.symbolStarts[symbol]; ok && start.Before(override) {
+		return override
+	}
+	return start
+}
+
+// toBinanceInterval returns the Binance kline interval of the timeframe, or
+// an error if Binance does not serve candles of that timeframe
+func toBinanceInterval(tf *utils.Timeframe) (string, error) {
+	re := regexp.MustCompile("[0-9]+")
+	re2 := regexp.MustCompile("[a-zA-Z]+")
+	timeIntervalLettersOnly := re.ReplaceAllString(tf.String, "")
+	timeIntervalNumsOnly := re2.ReplaceAllString(tf.String, "")
+	interval := timeIntervalNumsOnly + suffixBinanceDefs[timeIntervalLettersOnly]
+	for _, i := range binanceIntervals {
+		if interval == i {
+			return interval, nil
+		}
+	}
+	return "", fmt.Errorf("base_timeframe %s has no Binance interval, must be one of %s",
+		tf.String, strings.Join(binanceIntervals, ", "))
+}
+
+// binanceInterval returns the Binance kline interval of the base timeframe
+func (bn *BinanceFetcher) binanceInterval() string {
+	return bn.interval
+}
+
+// write writes the candles of the symbol that are not stored yet, see
+// filterWritten, and slows down while the storage engine is under pressure.
+// The writes of a symbol are serialized, those of different symbols are not.
+func (bn *BinanceFetcher) write(symbol string, cs *io.ColumnSeries, fillGaps bool) error {
+	if err := bn.store.ready(); err != nil {
+		return err
+	}
+	tbk := bn.tbkFor(symbol)
+	if len(bn.columns) < len(klineColumns) {
+		if err := cs.Project(append([]string{"Epoch"}, bn.writtenColumns()...)); err != nil {
+			return err
+		}
+	}
+	if !fillGaps && bn.unchangedWrite(symbol, cs) {
+		return nil
+	}
+	cs, err := bn.applyTransforms(symbol, cs)
+	if err != nil {
+		return err
+	}
+	unlock := bn.symbolWrites.lock(symbol)
+	cs, err = bn.filterWritten(symbol, tbk, cs, fillGaps)
+	if err != nil || cs.Len() == 0 {
+		unlock()
+		return err
+	}
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	writeStart := bn.clock.Now()
+	if err := bn.storeWithRetries(symbol, csm); err != nil {
+		unlock()
+		return err
+	}
+	epoch := cs.GetEpoch()
+	bn.mu.Lock()
+	if last := epoch[len(epoch)-1]; last > bn.lastWritten[symbol] {
+		bn.lastWritten[symbol] = last
+	}
+	bn.collectionStatus(symbol).rowsWritten += int64(cs.Len())
+	bn.mu.Unlock()
+	bn.recordLastRow(symbol, cs)
+	unlock()
+	bn.checkCaughtUp(symbol, epoch[len(epoch)-1])
+
+	delay := bn.backpressure.observe(bn.clock.Now().Sub(writeStart))
+	setGauge(bn.metricKey("write_delay_ms"), int64(delay/time.Millisecond))
+	if delay > 0 {
+		bn.clock.Sleep(delay)
+	}
+	return nil
 }
 
 // Run grabs data in intervals from starting time to ending time.
-// If query_end is not set, it will run forever.
+// If query_end is not set, it will run forever, unless the mode is oneshot.
 func (bn *BinanceFetcher) Run() {
 	symbols := bn.symbols
-	client := binance.NewClient("", "")
 	timeStart := time.Time{}
-	baseCurrency := bn.baseCurrency
-	slowDown := false
+	state := phaseBackfill
 
-	// Get correct Time Interval for Binance
-	originalInterval := bn.baseTimeframe.String
-	re := regexp.MustCompile("[0-9]+")
-	re2 := regexp.MustCompile("[a-zA-Z]+")
-	timeIntervalLettersOnly := re.ReplaceAllString(originalInterval, "")
-	timeIntervalNumsOnly := re2.ReplaceAllString(originalInterval, "")
-	correctIntervalSymbol := suffixBinanceDefs[timeIntervalLettersOnly]
-	if len(correctIntervalSymbol) <= 0 {
-		glog.Errorf("Interval Symbol Format Incorrect. Setting to time interval to default '1Min'")
-		correctIntervalSymbol = "1Min"
-	}
-	timeInterval := timeIntervalNumsOnly + correctIntervalSymbol
-
-	// Get last timestamp collected
-	for _, symbol := range symbols {
-		tbk := io.NewTimeBucketKey("BINANCE_BNB_" + symbol + "/" + bn.baseTimeframe.String + "/OHLCV")
-		lastTimestamp := findLastTimestamp(symbol, tbk)
+	// Get correct Time Interval for Binance, that of the finest timeframe
+	// with symbol_timeframes
+	timeInterval := bn.passInterval()
+	passTimeframe := bn.passTimeframe()
+
+	if err := bn.store.ready(); err != nil {
+		glog.Errorf("Cannot run the Binance fetcher: %v", err)
+		return
+	}
+	if bn.leaseTTL > 0 {
+		if err := bn.acquireLeases(bn.clock.Now()); err != nil {
+			glog.Errorf("Cannot run the Binance fetcher: %v", err)
+			return
+		}
+		stop, released := make(chan struct{}), make(chan struct{})
+		go bn.holdLeases(stop, released)
+		defer func() {
+			close(stop)
+			<-released
+		}()
+	}
+	bn.serveOnce.Do(func() { go bn.serveBackfills() })
+	// the checkpoint is written once more however Run returns
+	defer func() { bn.saveCheckpoint(bn.clock.Now().UTC(), true) }()
+
+	// Get last timestamp collected.  resumeFrom is the earliest of them, zero
+	// if a symbol has none.
+	resumeFrom := time.Time{}
+	for i, symbol := range symbols {
+		tbk := bn.tbkFor(symbol)
+		lastTimestamp, err := lastStoredTime(bn.store, tbk)
+		if err != nil {
+			glog.Errorf("Cannot read the last timestamp of %s: %v", symbol, err)
+			return
+		}
 		glog.Infof("lastTimestamp for %s = %v", symbol, lastTimestamp)
+		bn.checkCheckpoint(symbol, lastTimestamp)
+		if !lastTimestamp.IsZero() {
+			bn.mu.Lock()
+			bn.lastWritten[symbol] = lastTimestamp.Unix()
+			bn.mu.Unlock()
+		}
+		// a symbol with a start time resumes from it at the earliest
+		lastTimestamp = bn.symbolStart(symbol, lastTimestamp)
 		if timeStart.IsZero() || (!lastTimestamp.IsZero() && lastTimestamp.Before(timeStart)) {
 			timeStart = lastTimestamp
 		}
+		if i == 0 || (!resumeFrom.IsZero() && lastTimestamp.Before(resumeFrom)) {
+			resumeFrom = lastTimestamp
+		}
+	}
+
+	// the oneshot mode stops at the candles closed when it starts
+	runStart := bn.clock.Now().UTC()
+	if bn.mode == modeOneshot && (bn.queryEnd.IsZero() || bn.queryEnd.After(runStart)) {
+		bn.queryEnd = runStart
+	}
+	if bn.completeDaysOnly && !bn.queryEnd.IsZero() {
+		bn.queryEnd = bn.completeDaysEnd(runStart)
+	}
+
+	// without query_end the fetcher never finishes, so the data stored by
+	// earlier runs is verified instead
+	if bn.verifyEnabled && bn.queryEnd.IsZero() {
+		bn.verify(rand.New(rand.NewSource(bn.clock.Now().UnixNano())))
 	}
 
-	// Set start time if not given.
-	if !bn.queryStart.IsZero() {
+	if bn.mode == modeSchedule {
+		bn.runSchedule()
+		return
+	}
+
+	if bn.backwardFill && !bn.queryStart.IsZero() {
+		bn.backfillBackward(symbols)
+	}
+
+	// Set start time if not given.  The oneshot mode resumes from the last
+	// stored candles, and so does query_start with the skip reload policy.
+	resume := !resumeFrom.IsZero() && (bn.mode == modeOneshot || !bn.queryStart.IsZero()) &&
+		(bn.queryStart.IsZero() || (bn.reload == reloadSkip && resumeFrom.After(bn.queryStart)))
+	if resume {
+		timeStart = resumeFrom
+	} else if !bn.queryStart.IsZero() {
 		timeStart = bn.queryStart
 	} else {
-		timeStart = time.Now().UTC().Add(-bn.baseTimeframe.Duration)
+		timeStart = bn.clock.Now().UTC().Add(-passTimeframe.Duration)
+	}
+	timeStart = bn.capBackfill(timeStart, bn.clock.Now().UTC())
+	bn.startProgress(timeStart)
+	if bn.backfillOpenEnded {
+		frontier, ok := bn.backfillForward(symbols, timeStart, runStart)
+		if !ok {
+			return
+		}
+		if !frontier.IsZero() {
+			timeStart = frontier
+		}
+		if !bn.queryEnd.IsZero() && timeStart.After(bn.queryEnd) {
+			timeStart = bn.queryEnd
+		}
 	}
 
 	// For loop for collecting candlestick data forever
@@ -302,25 +1881,47 @@ func (bn *BinanceFetcher) Run() {
 	var originalTimeEndZero time.Time
 	var waitTill time.Time
 	firstLoop := true
+	// refetch is set by the writes dropped in the pass
+	refetch := false
 
 	for {
-		// finalTime = time.Now().UTC()
+		// a paused worker continues from the last stored candles
+		waited, ok := bn.waitResumed()
+		if !ok {
+			glog.Infof("Shutting down while paused")
+			return
+		}
+		if waited {
+			if from := bn.resumePoint(symbols); !from.IsZero() {
+				timeStart, timeEnd = from, time.Time{}
+				state, firstLoop = phaseBackfill, true
+			}
+		}
+		// finalTime = bn.clock.Now().UTC()
+		bn.refreshStatuses(bn.clock.Now().UTC())
+		if !bn.waitMaintenance() {
+			glog.Infof("Shutting down during the exchange maintenance")
+			return
+		}
 		originalTimeStart = timeStart
 		originalTimeEnd = timeEnd
 
-		// Check if it's finished backfilling. If not, just do 300 * Timeframe.duration
-		// only do beyond 1st loop
-		if !slowDown {
+		// Check if it's finished backfilling. If not, just do request_span
+		// after advancing by advance_step, only beyond 1st loop
+		if state == phaseBackfill {
 			if !firstLoop {
-				timeStart = timeStart.Add(bn.baseTimeframe.Duration * 300)
-				timeEnd = timeStart.Add(bn.baseTimeframe.Duration * 300)
+				timeStart = timeStart.Add(bn.advanceStep)
+				timeEnd = timeStart.Add(bn.requestSpan)
 			} else {
 				firstLoop = false
 				// Keep timeStart as original value
-				timeEnd = timeStart.Add(bn.baseTimeframe.Duration * 300)
+				timeEnd = timeStart.Add(bn.requestSpan)
 			}
-			if timeEnd.After(time.Now().UTC()) {
-				slowDown = true
+			if !bn.queryEnd.IsZero() && timeEnd.After(bn.queryEnd) {
+				timeEnd = bn.queryEnd
+			}
+			if state = bn.nextPhase(state, timeEnd, bn.clock.Now().UTC()); state == phaseLive {
+				glog.Infof("Caught up to %v, fetching the candles as they close", timeStart)
 			}
 		} else {
 			// Set to the :00 of previous TimeEnd to ensure that the complete candle that was not formed before is written
@@ -331,44 +1932,36 @@ func (bn *BinanceFetcher) Run() {
 		// Otherwise continue to call every second to backfill the data
 		// Slow Down for 1 Duration period
 		// Make sure last candle is formed
-		if slowDown {
-			timeEnd = time.Now().UTC()
-			timeStart = originalTimeEnd
-
-			year := timeEnd.Year()
-			month := timeEnd.Month()
-			day := timeEnd.Day()
-			hour := timeEnd.Hour()
-			minute := timeEnd.Minute()
+		if state == phaseLive {
+			timeEnd = bn.clock.Now().UTC()
+			// the first pass turns live without a previous pass to resume from
+			if !originalTimeEnd.IsZero() {
+				timeStart = originalTimeEnd
+			}
 
 			// To prevent gaps (ex: querying between 1:31 PM and 2:32 PM (hourly)would not be ideal)
 			// But we still want to wait 1 candle afterwards (ex: 1:01 PM (hourly))
 			// If it is like 1:59 PM, the first wait sleep time will be 1:59, but afterwards would be 1 hour.
 			// Main goal is to ensure it runs every 1 <time duration> at :00
-			switch originalInterval {
-			case "1Min":
-				timeEnd = time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
-			case "1H":
-				timeEnd = time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
-			case "1D":
-				timeEnd = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-			default:
-				glog.Infof("Incorrect format: %v", originalInterval)
-			}
-			waitTill = timeEnd.Add(bn.baseTimeframe.Duration)
+			timeEnd = candleOpen(timeEnd, passTimeframe)
+			waitTill = candleClose(timeEnd, passTimeframe)
 
-			timeStartM := timeStart.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
-			timeEndM := timeEnd.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
+			timeStartM = timeToMillis(timeStart)
+			timeEndM = timeToMillis(timeEnd)
 
 			// Make sure you get the last candle within the timeframe.
 			// If the next candle is in the API call, that means the previous candle has been fully formed
 			// (ex: if we see :00 is formed that means the :59 candle is fully formed)
 			gotCandle := false
 			for !gotCandle {
-				rates, err := client.NewKlinesService().Symbol(symbols[0] + baseCurrency).Interval(timeInterval).StartTime(timeStartM).Do(context.Background())
+				rates, err := bn.klines(context.Background(), symbols[0], timeInterval, timeStartM, 0)
 				if err != nil {
+					if classifyError(err) == actionAbort {
+						glog.Errorf("Stopping, the request of %s was rejected: %v", symbols[0], err)
+						return
+					}
 					glog.Errorf("Response error: %v", err)
-					time.Sleep(time.Minute)
+					bn.clock.Sleep(time.Minute)
 				}
 
 				if len(rates) > 0 && rates[len(rates)-1].OpenTime-timeEndM >= 0 {
@@ -376,119 +1969,144 @@ func (bn *BinanceFetcher) Run() {
 				}
 			}
 
+			// the pass requests the same window as the probe, up to the
+			// candle forming at timeEnd, which is trimmed
 			originalTimeEndZero = timeEnd
-			// Change timeEnd to the correct time where the last candle is formed
-			timeEnd = time.Now().UTC()
 		}
 
-		// Repeat since slowDown loop won't run if it hasn't been past the current time
-		timeStartM = timeStart.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
-		timeEndM = timeEnd.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
-
-		for _, symbol := range symbols {
+		refetch = false
+		bn.setPhase(state)
+		for i, symbol := range symbols {
+			// the write of the previous symbol is complete
+			if !bn.finishPassOnShutdown && bn.shuttingDown() {
+				glog.Warningf("Shutting down with %d of %d symbols not fetched in this pass", len(symbols)-i, len(symbols))
+				return
+			}
+			if bn.paused[symbol] {
+				continue
+			}
+			// the window follows the timeframe of the symbol
+			windowStart, windowEnd, ok := bn.symbolWindow(symbol, passTimeframe, timeStart, timeEnd, state == phaseLive)
+			if !ok {
+				continue
+			}
+			// a symbol is not requested before its start time, nor its
+			// stored candles while catching up
+			start, end := bn.symbolStart(symbol, windowStart), windowEnd
+			if state == phaseBackfill {
+				start = bn.skipGaps(symbol, bn.skipStored(symbol, start))
+				start, end = bn.adaptiveWindow(symbol, start, end)
+			}
+			if start.After(windowStart) && !start.Before(windowEnd) {
+				continue
+			}
+			symbolStartM, symbolEndM := timeToMillis(start), timeToMillis(end)
 			// glog.Infof("Requesting %s %v - %v", symbol, timeStart, timeEnd)
-			rates, err := client.NewKlinesService().Symbol(symbol + baseCurrency).Interval(timeInterval).StartTime(timeStartM).EndTime(timeEndM).Do(context.Background())
+			rates, err := bn.cachedKlines(context.Background(), symbol, bn.intervalOf(symbol), symbolStartM, symbolEndM)
+			bn.recordFetch(symbol, bn.clock.Now().UTC(), err)
 			if err != nil {
-				glog.Errorf("Response error: %v", err)
-				glog.Infof("Problematic symbol %s", symbol)
-				time.Sleep(time.Minute)
+				if !bn.handleFetchError(symbol, err) {
+					return
+				}
+				// a window failing again and again is left as a gap
+				if state == phaseBackfill && !bn.paused[symbol] && bn.windowFailed(symbol, time.Unix(0, symbolStartM*int64(time.Millisecond)).UTC(), end) {
+					continue
+				}
 				// Go back to last time
 				timeStart = originalTimeStart
 				continue
 			}
-			// if len(rates) == 0 {
-			// 	glog.Info("len(rates) == 0")
-			// 	continue
-			// }
-			openTime := make([]int64, 0)
-			open := make([]float64, 0)
-			high := make([]float64, 0)
-			low := make([]float64, 0)
-			close := make([]float64, 0)
-			volume := make([]float64, 0)
-			// closeTime := make([]int64, 0)
-			// quoteAssetVolume := make([]float64, 0)
-			// tradeNum := make([]int64, 0)
-			// takerBuyBaseAssetVolume := make([]float64, 0)
-			// takerBuyQuoteAssetVolume := make([]float64, 0)
-			for _, rate := range rates {
-				errorsConversion = errorsConversion[:0]
-				// if nil, do not append to list
-				if rate.OpenTime != 0 && rate.Open != "" &&
-					rate.High != "" && rate.Low != "" &&
-					rate.Close != "" && rate.Volume != "" {
-					openTime = append(openTime, convertMillToTime(rate.OpenTime).Unix())
-					open = append(open, convertStringToFloat(rate.Open))
-					high = append(high, convertStringToFloat(rate.High))
-					low = append(low, convertStringToFloat(rate.Low))
-					close = append(close, convertStringToFloat(rate.Close))
-					volume = append(volume, convertStringToFloat(rate.Volume))
-  				// closeTime = append(closeTime, convertMillToTime(rate.CloseTime).Unix())
-  				// quoteAssetVolume = append(quoteAssetVolume, convertStringToFloat(rate.QuoteAssetVolume))
-  				// tradeNum = append(tradeNum, rate.TradeNum)
-  				// takerBuyBaseAssetVolume  = append(takerBuyBaseAssetVolume, convertStringToFloat(rate.TakerBuyBaseAssetVolume))
-  				// takerBuyQuoteAssetVolume  = append(takerBuyQuoteAssetVolume, convertStringToFloat(rate.TakerBuyQuoteAssetVolume))
-					for _, e := range errorsConversion {
-						if e != nil {
-							return
-						}
+			bn.transientErrors = 0
+			bn.windowFetched(symbol)
+			if state == phaseLive {
+				bn.trackQuiet(symbol, len(rates) == 0)
+			}
+			fetched := rates
+			if bn.mode == modeOneshot {
+				rates, _ = closedRates(rates, runStart)
+			}
+			// Remove last incomplete candle when polling live
+			trimLast := state == phaseLive
+			if bn.completeDaysOnly {
+				// the candle of the current day is dropped, forming or not
+				rates, _ = closedRates(rates, bn.completeDaysEnd(bn.clock.Now().UTC()))
+				trimLast = false
+			}
+			cs, err := bn.toColumnSeries(symbol, rates, trimLast)
+			if err != nil {
+				glog.Errorf("Stopping on the conversion error for %s: %v", symbol, err)
+				return
+			}
+			// if data is nil, do not write to csm
+			if cs != nil {
+				if err := bn.write(symbol, cs, false); err != nil {
+					if !bn.writeFailed(symbol, err) {
+						return
 					}
-				} else {
-					glog.Infof("No value in rate %v", rate)
+					refetch = true
+					continue
 				}
 			}
-
-			validWriting := true
-			if len(openTime) == 0 || len(open) == 0 || len(high) == 0 || len(low) == 0 || len(close) == 0 || len(volume) == 0 {
-				validWriting = false
+			if state == phaseBackfill {
+				bn.learnSpan(symbol, time.Unix(0, symbolStartM*int64(time.Millisecond)).UTC(), end, fetched)
 			}
-			// if data is nil, do not write to csm
-			if validWriting {
-				cs := io.NewColumnSeries()
-				// Remove last incomplete candle if it exists since that is incomplete
-				// Since all are the same length we can just check one
-				// We know that the last one on the list is the incomplete candle because in
-				// the gotCandle loop we only move on when the incomplete candle appears which is the last entry from the API
-				if slowDown && len(openTime) > 1 {
-					openTime = openTime[:len(openTime)-1]
-					open = open[:len(open)-1]
-					high = high[:len(high)-1]
-					low = low[:len(low)-1]
-					close = close[:len(close)-1]
-					volume = volume[:len(volume)-1]
-  				// closeTime = closeTime[:len(closeTime)-1]
-  				// quoteAssetVolume = quoteAssetVolume[:len(QuoteAssetVolume)-1]
-  				// tradeNum = tradeNum[:len(TradeNum)-1]
-  				// takerBuyBaseAssetVolume = takerBuyBaseAssetVolume[:len(TakerBuyBaseAssetVolume)-1]
-  				// takerBuyQuoteAssetVolume = takerBuyQuoteAssetVolume[:len(TakerBuyQuoteAssetVolume)-1]
+		}
+		if state == phaseBackfill {
+			for _, symbol := range symbols {
+				if !bn.paused[symbol] {
+					bn.logProgress(symbol, timeEnd)
 				}
-				cs.AddColumn("Epoch", openTime)
-				cs.AddColumn("Open", open)
-				cs.AddColumn("High", high)
-				cs.AddColumn("Low", low)
-				cs.AddColumn("Close", close)
-				cs.AddColumn("Volume", volume)
-        // cs.AddColumn("closeTime", closeTime)
-  			// cs.AddColumn("quoteAssetVolume", quoteAssetVolume)
-  			// cs.AddColumn("tradeNum", tradeNum)
-  			// cs.AddColumn("takerBuyBaseAssetVolume", takerBuyBaseAssetVolume)
-  			// cs.AddColumn("takerBuyQuoteAssetVolume", takerBuyQuoteAssetVolume)
-				csm := io.NewColumnSeriesMap()
-  			// creslin change from symbol to exchange_symbol_quote
-				tbk := io.NewTimeBucketKey("BINANCE_BNB_" + symbol + "/" + bn.baseTimeframe.String + "/OHLCV")
-				csm.AddColumnSeries(*tbk, cs)
-				executor.WriteCSM(csm, false)
 			}
+		}
+		// the next pass requests the window of the dropped writes again,
+		// the rows written meanwhile are not written twice
+		if refetch {
+			if state == phaseLive {
+				originalTimeEndZero = timeStart
+			} else {
+				firstLoop = true
+			}
+		}
 
+		bn.writeCollectionStatuses(bn.clock.Now().UTC())
+		bn.saveCheckpoint(bn.clock.Now().UTC(), false)
+		if bn.shuttingDown() {
+			glog.Infof("Shutting down after a complete pass")
+			return
+		}
+		bn.collectTicker24(bn.clock.Now().UTC())
+		bn.collectFunding(bn.clock.Now().UTC())
+		if state == phaseLive {
+			bn.collectBookTicker(bn.clock.Now().UTC())
+			bn.writeSnapshot(bn.clock.Now().UTC())
 		}
 
-		if slowDown {
-			// Sleep till next :00 time
-			time.Sleep(waitTill.Sub(time.Now().UTC()))
-		} else {
-			// Binance rate limit is 20 reequests per second so this shouldn't be an issue.
-      			// Changed to 100msec - Creslin
-			time.Sleep(time.Second * 10)
+		if !refetch && !bn.queryEnd.IsZero() && !timeEnd.Before(bn.queryEnd) {
+			glog.Infof("Reached query_end %v", bn.queryEnd)
+			if bn.verifyEnabled {
+				bn.verify(rand.New(rand.NewSource(bn.clock.Now().UnixNano())))
+			}
+			if bn.staging {
+				bn.promoteStaged(rand.New(rand.NewSource(bn.clock.Now().UnixNano())))
+			}
+			if bn.mode == modeOneshot {
+				bn.mu.Lock()
+				for _, symbol := range symbols {
+					glog.Infof("Collected %d rows of %s", bn.collectionStatus(symbol).rowsWritten, symbol)
+				}
+				bn.mu.Unlock()
+			}
+			return
+		}
+
+		wait := bn.backfillSleep
+		if state == phaseLive {
+			// Sleep till next :00 time, and close_delay
+			wait = waitTill.Add(bn.closeDelay).Sub(bn.clock.Now().UTC())
+		}
+		if !bn.sleep(wait) {
+			glog.Infof("Shutting down after a complete pass")
+			return
 		}
 
 	}
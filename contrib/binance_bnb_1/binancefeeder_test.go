@@ -2,9 +2,18 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	binance "github.com/adshao/go-binance"
 	"github.com/alpacahq/marketstore/plugins/bgworker"
+	"github.com/alpacahq/marketstore/utils"
 	. "gopkg.in/check.v1"
 )
 
@@ -27,10 +36,10 @@ func (t *TestSuite) TestNew(c *C) {
 	var ret bgworker.BgWorker
 	var err error
 	ret, err = NewBgWorker(config)
+	c.Assert(err, IsNil)
 	worker = ret.(*BinanceFetcher)
 	c.Assert(len(worker.symbols), Equals, 1)
 	c.Assert(worker.symbols[0], Equals, "BTC")
-	c.Assert(err, IsNil)
 
 	//The symbols from the biannce API can very well change so
 	//if this test fails, consider that the API might of changed with more symbols
@@ -41,11 +50,574 @@ func (t *TestSuite) TestNew(c *C) {
 	// c.Assert(err, IsNil)
 	// c.Assert(len(worker.symbols), Equals, 357)
 
+	// explicit symbols, so that exchangeInfo is not requested
 	config = getConfig(`{
+        "symbols": ["BTC"],
         "query_start": "2017-01-02 00:00"
         }`)
 	ret, err = NewBgWorker(config)
-	worker = ret.(*BinanceFetcher)
 	c.Assert(err, IsNil)
+	worker = ret.(*BinanceFetcher)
 	c.Assert(worker.queryStart.IsZero(), Equals, false)
 }
+
+func (t *TestSuite) TestBucketNameTemplate(c *C) {
+	var config = getConfig(`{
+        "symbols": ["BTC"]
+        }`)
+	ret, err := NewBgWorker(config)
+	c.Assert(err, IsNil)
+	worker := ret.(*BinanceFetcher)
+	c.Assert(worker.bucketName("BTC"), Equals, "BINANCE_BNB_BTC")
+
+	config = getConfig(`{
+        "symbols": ["BTC"],
+        "bucket_name_template": "{exchange}_{base}_{quote}"
+        }`)
+	ret, err = NewBgWorker(config)
+	c.Assert(err, IsNil)
+	worker = ret.(*BinanceFetcher)
+	c.Assert(worker.bucketName("BTC"), Equals, "BINANCE_BTC_BNB")
+
+	for _, template := range []string{
+		"BINANCE_{quote}",
+		"BINANCE_{base}_{symbol}",
+		"BINANCE/{base}",
+		"BINANCE:{base}",
+	} {
+		config = getConfig(`{"symbols": ["BTC"], "bucket_name_template": "` + template + `"}`)
+		ret, err = NewBgWorker(config)
+		c.Assert(err, NotNil)
+		c.Assert(ret, IsNil)
+	}
+}
+
+func (t *TestSuite) TestWriteBackpressure(c *C) {
+	wb := newWriteBackpressure(time.Second, 400*time.Millisecond, 3)
+	c.Assert(wb.observe(100*time.Millisecond), Equals, time.Duration(0))
+
+	// average crosses the threshold and the delay keeps growing up to the cap
+	c.Assert(wb.observe(5*time.Second), Equals, minWriteDelay)
+	c.Assert(wb.observe(5*time.Second), Equals, 2*minWriteDelay)
+	c.Assert(wb.observe(5*time.Second), Equals, 4*minWriteDelay)
+	c.Assert(wb.observe(5*time.Second), Equals, 400*time.Millisecond)
+
+	// once the whole window is fast again the delay decays back to zero
+	c.Assert(wb.observe(10*time.Millisecond), Equals, 400*time.Millisecond)
+	c.Assert(wb.observe(10*time.Millisecond), Equals, 400*time.Millisecond)
+	c.Assert(wb.observe(10*time.Millisecond), Equals, 2*minWriteDelay)
+	c.Assert(wb.observe(10*time.Millisecond), Equals, minWriteDelay)
+	c.Assert(wb.observe(10*time.Millisecond), Equals, time.Duration(0))
+}
+
+func (t *TestSuite) TestVenue(c *C) {
+	var config = getConfig(`{
+        "symbols": ["BTC"],
+        "venue": "binanceus"
+        }`)
+	ret, err := NewBgWorker(config)
+	c.Assert(err, IsNil)
+	worker := ret.(*BinanceFetcher)
+	c.Assert(worker.venue.exchangeInfoURL(), Equals, "https://api.binance.us/api/v3/exchangeInfo")
+	c.Assert(worker.bucketName("BTC"), Equals, "BINANCEUS_BNB_BTC")
+
+	client := worker.venue.newClient()
+	c.Assert(client.BaseURL, Equals, "https://api.binance.us")
+
+	config = getConfig(`{
+        "symbols": ["BTC"],
+        "venue": "nowhere"
+        }`)
+	ret, err = NewBgWorker(config)
+	c.Assert(err, NotNil)
+	c.Assert(ret, IsNil)
+}
+
+func (t *TestSuite) TestAttributeGroup(c *C) {
+	ret, err := NewBgWorker(getConfig(`{"symbols": ["BTC"]}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).attributeGroup, Equals, "OHLCV")
+
+	ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "attribute_group": "Candle"}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).attributeGroup, Equals, "Candle")
+
+	// OHLC buckets have no Volume column, keys can't contain separators,
+	// candles have a row per interval and the other buckets keep their group
+	for _, group := range []string{"OHLC", "OHLCV/1Min", "A:B", "TRADES", "QUOTE", "STATUS", "SNAPSHOT"} {
+		ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "attribute_group": "` + group + `"}`))
+		c.Assert(err, NotNil)
+		c.Assert(ret, IsNil)
+	}
+
+	allowed := `"attribute_groups": ["OHLCV", "CLOSE"]`
+	_, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "columns": ["Close"], "attribute_group": "CLOSE", ` + allowed + `}`))
+	c.Assert(err, IsNil)
+	_, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "attribute_group": "Candle", ` + allowed + `}`))
+	c.Assert(err, NotNil)
+}
+
+func (s *TestSuite) TestMillisConversion(c *C) {
+	t := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(timeToMillis(t), Equals, int64(1533081600000))
+	c.Assert(millisToEpochSec(1533081600000), Equals, t.Unix())
+
+	// sub-second parts are truncated rather than rounded
+	c.Assert(timeToMillis(t.Add(999999*time.Nanosecond)), Equals, int64(1533081600000))
+	c.Assert(timeToMillis(t.Add(-time.Nanosecond)), Equals, int64(1533081599999))
+	c.Assert(millisToEpochSec(1533081600999), Equals, t.Unix())
+	c.Assert(millisToEpochSec(1533081599999), Equals, t.Unix()-1)
+	c.Assert(millisToEpochSec(-1), Equals, int64(-1))
+
+	// the zone does not matter
+	est := t.In(time.FixedZone("EST", -5*60*60))
+	c.Assert(timeToMillis(est), Equals, timeToMillis(t))
+
+	// beyond the range of UnixNano
+	far := time.Date(2300, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(millisToEpochSec(timeToMillis(far)), Equals, far.Unix())
+}
+
+func (s *TestSuite) TestCandleOpen(c *C) {
+	// the instance timezone does not move the UTC boundaries
+	tz := utils.InstanceConfig.Timezone
+	defer func() { utils.InstanceConfig.Timezone = tz }()
+	utils.InstanceConfig.Timezone = time.FixedZone("IST", 5*60*60+30*60)
+	local := time.FixedZone("EST", -5*60*60)
+
+	// 2018-08-01 is a Wednesday
+	t := time.Date(2018, time.July, 31, 23, 30, 0, 0, local)
+	for tf, open := range map[string]time.Time{
+		"1Min": time.Date(2018, time.August, 1, 4, 30, 0, 0, time.UTC),
+		"1H":   time.Date(2018, time.August, 1, 4, 0, 0, 0, time.UTC),
+		"4H":   time.Date(2018, time.August, 1, 4, 0, 0, 0, time.UTC),
+		"1D":   time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC),
+		"3D":   time.Date(2018, time.July, 30, 0, 0, 0, 0, time.UTC),
+		"1W":   time.Date(2018, time.July, 30, 0, 0, 0, 0, time.UTC),
+		"1M":   time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		got := candleOpen(t, utils.NewTimeframe(tf))
+		c.Assert(got, Equals, open, Commentf(tf))
+	}
+	// on the boundary itself
+	monday := time.Date(2018, time.August, 6, 0, 0, 0, 0, time.UTC)
+	c.Assert(candleOpen(monday, utils.NewTimeframe("1W")), Equals, monday)
+	c.Assert(candleOpen(monday.Add(-time.Second), utils.NewTimeframe("1D")), Equals, monday.AddDate(0, 0, -1))
+
+	// months close on the first of the next one
+	february := time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(candleClose(february, utils.NewTimeframe("1M")), Equals, time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(candleClose(february, utils.NewTimeframe("1D")), Equals, february.AddDate(0, 0, 1))
+}
+
+func (s *TestSuite) TestMaxBackfill(c *C) {
+	ret, err := NewBgWorker(getConfig(`{"symbols": ["BTC"]}`))
+	c.Assert(err, IsNil)
+	worker := ret.(*BinanceFetcher)
+	now := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	yearAgo := now.AddDate(-1, 0, 0)
+	// unlimited by default
+	c.Assert(worker.capBackfill(yearAgo, now), Equals, yearAgo)
+
+	ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "max_backfill": "30d"}`))
+	c.Assert(err, IsNil)
+	worker = ret.(*BinanceFetcher)
+	c.Assert(worker.maxBackfill, Equals, 30*24*time.Hour)
+	c.Assert(worker.capBackfill(yearAgo, now), Equals, now.AddDate(0, 0, -30))
+	c.Assert(worker.capBackfill(now.Add(-time.Hour), now), Equals, now.Add(-time.Hour))
+
+	ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "max_backfill": "12h"}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).maxBackfill, Equals, 12*time.Hour)
+
+	for _, maxBackfill := range []string{"banana", "xd", "-1d", "0s"} {
+		ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "max_backfill": "` + maxBackfill + `"}`))
+		c.Assert(err, NotNil)
+		c.Assert(ret, IsNil)
+	}
+}
+
+func (s *TestSuite) TestBackfillSleep(c *C) {
+	ret, err := NewBgWorker(getConfig(`{"symbols": ["BTC"]}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).backfillSleep, Equals, 10*time.Second)
+
+	ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "backfill_sleep": "0s"}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).backfillSleep, Equals, time.Duration(0))
+
+	for _, sleep := range []string{"banana", "-1s"} {
+		ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "backfill_sleep": "` + sleep + `"}`))
+		c.Assert(err, NotNil)
+		c.Assert(ret, IsNil)
+	}
+}
+
+func (s *TestSuite) TestBaseTimeframe(c *C) {
+	for _, t := range []struct {
+		timeframe string
+		duration  time.Duration
+		interval  string
+		valid     bool
+	}{
+		{"1Min", time.Minute, "1m", true},
+		{"5Min", 5 * time.Minute, "5m", true},
+		{"1H", time.Hour, "1h", true},
+		{"4H", 4 * time.Hour, "4h", true},
+		{"1D", 24 * time.Hour, "1d", true},
+		{"3D", 3 * 24 * time.Hour, "3d", true},
+		{"1W", 7 * 24 * time.Hour, "1w", true},
+		{"1M", utils.Month, "1M", true},
+		{"banana", 0, "", false},
+		{"Min", 0, "", false},
+		{"0Min", 0, "", false},
+		{"-1H", 0, "", false},
+		{"1Minute", 0, "", false},
+		{"1 Min", 0, "", false},
+		{"1m", 0, "", false},
+		// valid timeframes Binance has no candles of
+		{"2Min", 0, "", false},
+		{"10Sec", 0, "", false},
+		{"60Min", 0, "", false},
+		{"1Y", 0, "", false},
+	} {
+		ret, err := NewBgWorker(getConfig(`{"symbols": ["BTC"], "base_timeframe": "` + t.timeframe + `"}`))
+		if !t.valid {
+			c.Assert(err, NotNil, Commentf("%s", t.timeframe))
+			c.Assert(ret, IsNil)
+			continue
+		}
+		c.Assert(err, IsNil, Commentf("%s", t.timeframe))
+		worker := ret.(*BinanceFetcher)
+		c.Assert(worker.baseTimeframe.String, Equals, t.timeframe)
+		c.Assert(worker.baseTimeframe.Duration, Equals, t.duration)
+		c.Assert(worker.binanceInterval(), Equals, t.interval)
+	}
+}
+
+func (s *TestSuite) TestCatchupTolerance(c *C) {
+	now := time.Date(2018, time.August, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	for _, t := range []struct {
+		tolerance int
+		// frontier is the first pass end that turns live
+		frontier time.Time
+	}{
+		{0, now.Add(time.Nanosecond)},
+		{2, now.Add(-2*day + time.Nanosecond)},
+	} {
+		bn := &BinanceFetcher{
+			baseTimeframe:    utils.NewTimeframe("1D"),
+			catchupTolerance: t.tolerance,
+		}
+		c.Assert(bn.nextPhase(phaseBackfill, t.frontier.Add(-time.Nanosecond), now), Equals, phaseBackfill)
+		c.Assert(bn.nextPhase(phaseBackfill, t.frontier, now), Equals, phaseLive)
+
+		// passes of 7 days from 30 days back flip once, at the first pass
+		// ending past the frontier
+		state := phaseBackfill
+		flips := 0
+		var flippedAt time.Time
+		for end := now.Add(-30 * day); end.Before(now.Add(30 * day)); end = end.Add(7 * day) {
+			next := bn.nextPhase(state, end, now)
+			if next != state {
+				flips++
+				flippedAt = end
+			}
+			state = next
+		}
+		c.Assert(flips, Equals, 1, Commentf("tolerance %d", t.tolerance))
+		c.Assert(flippedAt.Before(t.frontier), Equals, false)
+		c.Assert(flippedAt.Add(-7*day).Before(t.frontier), Equals, true)
+	}
+	c.Assert(phaseLive.String(), Equals, "live")
+}
+
+func (s *TestSuite) TestWeightLimiter(c *C) {
+	c.Assert(klinesWeight(50), Equals, 1)
+	c.Assert(klinesWeight(100), Equals, 2)
+	c.Assert(klinesWeight(500), Equals, 5)
+	c.Assert(klinesWeight(1000), Equals, 5)
+	c.Assert(klinesWeight(1500), Equals, 10)
+
+	now := time.Date(2018, time.August, 1, 0, 0, 30, 0, time.UTC)
+	var slept []time.Duration
+	var used, remaining int
+	l := newWeightLimiter(12, time.Minute)
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+	l.observe = func(u, r int) { used, remaining = u, r }
+
+	l.reserve(5)
+	l.reserve(5)
+	c.Assert(slept, HasLen, 0)
+	c.Assert(used, Equals, 10)
+	c.Assert(remaining, Equals, 2)
+
+	// the third request waits for the next minute
+	l.reserve(5)
+	c.Assert(slept, DeepEquals, []time.Duration{30 * time.Second})
+	c.Assert(used, Equals, 5)
+	c.Assert(remaining, Equals, 7)
+
+	// a request heavier than the limit is not stuck forever
+	now = now.Add(time.Minute)
+	l.reserve(20)
+	c.Assert(slept, HasLen, 1)
+	c.Assert(used, Equals, 20)
+}
+
+func (s *TestSuite) TestLimiterState(c *C) {
+	now := time.Date(2018, time.August, 1, 0, 0, 30, 0, time.UTC)
+	l := newWeightLimiter(12, time.Minute)
+	l.now = func() time.Time { return now }
+	var waiting limiterState
+	l.sleep = func(d time.Duration) {
+		waiting = l.state()
+		now = now.Add(d)
+	}
+
+	l.reserve(10)
+	l.reportUsed(11)
+	c.Assert(l.state(), DeepEquals, limiterState{
+		Limit: 12, Window: "1m0s", RatePerSecond: 0.2,
+		Used: 10, Available: 2, VenueUsed: 11,
+	})
+	l.reserve(5)
+	c.Assert(waiting.Waiting, Equals, 1)
+	c.Assert(waiting.Available, Equals, 2)
+	c.Assert(l.state().Waiting, Equals, 0)
+	c.Assert(l.state().Available, Equals, 7)
+
+	// a window without requests yet is all available
+	now = now.Add(time.Minute)
+	c.Assert(l.state().Available, Equals, 12)
+
+	ret, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "base_timeframe": "5Min"}`))
+	c.Assert(err, IsNil)
+	worker := ret.(*BinanceFetcher)
+	published := metrics.Get(worker.metricKey("limiter")).String()
+	var state limiterState
+	c.Assert(json.Unmarshal([]byte(published), &state), IsNil)
+	c.Assert(state.Limit, Equals, worker.limiterState().Limit)
+}
+
+func (s *TestSuite) TestUsedWeight(c *C) {
+	used := "0"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", used)
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	var reported []int
+	l := newWeightLimiter(100, time.Minute)
+	l.observeUsed = func(u int) { reported = append(reported, u) }
+	v := venue{baseURL: srv.URL, limiter: l}
+	client := v.jsonHTTPClient()
+
+	for _, u := range []string{"10", "85", "90", "20", "80"} {
+		used = u
+		resp, err := client.Get(srv.URL)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		if u == "90" {
+			c.Assert(l.warned, Equals, true)
+		}
+	}
+	c.Assert(reported, DeepEquals, []int{10, 85, 90, 20, 80})
+	// crossing the fraction again after falling below it warns again
+	c.Assert(l.warned, Equals, true)
+
+	used = "none"
+	resp, err := client.Get(srv.URL)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(reported, HasLen, 5)
+}
+
+func (s *TestSuite) TestAudit(c *C) {
+	path := filepath.Join(c.MkDir(), "audit.jsonl")
+	a, err := newAuditLog(path, 1.5, 1000)
+	c.Assert(err, IsNil)
+	spike := &binance.Kline{OpenTime: 1533081600000, Open: "10", High: "20", Low: "10", Close: "11", Volume: "5"}
+	calm := &binance.Kline{OpenTime: 1533081660000, Open: "10", High: "11", Low: "10", Close: "11", Volume: "5"}
+	c.Assert(a.anomalous(spike), Equals, true)
+	c.Assert(a.anomalous(calm), Equals, false)
+
+	// the kline returned again is recorded once
+	a.record("EOS", []*binance.Kline{spike, calm}, "open")
+	a.record("EOS", []*binance.Kline{spike}, "open")
+	records, err := readAudit(path, "EOS", 1533081600)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Assert(*records[0].Kline, DeepEquals, *spike)
+
+	// the file stops growing at audit_max_bytes
+	for i := int64(1); i < 10; i++ {
+		k := *spike
+		k.OpenTime += i * 120000
+		a.record("TRX", []*binance.Kline{&k}, "open")
+	}
+	fi, err := os.Stat(path)
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size() <= 1000, Equals, true)
+	c.Assert(a.full, Equals, true)
+	records, err = readAudit(path, "TRX", 0)
+	c.Assert(err, IsNil)
+	c.Assert(len(records) > 0 && len(records) < 9, Equals, true)
+
+	workersMu.Lock()
+	workers[workerKey("BNB", "3Min")] = &BinanceFetcher{audit: a}
+	workersMu.Unlock()
+	defer func() {
+		workersMu.Lock()
+		delete(workers, "3Min")
+		workersMu.Unlock()
+	}()
+	w := httptest.NewRecorder()
+	handleAudit(w, httptest.NewRequest("GET", "/binance/bnb/audit?timeframe=3Min&symbol=EOS", nil))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &records), IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Assert(records[0].Epoch, Equals, int64(1533081600))
+
+	_, err = newAuditLog("", 1.5, 400)
+	c.Assert(err, NotNil)
+	_, err = newAuditLog(path, 0.5, 400)
+	c.Assert(err, NotNil)
+}
+
+func (s *TestSuite) TestSharedClient(c *C) {
+	newWorker := func(config string) *BinanceFetcher {
+		ret, err := NewBgWorker(getConfig(config))
+		c.Assert(err, IsNil)
+		return ret.(*BinanceFetcher)
+	}
+	eos := newWorker(`{"symbols": ["EOS"], "base_currency": "BNB", "base_timeframe": "1Min"}`)
+	btc := newWorker(`{"symbols": ["ETH"], "base_currency": "BTC", "base_timeframe": "1H"}`)
+	c.Assert(btc.limiter, Equals, eos.limiter)
+	c.Assert(btc.client, Equals, eos.client)
+	c.Assert(btc.venue.httpClient, Equals, eos.venue.httpClient)
+
+	// another venue or egress has a limit of its own
+	us := newWorker(`{"symbols": ["EOS"], "venue": "binanceus"}`)
+	c.Assert(us.limiter, Not(Equals), eos.limiter)
+	proxied := newWorker(`{"symbols": ["EOS"], "proxy_url": "http://proxy.invalid:3128"}`)
+	c.Assert(proxied.limiter, Not(Equals), eos.limiter)
+
+	// the weight reserved by one worker counts against the others
+	l := eos.limiter
+	l.now = func() time.Time { return time.Date(2018, time.August, 1, 0, 0, 30, 0, time.UTC) }
+	l.windowStart = time.Time{}
+	defer func() {
+		l.now = time.Now
+		l.windowStart = time.Time{}
+	}()
+	eos.limiter.reserve(1)
+	btc.limiter.reserve(5)
+	c.Assert(l.used, Equals, 6)
+}
+
+func (s *TestSuite) TestShard(c *C) {
+	universe := []string{}
+	for i := 0; i < 2000; i++ {
+		universe = append(universe, fmt.Sprintf("S%04d", i))
+	}
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		shard := shardSymbols(universe, &ShardConfig{Index: i, Count: 4})
+		// roughly a quarter each
+		c.Assert(len(shard) > 400 && len(shard) < 600, Equals, true, Commentf("shard %d has %d", i, len(shard)))
+		for _, symbol := range shard {
+			seen[symbol]++
+		}
+		// the same symbols with or without the others
+		c.Assert(shardSymbols(shard, &ShardConfig{Index: i, Count: 4}), DeepEquals, shard)
+	}
+	c.Assert(seen, HasLen, len(universe))
+	for symbol, n := range seen {
+		c.Assert(n, Equals, 1, Commentf("%s", symbol))
+	}
+
+	ret, err := NewBgWorker(getConfig(`{"symbols": ["EOS", "TRX", "VEN", "BTC", "ETH", "XRP"], "shard": {"index": 1, "count": 2}}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).symbols, DeepEquals, shardSymbols([]string{"EOS", "TRX", "VEN", "BTC", "ETH", "XRP"}, &ShardConfig{Index: 1, Count: 2}))
+	for _, shard := range []string{`{"index": 2, "count": 2}`, `{"index": -1, "count": 2}`, `{"index": 0, "count": 0}`} {
+		_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "shard": ` + shard + `}`))
+		c.Assert(err, NotNil, Commentf("%s", shard))
+	}
+}
+
+func (s *TestSuite) TestClassifyError(c *C) {
+	for code, want := range map[int64]action{
+		-1000: actionRetry,
+		-1001: actionRetry,
+		-1007: actionRetry,
+		-1003: actionBackoff,
+		-1015: actionBackoff,
+		-1121: actionQuarantine,
+		-1002: actionAbort,
+		-1022: actionAbort,
+		-1120: actionAbort,
+		-2014: actionAbort,
+		-2015: actionAbort,
+	} {
+		err := &binance.APIError{Code: code, Message: "message"}
+		c.Assert(classifyError(err), Equals, want, Commentf("code %d", code))
+	}
+	c.Assert(classifyError(errors.New("connection reset by peer")), Equals, actionRetry)
+
+	c.Assert(retryDelay(1), Equals, time.Second)
+	c.Assert(retryDelay(3), Equals, 4*time.Second)
+	c.Assert(retryDelay(7), Equals, maxRetryDelay)
+	c.Assert(retryDelay(100), Equals, maxRetryDelay)
+}
+
+func (s *TestSuite) TestExpandEnv(c *C) {
+	os.Setenv("BINANCE_TEST_SYMBOL", "EOS")
+	os.Setenv("BINANCE_TEST_QUOTE", "USDT")
+	defer os.Unsetenv("BINANCE_TEST_SYMBOL")
+	defer os.Unsetenv("BINANCE_TEST_QUOTE")
+
+	conf := getConfig(`{"symbols": ["${BINANCE_TEST_SYMBOL}", "TRX"], "base_currency": "${BINANCE_TEST_QUOTE}"}`)
+	ret, err := NewBgWorker(conf)
+	c.Assert(err, IsNil)
+	worker := ret.(*BinanceFetcher)
+	c.Assert(worker.symbols, DeepEquals, []string{"EOS", "TRX"})
+	c.Assert(worker.baseCurrency, Equals, "USDT")
+	// the config passed is left as it is
+	c.Assert(conf["base_currency"], Equals, "${BINANCE_TEST_QUOTE}")
+
+	expanded, err := expandEnv(getConfig(`{"a": {"b": "x-${BINANCE_TEST_QUOTE}-$BINANCE_TEST_QUOTE"}, "n": 1}`))
+	c.Assert(err, IsNil)
+	c.Assert(expanded, DeepEquals, map[string]interface{}{
+		"a": map[string]interface{}{"b": "x-USDT-$BINANCE_TEST_QUOTE"},
+		"n": float64(1),
+	})
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "base_currency": "${BINANCE_TEST_UNSET}"}`))
+	c.Assert(err, ErrorMatches, ".*BINANCE_TEST_UNSET.*")
+}
+
+func (s *TestSuite) TestValidateConfig(c *C) {
+	c.Assert(ValidateConfig(getConfig(`{"symbols": ["EOS"], "base_timeframe": "5Min", "lease_ttl": "10s"}`)), IsNil)
+	c.Assert(ValidateConfig(getConfig(`{"base_currencies": ["BNB", "USDT"], "state_addr": "localhost:0"}`)), IsNil)
+	for _, config := range []string{
+		`{"symbols": ["EOS"], "base_timeframe": "2Min"}`,
+		`{"symbols": ["EOS"], "mode": "daemon"}`,
+		`{"symbols": ["EOS"], "lease_ttl": "1s"}`,
+		`{"symbols": ["EOS"], "base_currency": "BNB", "base_currencies": ["USDT"]}`,
+		`{"symbols": ["EOS"], "venue": "kraken"}`,
+	} {
+		c.Assert(ValidateConfig(getConfig(config)), NotNil, Commentf(config))
+	}
+
+	// the symbol_source is only read by the probe
+	missing := `{"symbol_source": "file:` + filepath.Join(c.MkDir(), "symbols.txt") + `"}`
+	c.Assert(ValidateConfig(getConfig(missing)), IsNil)
+	c.Assert(ProbeConfig(getConfig(missing)), NotNil)
+}
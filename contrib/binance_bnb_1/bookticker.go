@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// bookTickerWeight is the request weight of /ticker/bookTicker for all
+// symbols
+const bookTickerWeight = 2
+
+// BookTicker is the best bid and ask of a symbol
+type BookTicker struct {
+	Symbol   string `json:"symbol"`
+	BidPrice string `json:"bidPrice"`
+	BidQty   string `json:"bidQty"`
+	AskPrice string `json:"askPrice"`
+	AskQty   string `json:"askQty"`
+}
+
+// quoteKey is the bucket the best bid and ask of symbol are written to, e.g.
+// BINANCE_BNB_EOS/1Min/QUOTE.  Its records are variable length, so that the
+// Epoch of each snapshot is the time it was taken.
+func (bn *BinanceFetcher) quoteKey(symbol string) *io.TimeBucketKey {
+	return io.NewTimeBucketKey(bn.bucketName(symbol) + "/" + bn.timeframeOf(symbol).String + "/QUOTE")
+}
+
+// collectBookTicker writes the best bid and ask of each symbol to its QUOTE
+// bucket with now as Epoch, when book_ticker is set.  All symbols are
+// requested at once.
+func (bn *BinanceFetcher) collectBookTicker(now time.Time) {
+	if !bn.bookTicker {
+		return
+	}
+	if err := bn.store.ready(); err != nil {
+		glog.Errorf("Cannot write the book ticker: %v", err)
+		return
+	}
+	bn.limiter.reserve(bookTickerWeight)
+	var tickers []BookTicker
+	if err := getJson(bn.venue.jsonHTTPClient(), bn.venue.bookTickerURL(), &tickers); err != nil {
+		glog.Errorf("Binance /ticker/bookTicker API error: %v", err)
+		return
+	}
+
+	bySymbol := map[string]BookTicker{}
+	for _, t := range tickers {
+		bySymbol[t.Symbol] = t
+	}
+	csm := io.NewColumnSeriesMap()
+	for _, symbol := range bn.symbols {
+		t, ok := bySymbol[symbol+bn.baseCurrency]
+		if !ok {
+			continue
+		}
+		values := make([]float64, 4)
+		var err error
+		for i, str := range []string{t.BidPrice, t.BidQty, t.AskPrice, t.AskQty} {
+			if values[i], err = strconv.ParseFloat(str, 64); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			glog.Errorf("Invalid book ticker of %s: %v", symbol, err)
+			continue
+		}
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{now.Unix()})
+		cs.AddColumn("BidPrice", []float64{values[0]})
+		cs.AddColumn("BidQty", []float64{values[1]})
+		cs.AddColumn("AskPrice", []float64{values[2]})
+		cs.AddColumn("AskQty", []float64{values[3]})
+		csm.AddColumnSeries(*bn.quoteKey(symbol), cs)
+	}
+	if !csm.IsEmpty() {
+		if err := bn.store.write(csm); err != nil {
+			glog.Errorf("Cannot write the book ticker: %v", err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestBookTicker(c *C) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		c.Check(r.URL.Path, Equals, "/api/v3/ticker/bookTicker")
+		w.Write([]byte(`[
+			{"symbol": "EOSBNB", "bidPrice": "0.4200", "bidQty": "31.5", "askPrice": "0.4210", "askQty": "12"},
+			{"symbol": "ETHBTC", "bidPrice": "0.06", "bidQty": "1", "askPrice": "0.061", "askQty": "2"}
+		]`))
+	}))
+	defer server.Close()
+
+	config := `{"symbols": ["EOS", "TRX"], "bucket_name_template": "BOOK_{base}"%s}`
+	// off by default
+	worker := s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, ""))
+	worker.venue.baseURL = server.URL
+	worker.collectBookTicker(time.Now().UTC())
+	c.Assert(requests, Equals, 0)
+
+	worker = s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, `, "book_ticker": true`))
+	worker.venue.baseURL = server.URL
+	now := time.Date(2018, time.August, 1, 0, 0, 30, 0, time.UTC)
+	worker.collectBookTicker(now)
+	worker.collectBookTicker(now.Add(10 * time.Second))
+	c.Assert(requests, Equals, 2)
+
+	// the snapshots keep their own times within the minute
+	eos := readBucket(c, "BOOK_EOS/1Min/QUOTE")
+	c.Assert(eos.GetEpoch(), DeepEquals, []int64{now.Unix(), now.Add(10 * time.Second).Unix()})
+	c.Assert(eos.GetByName("BidPrice"), DeepEquals, []float64{0.42, 0.42})
+	c.Assert(eos.GetByName("BidQty"), DeepEquals, []float64{31.5, 31.5})
+	c.Assert(eos.GetByName("AskPrice"), DeepEquals, []float64{0.421, 0.421})
+	c.Assert(eos.GetByName("AskQty"), DeepEquals, []float64{12, 12})
+}
@@ -0,0 +1,49 @@
+package main
+
+import "github.com/alpacahq/marketstore/utils/io"
+
+// BucketKeys returns the keys of all the buckets the worker writes, for tools
+// operating on everything it produces: the candles of each symbol, then the
+// buckets of the options enabled, its collection status, 24hr ticker, book
+// ticker, funding, caught up event and lease, in the order of the symbols, and the
+// snapshot of all of them
+func (bn *BinanceFetcher) BucketKeys() []*io.TimeBucketKey {
+	keys := []*io.TimeBucketKey{}
+	for _, symbol := range bn.symbols {
+		keys = append(keys, bn.tbkFor(symbol))
+	}
+	for _, symbol := range bn.symbols {
+		if bn.statusInterval > 0 {
+			keys = append(keys, bn.collectionStatusKey(symbol))
+		}
+		if bn.ticker24Interval > 0 {
+			keys = append(keys, bn.ticker24Key(symbol))
+		}
+		if bn.bookTicker {
+			keys = append(keys, bn.quoteKey(symbol))
+		}
+		if bn.fundingInterval > 0 {
+			keys = append(keys, bn.fundingKey(symbol))
+		}
+		if bn.caughtUpEvents {
+			keys = append(keys, bn.caughtUpKey(symbol))
+		}
+		if bn.leaseTTL > 0 {
+			keys = append(keys, bn.leaseKey(symbol))
+		}
+	}
+	if bn.snapshot {
+		keys = append(keys, bn.snapshotKey())
+	}
+	return keys
+}
+
+// BucketKeys returns the keys of the buckets of all the workers, see
+// BinanceFetcher.BucketKeys
+func (workers quoteWorkers) BucketKeys() []*io.TimeBucketKey {
+	keys := []*io.TimeBucketKey{}
+	for _, bn := range workers {
+		keys = append(keys, bn.BucketKeys()...)
+	}
+	return keys
+}
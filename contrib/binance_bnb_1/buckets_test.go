@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestBucketKeys(c *C) {
+	itemKeys := func(keys []*io.TimeBucketKey) []string {
+		items := []string{}
+		for _, tbk := range keys {
+			items = append(items, tbk.GetItemKey())
+		}
+		return items
+	}
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS", "TRX"], "base_timeframe": "5Min"}`)
+	c.Assert(itemKeys(worker.BucketKeys()), DeepEquals, []string{
+		"BINANCE_BNB_EOS/5Min/OHLCV", "BINANCE_BNB_TRX/5Min/OHLCV",
+	})
+
+	worker = s.newWorker(c, newFixtureClient(c), `{
+        "symbols": ["EOS", "TRX"],
+        "bucket_name_template": "KEYS_{base}",
+        "status_interval": "1m",
+        "book_ticker": true
+        }`)
+	c.Assert(itemKeys(worker.BucketKeys()), DeepEquals, []string{
+		"KEYS_EOS/1Min/OHLCV", "KEYS_TRX/1Min/OHLCV",
+		"KEYS_EOS/1Min/STATUS", "KEYS_EOS/1Min/QUOTE",
+		"KEYS_TRX/1Min/STATUS", "KEYS_TRX/1Min/QUOTE",
+	})
+}
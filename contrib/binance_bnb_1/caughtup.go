@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// caughtUpKey is the bucket the caught up event of symbol is written to,
+// e.g. BINANCE_BNB_EOS/1Min/CAUGHTUP
+func (bn *BinanceFetcher) caughtUpKey(symbol string) *io.TimeBucketKey {
+	return io.NewTimeBucketKey(bn.bucketName(symbol) + "/1Min/CAUGHTUP")
+}
+
+// checkCaughtUp writes the caught up event of symbol with caught_up_events
+// the first time its last written candle, at epoch last, is current: the
+// candle after it closes later than catchup_tolerance intervals before now,
+// like the pass ending the backfill in nextPhase.  Downstream consumers can
+// start on the symbol from then on without waiting for the others.
+func (bn *BinanceFetcher) checkCaughtUp(symbol string, last int64) {
+	if !bn.caughtUpEvents {
+		return
+	}
+	now := bn.clock.Now().UTC()
+	nextClose := time.Unix(last, 0).Add(2 * bn.timeframeOf(symbol).Duration)
+	if bn.nextPhase(phaseBackfill, nextClose, now) != phaseLive {
+		return
+	}
+	bn.mu.Lock()
+	if bn.caughtUp[symbol] {
+		bn.mu.Unlock()
+		return
+	}
+	bn.caughtUp[symbol] = true
+	bn.mu.Unlock()
+	glog.Infof("%s caught up to %v", symbol, time.Unix(last, 0).UTC())
+
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{now.Truncate(time.Minute).Unix()})
+	cs.AddColumn("LastEpoch", []int64{last})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*bn.caughtUpKey(symbol), cs)
+	if err := bn.store.write(csm); err != nil {
+		glog.Errorf("Cannot write the caught up event of %s: %v", symbol, err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestCaughtUpEvents(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	klines := syntheticKlines(start, 60, time.Minute)
+	write := func(worker *BinanceFetcher, klines []*binance.Kline) {
+		cs, err := ratesToColumnSeries(klines, false, "open", onErrorSkip)
+		c.Assert(err, IsNil)
+		c.Assert(worker.write("EOS", cs, false), IsNil)
+	}
+	worker := s.newWorker(c, newFixtureClient(c), `{
+        "symbols": ["EOS"],
+        "bucket_name_template": "CAUGHTUP_{base}",
+        "caught_up_events": true
+        }`)
+	clk := &fakeClock{now: start.Add(time.Hour)}
+	worker.clock = clk
+	keys := worker.BucketKeys()
+	c.Assert(keys, HasLen, 2)
+	c.Assert(keys[1].GetItemKey(), Equals, "CAUGHTUP_EOS/1Min/CAUGHTUP")
+
+	write(worker, klines[:30])
+	last, err := lastStoredTime(worker.store, worker.caughtUpKey("EOS"))
+	c.Assert(err, IsNil)
+	c.Assert(last.IsZero(), Equals, true)
+	// the candle of 01:00 is forming
+	write(worker, klines[30:])
+	events := readBucket(c, "CAUGHTUP_EOS/1Min/CAUGHTUP")
+	c.Assert(events.GetEpoch(), DeepEquals, []int64{start.Add(time.Hour).Unix()})
+	c.Assert(events.GetByName("LastEpoch").([]int64), DeepEquals, []int64{start.Add(59 * time.Minute).Unix()})
+
+	// only the first time
+	clk.now = clk.now.Add(5 * time.Minute)
+	write(worker, syntheticKlines(start.Add(time.Hour), 5, time.Minute))
+	c.Assert(readBucket(c, "CAUGHTUP_EOS/1Min/CAUGHTUP").Len(), Equals, 1)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// defaultCheckpointInterval is how often the checkpoint is written with
+// checkpoint_file
+const defaultCheckpointInterval = time.Minute
+
+// checkpoint is the progress of a worker, written to its checkpoint file so
+// that a restart keeps what the buckets do not tell
+type checkpoint struct {
+	Quote     string   `json:"quote"`
+	Timeframe string   `json:"timeframe"`
+	Symbols   []string `json:"symbols"`
+	// LastCandles are the epochs of the last candles written of each symbol
+	LastCandles map[string]int64 `json:"last_candles"`
+	// Spans and FetchedThrough are those learned with adaptive_span
+	Spans          map[string]int       `json:"spans,omitempty"`
+	FetchedThrough map[string]time.Time `json:"fetched_through,omitempty"`
+	// Gaps are the backfill windows given up, see windowFailed
+	Gaps map[string][]gap `json:"gaps,omitempty"`
+}
+
+// checkpointFile is the content of a checkpoint file, the checkpoint with the
+// SHA-256 of its JSON, so that a file cut short or altered is detected
+type checkpointFile struct {
+	Checksum   string          `json:"checksum"`
+	Checkpoint json.RawMessage `json:"checkpoint"`
+}
+
+// checkpointer writes the checkpoint of a worker to path every interval
+type checkpointer struct {
+	mu        sync.Mutex
+	path      string
+	interval  time.Duration
+	writtenAt time.Time
+	// restored is the checkpoint loaded at startup, nil if none
+	restored *checkpoint
+}
+
+// renameCheckpoint moves the written temporary file over the checkpoint
+// file, replaced by the tests to simulate a crash before it
+var renameCheckpoint = os.Rename
+
+// checkpointPath is the checkpoint file of the worker of quote and
+// timeframe, e.g. checkpoint_BNB_1Min.json for checkpoint.json, as the
+// workers of the quotes share the config
+func checkpointPath(path, quote, timeframe string) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%s_%s%s", strings.TrimSuffix(path, ext), quote, timeframe, ext)
+}
+
+// loadCheckpoint reads the checkpoint file at path, nil if there is none.  A
+// file that cannot be read, is cut short or does not match its checksum is
+// logged and ignored, the progress being derived from the buckets instead.
+func loadCheckpoint(path, quote, timeframe string) *checkpoint {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Warningf("Ignoring the checkpoint %s: %v", path, err)
+		}
+		return nil
+	}
+	var f checkpointFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		glog.Warningf("Ignoring the corrupt checkpoint %s: %v", path, err)
+		return nil
+	}
+	sum := sha256.Sum256(f.Checkpoint)
+	if hex.EncodeToString(sum[:]) != f.Checksum {
+		glog.Warningf("Ignoring the corrupt checkpoint %s: checksum mismatch", path)
+		return nil
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(f.Checkpoint, &cp); err != nil {
+		glog.Warningf("Ignoring the corrupt checkpoint %s: %v", path, err)
+		return nil
+	}
+	if cp.Quote != quote || cp.Timeframe != timeframe {
+		glog.Warningf("Ignoring the checkpoint %s of %s %s", path, cp.Quote, cp.Timeframe)
+		return nil
+	}
+	return &cp
+}
+
+// writeCheckpoint writes cp to path atomically: to a temporary file in the
+// same directory first, synced, then renamed over path, so that a crash
+// leaves either the previous checkpoint or the new one
+func writeCheckpoint(path string, cp *checkpoint) error {
+	payload, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload)
+	data, err := json.Marshal(checkpointFile{Checksum: hex.EncodeToString(sum[:]), Checkpoint: payload})
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := renameCheckpoint(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// checkpoint returns the progress of the worker, read under mu
+func (bn *BinanceFetcher) checkpoint() *checkpoint {
+	cp := &checkpoint{
+		Quote:          bn.baseCurrency,
+		Timeframe:      bn.baseTimeframe.String,
+		Symbols:        append([]string{}, bn.symbols...),
+		LastCandles:    map[string]int64{},
+		Spans:          map[string]int{},
+		FetchedThrough: map[string]time.Time{},
+		Gaps:           map[string][]gap{},
+	}
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	for _, symbol := range bn.symbols {
+		if last, ok := bn.lastWritten[symbol]; ok {
+			cp.LastCandles[symbol] = last
+		}
+		if span, ok := bn.spans[symbol]; ok {
+			cp.Spans[symbol] = span
+		}
+		if through, ok := bn.fetchedThrough[symbol]; ok {
+			cp.FetchedThrough[symbol] = through
+		}
+		if gaps := bn.gaps[symbol]; len(gaps) > 0 {
+			cp.Gaps[symbol] = append([]gap{}, gaps...)
+		}
+	}
+	return cp
+}
+
+// saveCheckpoint writes the checkpoint with checkpoint_file once every
+// checkpoint_interval at now, or whenever with force
+func (bn *BinanceFetcher) saveCheckpoint(now time.Time, force bool) {
+	c := bn.checkpointer
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !force && now.Sub(c.writtenAt) < c.interval {
+		return
+	}
+	if err := writeCheckpoint(c.path, bn.checkpoint()); err != nil {
+		glog.Errorf("Cannot write the checkpoint %s: %v", c.path, err)
+		return
+	}
+	c.writtenAt = now
+}
+
+// restoreCheckpoint restores the learned spans and the gaps of the symbols
+// from the checkpoint loaded at startup.  The buckets tell where each symbol
+// resumes, see checkCheckpoint.
+func (bn *BinanceFetcher) restoreCheckpoint() {
+	if bn.checkpointer == nil || bn.checkpointer.restored == nil {
+		return
+	}
+	cp := bn.checkpointer.restored
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	for _, symbol := range bn.symbols {
+		if span, ok := cp.Spans[symbol]; ok {
+			bn.spans[symbol] = span
+		}
+		if through, ok := cp.FetchedThrough[symbol]; ok {
+			bn.fetchedThrough[symbol] = through
+		}
+		if gaps := cp.Gaps[symbol]; len(gaps) > 0 {
+			bn.gaps[symbol] = gaps
+		}
+	}
+}
+
+// checkCheckpoint warns if the checkpoint loaded at startup had written
+// candles of symbol after last, the last one in its bucket, e.g. as the
+// bucket was removed.  The bucket wins.
+func (bn *BinanceFetcher) checkCheckpoint(symbol string, last time.Time) {
+	if bn.checkpointer == nil || bn.checkpointer.restored == nil {
+		return
+	}
+	if epoch, ok := bn.checkpointer.restored.LastCandles[symbol]; ok && epoch > last.Unix() {
+		glog.Warningf("The checkpoint had written %s through %v, its bucket only holds them through %v",
+			symbol, time.Unix(epoch, 0).UTC(), last)
+	}
+}
+
+// checkpointInterval returns checkpoint_interval
+func checkpointInterval(config *FetcherConfig) (time.Duration, error) {
+	if config.CheckpointInterval == "" {
+		return defaultCheckpointInterval, nil
+	}
+	d, err := time.ParseDuration(config.CheckpointInterval)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("invalid checkpoint_interval %q, must be at least 1s", config.CheckpointInterval)
+	}
+	return d, nil
+}
+
+// newCheckpointer returns the checkpointer of the worker of quote and
+// timeframe, with the checkpoint it finds at path loaded
+func newCheckpointer(path string, interval time.Duration, quote, timeframe string) *checkpointer {
+	return &checkpointer{path: path, interval: interval, restored: loadCheckpoint(path, quote, timeframe)}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestCheckpoint(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	dir := c.MkDir()
+	config := fmt.Sprintf(`{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "CHECKPOINT_{base}",
+        "request_span": 20,
+        "advance_step": 20,
+        "window_retries": 1,
+        "checkpoint_file": %q
+        }`, filepath.Join(dir, "checkpoint.json"))
+	path := filepath.Join(dir, "checkpoint_BNB_1Min.json")
+	worker := s.newWorker(c, &badWindowClient{
+		klinesClient: newFixtureClient(c, "TRXBNB"),
+		symbol:       "TRXBNB",
+		startTime:    timeToMillis(start.Add(20 * time.Minute)),
+	}, config)
+	worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+	worker.Run()
+
+	cp := loadCheckpoint(path, "BNB", "1Min")
+	c.Assert(cp, NotNil)
+	c.Assert(cp.Symbols, DeepEquals, []string{"TRX"})
+	c.Assert(cp.LastCandles["TRX"], Equals, start.Add(time.Hour).Unix())
+	c.Assert(cp.Gaps["TRX"], HasLen, 1)
+	valid, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+
+	// a crash while writing the checkpoint leaves the previous one
+	renameCheckpoint = func(string, string) error { return errors.New("killed") }
+	worker.gaps["TRX"] = nil
+	worker.saveCheckpoint(worker.clock.Now(), true)
+	renameCheckpoint = os.Rename
+	c.Assert(ioutil.WriteFile(path+".tmp123", valid[:len(valid)/2], 0644), IsNil)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(data, DeepEquals, valid)
+
+	// the gaps are restored by the next start
+	worker = s.newWorker(c, newFixtureClient(c, "TRXBNB"), config)
+	c.Assert(worker.state(start).Symbols["TRX"].Gaps, HasLen, 1)
+
+	// a checkpoint cut short, or altered, is ignored for the buckets
+	for _, corrupt := range [][]byte{
+		valid[:len(valid)/2],
+		[]byte(strings.Replace(string(valid), `"TRX"`, `"EOS"`, 1)),
+	} {
+		c.Assert(ioutil.WriteFile(path, corrupt, 0644), IsNil)
+		c.Assert(loadCheckpoint(path, "BNB", "1Min"), IsNil)
+		worker = s.newWorker(c, newFixtureClient(c, "TRXBNB"), config)
+		c.Assert(worker.checkpointer.restored, IsNil)
+		c.Assert(worker.state(start).Symbols["TRX"].Gaps, HasLen, 0)
+		worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+		worker.Run()
+		c.Assert(readBucket(c, "CHECKPOINT_TRX/1Min/OHLCV").GetEpoch(), HasLen, 61)
+		c.Assert(loadCheckpoint(path, "BNB", "1Min"), NotNil)
+	}
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["TRX"], "checkpoint_file": "/tmp/checkpoint.json", "checkpoint_interval": "1ms"}`))
+	c.Assert(err, NotNil)
+}
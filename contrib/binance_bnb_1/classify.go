@@ -0,0 +1,106 @@
+package main
+
+import (
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/golang/glog"
+)
+
+// action is what the worker does about a failed request
+type action int
+
+const (
+	// actionRetry retries the request after a growing delay, for network
+	// errors and the errors the exchange reports as transient
+	actionRetry action = iota
+	// actionBackoff waits out rateLimitBackoff before the next request
+	actionBackoff
+	// actionQuarantine stops fetching the symbol, which the venue does not know
+	actionQuarantine
+	// actionAbort stops the worker, retrying cannot succeed without a
+	// configuration change
+	actionAbort
+)
+
+func (a action) String() string {
+	switch a {
+	case actionBackoff:
+		return "backoff"
+	case actionQuarantine:
+		return "quarantine"
+	case actionAbort:
+		return "abort"
+	}
+	return "retry"
+}
+
+const (
+	// rateLimitBackoff is the wait after the venue rejects a request for
+	// exceeding its limits
+	rateLimitBackoff = time.Minute
+	// maxRetryDelay caps the delay between retries of transient errors
+	maxRetryDelay = time.Minute
+)
+
+// Binance API error codes, see
+// https://github.com/binance-exchange/binance-official-api-docs/blob/master/errors.md
+var errorActions = map[int64]action{
+	-1003: actionBackoff,    // TOO_MANY_REQUESTS, also sent with the 418 ban
+	-1015: actionBackoff,    // TOO_MANY_ORDERS
+	-1121: actionQuarantine, // INVALID_SYMBOL
+	-1002: actionAbort,      // UNAUTHORIZED
+	-1022: actionAbort,      // INVALID_SIGNATURE
+	-1120: actionAbort,      // BAD_INTERVAL
+	-2014: actionAbort,      // BAD_API_KEY_FMT
+	-2015: actionAbort,      // REJECTED_MBX_KEY
+}
+
+// classifyError tells what to do about an error returned by a request to the
+// venue.  The errors without a known code, such as network errors, -1000
+// UNKNOWN or -1007 TIMEOUT, are retried.
+func classifyError(err error) action {
+	apiErr, ok := err.(*binance.APIError)
+	if !ok {
+		return actionRetry
+	}
+	if a, ok := errorActions[apiErr.Code]; ok {
+		return a
+	}
+	return actionRetry
+}
+
+// retryDelay is the wait before retrying after n consecutive transient
+// errors, doubling from a second up to maxRetryDelay
+func retryDelay(n int) time.Duration {
+	d := time.Second
+	for i := 1; i < n && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
+// handleFetchError acts on a failed request of symbol according to its
+// class, returning false when the worker must stop
+func (bn *BinanceFetcher) handleFetchError(symbol string, err error) bool {
+	switch classifyError(err) {
+	case actionQuarantine:
+		glog.Warningf("Quarantining %s, the venue does not know it: %v", symbol, err)
+		bn.paused[symbol] = true
+	case actionAbort:
+		glog.Errorf("Stopping, the request of %s was rejected: %v", symbol, err)
+		return false
+	case actionBackoff:
+		glog.Warningf("Rate limited while requesting %s, backing off for %v: %v", symbol, rateLimitBackoff, err)
+		bn.sleep(rateLimitBackoff)
+	default:
+		bn.transientErrors++
+		d := retryDelay(bn.transientErrors)
+		glog.Errorf("Response error for %s, retrying in %v: %v", symbol, d, err)
+		bn.sleep(d)
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestFetchErrors(c *C) {
+	config := `{
+        "symbols": ["XRP", "EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "%s_{base}"
+        }`
+	// XRP is not listed, EOS is fetched without waiting on it
+	worker := s.newWorker(c, newFixtureClient(c, "EOSBNB"), fmt.Sprintf(config, "QUARANTINE"))
+	worker.Run()
+	c.Assert(worker.paused, DeepEquals, map[string]bool{"XRP": true})
+	c.Assert(readBucket(c, "QUARANTINE_EOS/1Min/OHLCV").Len() > 0, Equals, true)
+
+	// a rejected key stops the worker at once
+	worker = s.newWorker(c, &errorClient{&binance.APIError{Code: -2015, Message: "Invalid API-key"}}, fmt.Sprintf(config, "ABORT"))
+	worker.Run()
+	last, err := findLastTimestamp("EOS", io.NewTimeBucketKey("ABORT_EOS/1Min/OHLCV"))
+	c.Assert(err, IsNil)
+	c.Assert(last.IsZero(), Equals, true)
+}
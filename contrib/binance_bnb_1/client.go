@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+
+	binance "github.com/adshao/go-binance"
+)
+
+// klinesClient is the part of the Binance API the fetcher depends on
+type klinesClient interface {
+	// Klines returns the candles of symbol opened between startTime and
+	// endTime in milliseconds.  A zero bound is left to the API default.
+	Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error)
+}
+
+// binanceClient implements klinesClient with go-binance
+type binanceClient struct {
+	*binance.Client
+}
+
+func (c *binanceClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	service := c.NewKlinesService().Symbol(symbol).Interval(interval)
+	if startTime > 0 {
+		service = service.StartTime(startTime)
+	}
+	if endTime > 0 {
+		service = service.EndTime(endTime)
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	return service.Do(ctx)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSimulatedTime(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start.Add(10 * time.Hour)}
+	client := &clockClient{&fixtureClient{klines: map[string][]*binance.Kline{
+		"EOSBNB": syntheticKlines(start, 11*60, time.Minute),
+	}}, clk}
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 10:05",
+        "bucket_name_template": "CLOCK_{base}"
+        }`)
+	worker.clock = clk
+	worker.Run()
+
+	// two backfill passes of 300 candles, then a poll as each candle closes
+	c.Assert(clk.slept, DeepEquals, []time.Duration{
+		10 * time.Second, 10 * time.Second,
+		40 * time.Second, time.Minute, time.Minute, time.Minute, time.Minute,
+	})
+	c.Assert(clk.Now(), Equals, start.Add(10*time.Hour+5*time.Minute))
+	epoch := readBucket(c, "CLOCK_EOS/1Min/OHLCV").GetEpoch()
+	c.Assert(epoch, HasLen, 10*60+5)
+	c.Assert(epoch[len(epoch)-1], Equals, start.Add(10*time.Hour+4*time.Minute).Unix())
+}
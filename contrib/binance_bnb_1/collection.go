@@ -0,0 +1,84 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// collectionStatus is the collection health of a symbol, written to its
+// STATUS bucket so that it can be charted from marketstore itself
+type collectionStatus struct {
+	// lastSuccess is the time of the last successful klines request
+	lastSuccess time.Time
+	errors      int64
+	rowsWritten int64
+	// rejected counts the candles out of the sanity bounds
+	rejected int64
+	// emptyIntervals counts the live passes in a row without candles, see
+	// trackQuiet
+	emptyIntervals int
+	quiet          bool
+}
+
+// collectionStatusKey is the bucket the collection status of symbol is
+// written to, e.g. BINANCE_BNB_EOS/1Min/STATUS
+func (bn *BinanceFetcher) collectionStatusKey(symbol string) *io.TimeBucketKey {
+	return io.NewTimeBucketKey(bn.bucketName(symbol) + "/1Min/STATUS")
+}
+
+// recordFetch counts the outcome of a klines request of symbol
+func (bn *BinanceFetcher) recordFetch(symbol string, now time.Time, err error) {
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	st := bn.collectionStatus(symbol)
+	if err != nil {
+		st.errors++
+		return
+	}
+	st.lastSuccess = now
+}
+
+// collectionStatus returns the status of symbol, bn.mu must be held
+func (bn *BinanceFetcher) collectionStatus(symbol string) *collectionStatus {
+	st, ok := bn.collectionStatuses[symbol]
+	if !ok {
+		st = &collectionStatus{}
+		bn.collectionStatuses[symbol] = st
+	}
+	return st
+}
+
+// writeCollectionStatuses writes a row per symbol to the STATUS buckets once
+// every status_interval, with the minute of now as Epoch
+func (bn *BinanceFetcher) writeCollectionStatuses(now time.Time) {
+	if bn.statusInterval <= 0 || now.Sub(bn.statusWrittenAt) < bn.statusInterval {
+		return
+	}
+	if err := bn.store.ready(); err != nil {
+		glog.Errorf("Cannot write the collection status: %v", err)
+		return
+	}
+	bn.statusWrittenAt = now
+
+	csm := io.NewColumnSeriesMap()
+	bn.mu.Lock()
+	for _, symbol := range bn.symbols {
+		st := bn.collectionStatus(symbol)
+		var lastSuccess int64
+		if !st.lastSuccess.IsZero() {
+			lastSuccess = st.lastSuccess.Unix()
+		}
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{now.Truncate(time.Minute).Unix()})
+		cs.AddColumn("LastSuccessEpoch", []int64{lastSuccess})
+		cs.AddColumn("ErrorCount", []int64{st.errors})
+		cs.AddColumn("RowsWritten", []int64{st.rowsWritten})
+		csm.AddColumnSeries(*bn.collectionStatusKey(symbol), cs)
+	}
+	bn.mu.Unlock()
+	if err := bn.store.write(csm); err != nil {
+		glog.Errorf("Cannot write the collection status: %v", err)
+	}
+}
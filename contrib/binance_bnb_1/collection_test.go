@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/marketstore/planner"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestCollectionStatus(c *C) {
+	config := `{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "%s_{base}"%s
+        }`
+	_, err := NewBgWorker(getConfig(fmt.Sprintf(config, "COLLECTION", `, "status_interval": "10s"`)))
+	c.Assert(err, NotNil)
+
+	// off by default
+	worker := s.newWorker(c, newFixtureClient(c, "TRXBNB"), fmt.Sprintf(config, "NOSTATUS", ""))
+	worker.Run()
+	cs, err := readRange(worker.collectionStatusKey("TRX"), planner.MinEpoch, planner.MaxEpoch)
+	c.Assert(err, IsNil)
+	c.Assert(cs, IsNil)
+	c.Assert(worker.collectionStatuses["TRX"].lastSuccess.IsZero(), Equals, false)
+
+	before := time.Now().UTC()
+	worker = s.newWorker(c, newFixtureClient(c, "TRXBNB"), fmt.Sprintf(config, "COLLECTION", `, "status_interval": "1m"`))
+	worker.recordFetch("TRX", before, errors.New("timeout"))
+	worker.Run()
+	stored := readBucket(c, "COLLECTION_TRX/1Min/OHLCV").Len()
+
+	status := readBucket(c, "COLLECTION_TRX/1Min/STATUS")
+	c.Assert(status.Len(), Equals, 1)
+	c.Assert(status.GetEpoch()[0] >= before.Truncate(time.Minute).Unix(), Equals, true)
+	c.Assert(status.GetByName("LastSuccessEpoch").([]int64)[0] >= before.Unix(), Equals, true)
+	c.Assert(status.GetByName("ErrorCount").([]int64)[0], Equals, int64(1))
+	c.Assert(status.GetByName("RowsWritten").([]int64)[0], Equals, int64(stored))
+}
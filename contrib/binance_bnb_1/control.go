@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// suspension pauses the Run loop of a worker between passes, e.g. during a
+// storage upgrade, keeping its resolved symbols and state
+type suspension struct {
+	mu        sync.Mutex
+	suspended bool
+	// resumed is closed by resume
+	resumed chan struct{}
+}
+
+// suspend pauses the worker at the start of its next pass, returning false
+// if it is paused already
+func (bn *BinanceFetcher) suspend() bool {
+	bn.suspension.mu.Lock()
+	defer bn.suspension.mu.Unlock()
+	if bn.suspension.suspended {
+		return false
+	}
+	bn.suspension.suspended = true
+	bn.suspension.resumed = make(chan struct{})
+	setGauge(bn.metricKey("paused"), 1)
+	glog.Infof("Pausing the collection of %s candles", bn.baseTimeframe.String)
+	return true
+}
+
+// resume continues a paused worker, returning false if it is not paused
+func (bn *BinanceFetcher) resume() bool {
+	bn.suspension.mu.Lock()
+	defer bn.suspension.mu.Unlock()
+	if !bn.suspension.suspended {
+		return false
+	}
+	bn.suspension.suspended = false
+	close(bn.suspension.resumed)
+	setGauge(bn.metricKey("paused"), 0)
+	glog.Infof("Resuming the collection of %s candles", bn.baseTimeframe.String)
+	return true
+}
+
+// waitResumed blocks while the worker is paused.  It returns whether it was,
+// and false for ok if the server shuts down meanwhile.
+func (bn *BinanceFetcher) waitResumed() (waited, ok bool) {
+	bn.suspension.mu.Lock()
+	suspended, resumed := bn.suspension.suspended, bn.suspension.resumed
+	bn.suspension.mu.Unlock()
+	if !suspended {
+		return false, true
+	}
+	glog.Infof("Paused")
+	select {
+	case <-resumed:
+		return true, true
+	case <-bn.shutdown:
+		return true, false
+	}
+}
+
+// resumePoint reads the last stored candle of each symbol again, as the
+// buckets may have changed while the worker was paused, and returns the
+// earliest of them, or the zero time if none is stored
+func (bn *BinanceFetcher) resumePoint(symbols []string) time.Time {
+	from := time.Time{}
+	for _, symbol := range symbols {
+		tbk := bn.tbkFor(symbol)
+		last, err := lastStoredTime(bn.store, tbk)
+		if err != nil {
+			glog.Errorf("Cannot read the last timestamp of %s: %v", symbol, err)
+			continue
+		}
+		if last.IsZero() {
+			continue
+		}
+		bn.mu.Lock()
+		bn.lastWritten[symbol] = last.Unix()
+		bn.mu.Unlock()
+		if from.IsZero() || last.Before(from) {
+			from = last
+		}
+	}
+	return from
+}
+
+// controlPath is where a worker is paused or resumed, e.g.
+//
+//	curl -X POST 'localhost:5993/binance/bnb/pause?timeframe=1Min'
+//	curl -X POST 'localhost:5993/binance/bnb/resume?timeframe=1Min'
+func controlPath(quote, action string) string {
+	return "/binance/" + strings.ToLower(quote) + "/" + action
+}
+
+// registerControl installs the pause and resume handlers on the default HTTP
+// mux, for the workers registered by registerBackfills
+func registerControl(bn *BinanceFetcher) {
+	registerHandler(controlPath(bn.baseCurrency, "pause"), handleControl((*BinanceFetcher).suspend))
+	registerHandler(controlPath(bn.baseCurrency, "resume"), handleControl((*BinanceFetcher).resume))
+}
+
+func handleControl(action func(*BinanceFetcher) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		bn, timeframe := workerFor(r)
+		if bn == nil {
+			http.Error(w, fmt.Sprintf("no worker for timeframe %q", timeframe), http.StatusNotFound)
+			return
+		}
+		if !action(bn) {
+			http.Error(w, "already in that state", http.StatusConflict)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestPauseResume(c *C) {
+	client := newFixtureClient(c, "TRXBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "PAUSE_{base}"
+        }`)
+	control := func(action string) int {
+		w := httptest.NewRecorder()
+		handleControl(map[string]func(*BinanceFetcher) bool{
+			"pause":  (*BinanceFetcher).suspend,
+			"resume": (*BinanceFetcher).resume,
+		}[action])(w, httptest.NewRequest("POST", controlPath("BNB", action), nil))
+		return w.Code
+	}
+	c.Assert(control("resume"), Equals, http.StatusConflict)
+	c.Assert(control("pause"), Equals, http.StatusOK)
+	c.Assert(control("pause"), Equals, http.StatusConflict)
+	c.Assert(metrics.Get(worker.metricKey("paused")).String(), Equals, "1")
+
+	done := make(chan struct{})
+	go func() {
+		worker.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+		c.Fatal("Run did not wait while paused")
+	case <-time.After(200 * time.Millisecond):
+	}
+	// nothing is fetched while paused
+	last, err := findLastTimestamp("TRX", io.NewTimeBucketKey("PAUSE_TRX/1Min/OHLCV"))
+	c.Assert(err, IsNil)
+	c.Assert(last.IsZero(), Equals, true)
+
+	c.Assert(control("resume"), Equals, http.StatusOK)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		c.Fatal("Run did not resume")
+	}
+	c.Assert(metrics.Get(worker.metricKey("paused")).String(), Equals, "0")
+	assertKlines(c, readBucket(c, "PAUSE_TRX/1Min/OHLCV"), client.klines["TRXBNB"])
+}
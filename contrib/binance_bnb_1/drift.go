@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// defaultClockDriftWarn is the offset between the local clock and the
+// server time above which a warning is logged
+const defaultClockDriftWarn = time.Second
+
+// driftClock is a clock corrected by the offset of the server time, so that
+// the candle boundaries the Run loop computes are those of the exchange
+type driftClock struct {
+	clock
+	mu     sync.Mutex
+	offset time.Duration
+}
+
+func (c *driftClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock.Now().Add(c.offset)
+}
+
+func (c *driftClock) correct(residual time.Duration) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset += residual
+	return c.offset
+}
+
+// observeServerTime compares the serverTime of an exchangeInfo response, in
+// milliseconds, with the local time halfway through the request sent and
+// received at.  The offset is logged above clock_drift_warn, published, and
+// applied to the clock of the worker with correct_clock_drift.
+func (bn *BinanceFetcher) observeServerTime(serverTime int64, sent, received time.Time) {
+	if serverTime <= 0 {
+		return
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	offset := time.Unix(0, serverTime*int64(time.Millisecond)).Sub(local)
+	// the times of a corrected clock only show what is left to correct
+	if c, ok := bn.clock.(*driftClock); ok {
+		offset = c.correct(offset)
+	}
+	bn.clockOffset = offset
+	setGauge(bn.metricKey("clock_offset_ms"), int64(offset/time.Millisecond))
+	if bn.clockDriftWarn > 0 && (offset > bn.clockDriftWarn || offset < -bn.clockDriftWarn) {
+		glog.Warningf("The local clock is off the %s server time by %v", bn.venue.bucketPrefix, offset)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestClockDrift(c *C) {
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["TRX"]}`)
+	sent := time.Now().Truncate(time.Millisecond)
+	worker.observeServerTime(timeToMillis(sent.Add(5*time.Second)), sent, sent.Add(time.Second))
+	c.Assert(worker.clockOffset, Equals, 4500*time.Millisecond)
+	// the clock is left alone
+	c.Assert(worker.clock.Now().Sub(time.Now()) < time.Second, Equals, true)
+
+	worker = s.newWorker(c, newFixtureClient(c), `{"symbols": ["TRX"], "correct_clock_drift": true}`)
+	worker.observeServerTime(timeToMillis(sent.Add(5*time.Second)), sent, sent)
+	c.Assert(worker.clock.Now().Sub(time.Now()) > 4*time.Second, Equals, true)
+	// a corrected clock has no residual offset
+	sent = worker.clock.Now()
+	worker.observeServerTime(timeToMillis(sent), sent, sent)
+	c.Assert(worker.clockOffset > 4*time.Second, Equals, true)
+	c.Assert(worker.clock.Now().Sub(time.Now()) > 4*time.Second, Equals, true)
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["TRX"], "clock_drift_warn": "soon"}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// envPattern matches the references to environment variables in the config,
+// e.g. ${BINANCE_REMOTE_ENDPOINT}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv returns a copy of the config with the environment variables
+// referenced in its strings, at any depth, replaced by their values, so that
+// secrets stay out of the config file.  A variable that is not set is an
+// error.  Strings without references are left as they are.
+func expandEnv(conf map[string]interface{}) (map[string]interface{}, error) {
+	expanded, err := expandEnvValue(conf)
+	if err != nil {
+		return nil, err
+	}
+	return expanded.(map[string]interface{}), nil
+}
+
+func expandEnvValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		var err error
+		s := envPattern.ReplaceAllStringFunc(v, func(ref string) string {
+			name := envPattern.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok && err == nil {
+				err = fmt.Errorf("config references the unset environment variable %s", name)
+			}
+			return value
+		})
+		return s, err
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			expanded, err := expandEnvValue(value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = expanded
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			expanded, err := expandEnvValue(value)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = expanded
+		}
+		return s, nil
+	}
+	return v, nil
+}
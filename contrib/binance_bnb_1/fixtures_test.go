@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+var _ = Suite(&RunTestSuite{})
+
+// RunTestSuite drives the fetcher against recorded Binance responses
+// and reads the written buckets back through the planner.
+type RunTestSuite struct {
+	rootDir string
+}
+
+func (s *RunTestSuite) SetUpSuite(c *C) {
+	s.rootDir = filepath.Join(c.MkDir(), "mktsdb")
+	os.MkdirAll(s.rootDir, 0777)
+	executor.NewInstanceSetup(s.rootDir, true, true, false, false)
+}
+
+// fixtureClient serves klines recorded in testdata/klines_<SYMBOL>_1m.json
+type fixtureClient struct {
+	klines map[string][]*binance.Kline
+}
+
+func newFixtureClient(c *C, pairs ...string) *fixtureClient {
+	f := &fixtureClient{klines: map[string][]*binance.Kline{}}
+	for _, pair := range pairs {
+		f.klines[pair] = loadKlines(c, filepath.Join("testdata", "klines_"+pair+"_1m.json"))
+	}
+	return f
+}
+
+func (f *fixtureClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	all, ok := f.klines[symbol]
+	if !ok {
+		return nil, &binance.APIError{Code: -1121, Message: "Invalid symbol."}
+	}
+	klines := []*binance.Kline{}
+	for _, k := range all {
+		if k.OpenTime < startTime || (endTime > 0 && k.OpenTime > endTime) {
+			continue
+		}
+		klines = append(klines, k)
+		// the API default limit
+		if len(klines) == 500 {
+			break
+		}
+	}
+	return klines, nil
+}
+
+// loadKlines parses the array-of-arrays kline format returned by /klines
+func loadKlines(c *C, path string) []*binance.Kline {
+	f, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	var raw [][]interface{}
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	c.Assert(dec.Decode(&raw), IsNil)
+
+	klines := make([]*binance.Kline, 0, len(raw))
+	for _, item := range raw {
+		openTime, _ := item[0].(json.Number).Int64()
+		closeTime, _ := item[6].(json.Number).Int64()
+		tradeNum, _ := item[8].(json.Number).Int64()
+		klines = append(klines, &binance.Kline{
+			OpenTime:                 openTime,
+			Open:                     item[1].(string),
+			High:                     item[2].(string),
+			Low:                      item[3].(string),
+			Close:                    item[4].(string),
+			Volume:                   item[5].(string),
+			CloseTime:                closeTime,
+			QuoteAssetVolume:         item[7].(string),
+			TradeNum:                 tradeNum,
+			TakerBuyBaseAssetVolume:  item[9].(string),
+			TakerBuyQuoteAssetVolume: item[10].(string),
+		})
+	}
+	return klines
+}
+
+func (s *RunTestSuite) newWorker(c *C, client klinesClient, config string) *BinanceFetcher {
+	ret, err := NewBgWorker(getConfig(config))
+	c.Assert(err, IsNil)
+	worker := ret.(*BinanceFetcher)
+	worker.client = client
+	// keep Run from checking symbol statuses on the exchange
+	worker.statusRefreshedAt = time.Now().UTC()
+	return worker
+}
+
+func readBucket(c *C, key string) *io.ColumnSeries {
+	tbk := io.NewTimeBucketKey(key)
+	q := planner.NewQuery(executor.ThisInstance.CatalogDir)
+	q.AddTargetKey(tbk)
+	q.SetRange(planner.MinEpoch, planner.MaxEpoch)
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	reader, err := executor.NewReader(parsed)
+	c.Assert(err, IsNil)
+	csm, _, err := reader.Read()
+	c.Assert(err, IsNil)
+	cs := csm[*tbk]
+	c.Assert(cs, NotNil)
+	return cs
+}
+
+func parseFloat(c *C, str string) float64 {
+	f, err := strconv.ParseFloat(str, 64)
+	c.Assert(err, IsNil)
+	return f
+}
+
+// assertKlines checks the bucket holds exactly the klines, in ascending time order
+func assertKlines(c *C, cs *io.ColumnSeries, klines []*binance.Kline) {
+	c.Assert(cs.Len(), Equals, len(klines))
+	epoch := cs.GetEpoch()
+	open := cs.GetByName("Open").([]float64)
+	high := cs.GetByName("High").([]float64)
+	low := cs.GetByName("Low").([]float64)
+	close := cs.GetByName("Close").([]float64)
+	volume := cs.GetByName("Volume").([]float64)
+	for i, k := range klines {
+		if i > 0 {
+			c.Assert(epoch[i] > epoch[i-1], Equals, true)
+		}
+		c.Assert(epoch[i], Equals, k.OpenTime/1000)
+		c.Assert(open[i], Equals, parseFloat(c, k.Open))
+		c.Assert(high[i], Equals, parseFloat(c, k.High))
+		c.Assert(low[i], Equals, parseFloat(c, k.Low))
+		c.Assert(close[i], Equals, parseFloat(c, k.Close))
+		c.Assert(volume[i], Equals, parseFloat(c, k.Volume))
+	}
+}
+
+// syntheticKlines returns n candles of duration d from start
+func syntheticKlines(start time.Time, n int, d time.Duration) []*binance.Kline {
+	klines := make([]*binance.Kline, 0, n)
+	for i := 0; i < n; i++ {
+		openTime := timeToMillis(start.Add(time.Duration(i) * d))
+		klines = append(klines, &binance.Kline{
+			OpenTime: openTime, CloseTime: openTime + int64(d/time.Millisecond) - 1,
+			Open: "1", High: "2", Low: "0.5", Close: "1.5", Volume: "10",
+			QuoteAssetVolume: "15", TakerBuyBaseAssetVolume: "5", TakerBuyQuoteAssetVolume: "7.5",
+		})
+	}
+	return klines
+}
+
+// fakeClock advances by the durations slept instead of waiting
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Sleep(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.slept = append(f.slept, d)
+	f.now = f.now.Add(d)
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.Sleep(d)
+	ch := make(chan time.Time, 1)
+	ch <- f.Now()
+	return ch
+}
+
+// clockClient only returns the candles opened by the time of the clock
+type clockClient struct {
+	*fixtureClient
+	clock clock
+}
+
+func (c *clockClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	now := timeToMillis(c.clock.Now())
+	if endTime == 0 || endTime > now {
+		endTime = now
+	}
+	return c.fixtureClient.Klines(ctx, symbol, interval, startTime, endTime)
+}
+
+// requestRecorder records the time range of each request
+type requestRecorder struct {
+	klinesClient
+	mu     sync.Mutex
+	ranges [][2]int64
+}
+
+func (r *requestRecorder) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	r.mu.Lock()
+	r.ranges = append(r.ranges, [2]int64{startTime, endTime})
+	r.mu.Unlock()
+	return r.klinesClient.Klines(ctx, symbol, interval, startTime, endTime)
+}
+
+// badWindowClient fails the requests of symbol starting at startTime
+type badWindowClient struct {
+	klinesClient
+	symbol    string
+	startTime int64
+}
+
+func (b *badWindowClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	if symbol == b.symbol && startTime == b.startTime {
+		return nil, errors.New("connection reset by peer")
+	}
+	return b.klinesClient.Klines(ctx, symbol, interval, startTime, endTime)
+}
+
+// errorClient fails every request with err
+type errorClient struct {
+	err error
+}
+
+func (e *errorClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	return nil, e.err
+}
+
+// shutdownClient starts the shutdown on the first request
+type shutdownClient struct {
+	klinesClient
+	shutdown chan struct{}
+}
+
+func (s *shutdownClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	select {
+	case <-s.shutdown:
+	default:
+		close(s.shutdown)
+	}
+	return s.klinesClient.Klines(ctx, symbol, interval, startTime, endTime)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// backfillForward catches each symbol up from start with requests that omit
+// endTime, so that Binance returns up to defaultKlinesLimit candles from the
+// cursor, and advances the cursor past the last candle returned.  It stops at
+// the forming candle, or query_end, and returns the earliest cursor of the
+// symbols for the Run loop to continue from, or false if the worker must
+// stop.  In the oneshot mode the candles closing after runStart are left out.
+func (bn *BinanceFetcher) backfillForward(symbols []string, start, runStart time.Time) (time.Time, bool) {
+	frontier := time.Time{}
+	for _, symbol := range symbols {
+		interval := bn.intervalOf(symbol)
+		cursor := bn.skipStored(symbol, bn.symbolStart(symbol, start))
+		for !bn.paused[symbol] {
+			if !bn.finishPassOnShutdown && bn.shuttingDown() {
+				return frontier, false
+			}
+			now := bn.clock.Now().UTC()
+			rates, err := bn.klines(context.Background(), symbol, interval, timeToMillis(cursor), 0)
+			bn.recordFetch(symbol, now, err)
+			if err != nil {
+				if !bn.handleFetchError(symbol, err) {
+					return frontier, false
+				}
+				continue
+			}
+			bn.transientErrors = 0
+
+			closedAt := now
+			if bn.mode == modeOneshot && runStart.Before(now) {
+				closedAt = runStart
+			}
+			closed, _ := closedRates(rates, closedAt)
+			reachedEnd := false
+			if !bn.queryEnd.IsZero() {
+				for i, rate := range closed {
+					if rate.OpenTime > timeToMillis(bn.queryEnd) {
+						closed, reachedEnd = closed[:i], true
+						break
+					}
+				}
+			}
+			if len(closed) == 0 {
+				break
+			}
+			cs, err := bn.toColumnSeries(symbol, closed, false)
+			if err != nil {
+				glog.Errorf("Stopping on the conversion error for %s: %v", symbol, err)
+				return frontier, false
+			}
+			if cs != nil {
+				if err := bn.write(symbol, cs, false); err != nil {
+					if !bn.writeFailed(symbol, err) {
+						return frontier, false
+					}
+					// the cursor stays on the dropped batch
+					break
+				}
+			}
+			cursor = time.Unix(millisToEpochSec(closed[len(closed)-1].OpenTime), 0).UTC().Add(bn.timeframeOf(symbol).Duration)
+			bn.logProgress(symbol, cursor)
+			// fewer candles than the limit end at the forming one
+			if reachedEnd || len(closed) < len(rates) || len(rates) < defaultKlinesLimit {
+				break
+			}
+			if !bn.sleep(bn.backfillSleep) {
+				return frontier, false
+			}
+		}
+		// a quarantined symbol does not hold the others back
+		if bn.paused[symbol] {
+			continue
+		}
+		if frontier.IsZero() || cursor.Before(frontier) {
+			frontier = cursor
+		}
+	}
+	return frontier, true
+}
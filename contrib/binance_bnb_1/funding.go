@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// premiumIndexWeight is the request weight of /premiumIndex for all symbols
+const premiumIndexWeight = 10
+
+// defaultFundingInterval is how often the premium index is requested with
+// funding
+const defaultFundingInterval = time.Minute
+
+// PremiumIndex is the mark price, index price and funding rate of a
+// perpetual futures symbol
+type PremiumIndex struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	IndexPrice      string `json:"indexPrice"`
+	LastFundingRate string `json:"lastFundingRate"`
+	NextFundingTime int64  `json:"nextFundingTime"`
+}
+
+// fundingKey is the bucket the premium index of symbol is written to, e.g.
+// BINANCEFUTURES_EOS/1Min/FUNDING.  Its records are variable length, so that
+// the Epoch of each row is the time it was taken, and hold the float64
+// MarkPrice, IndexPrice and FundingRate, and the int64 NextFundingTime in
+// seconds.
+func (bn *BinanceFetcher) fundingKey(symbol string) *io.TimeBucketKey {
+	return io.NewTimeBucketKey(bn.bucketName(symbol) + "/" + bn.timeframeOf(symbol).String + "/FUNDING")
+}
+
+// collectFunding writes the premium index of each symbol to its FUNDING
+// bucket once every funding_interval, with now as Epoch, when funding is set.
+// All symbols are requested at once.
+func (bn *BinanceFetcher) collectFunding(now time.Time) {
+	if bn.fundingInterval <= 0 || now.Sub(bn.fundingFetchedAt) < bn.fundingInterval {
+		return
+	}
+	if err := bn.store.ready(); err != nil {
+		glog.Errorf("Cannot write the premium index: %v", err)
+		return
+	}
+	bn.limiter.reserve(premiumIndexWeight)
+	var indexes []PremiumIndex
+	if err := getJson(bn.venue.jsonHTTPClient(), bn.venue.premiumIndexURL(), &indexes); err != nil {
+		glog.Errorf("Binance /premiumIndex API error: %v", err)
+		return
+	}
+	bn.fundingFetchedAt = now
+
+	bySymbol := map[string]PremiumIndex{}
+	for _, p := range indexes {
+		bySymbol[p.Symbol] = p
+	}
+	csm := io.NewColumnSeriesMap()
+	for _, symbol := range bn.symbols {
+		p, ok := bySymbol[symbol+bn.baseCurrency]
+		if !ok {
+			continue
+		}
+		values := make([]float64, 3)
+		var err error
+		for i, str := range []string{p.MarkPrice, p.IndexPrice, p.LastFundingRate} {
+			if values[i], err = strconv.ParseFloat(str, 64); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			glog.Errorf("Invalid premium index of %s: %v", symbol, err)
+			continue
+		}
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{now.Unix()})
+		cs.AddColumn("MarkPrice", []float64{values[0]})
+		cs.AddColumn("IndexPrice", []float64{values[1]})
+		cs.AddColumn("FundingRate", []float64{values[2]})
+		cs.AddColumn("NextFundingTime", []int64{p.NextFundingTime / 1000})
+		csm.AddColumnSeries(*bn.fundingKey(symbol), cs)
+	}
+	if !csm.IsEmpty() {
+		if err := bn.store.write(csm); err != nil {
+			glog.Errorf("Cannot write the premium index: %v", err)
+		}
+	}
+}
+
+// fundingConfig returns funding_interval, 0 without funding, validating
+// funding against the venue
+func fundingConfig(config *FetcherConfig, v venue) (time.Duration, error) {
+	if !config.Funding {
+		return 0, nil
+	}
+	if v.premiumIndexPath == "" {
+		return 0, fmt.Errorf("funding is only supported by the binancefutures venue")
+	}
+	if config.FundingInterval == "" {
+		return defaultFundingInterval, nil
+	}
+	d, err := time.ParseDuration(config.FundingInterval)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("invalid funding_interval %q, must be at least 1s", config.FundingInterval)
+	}
+	return d, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestFunding(c *C) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		c.Check(r.URL.Path, Equals, "/fapi/v1/premiumIndex")
+		w.Write([]byte(`[
+			{"symbol": "EOSUSDT", "markPrice": "0.4215", "indexPrice": "0.4212", "lastFundingRate": "0.0001", "nextFundingTime": 1533110400000},
+			{"symbol": "TRXUSDT", "markPrice": "bad", "indexPrice": "0.02", "lastFundingRate": "0.0001", "nextFundingTime": 1533110400000}
+		]`))
+	}))
+	defer server.Close()
+
+	config := `{"symbols": ["EOS", "TRX"], "base_currency": "USDT", "venue": "binancefutures", "bucket_name_template": "FUNDING_{base}"%s}`
+	// off by default
+	worker := s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, ""))
+	worker.venue.baseURL = server.URL
+	worker.collectFunding(time.Now().UTC())
+	c.Assert(requests, Equals, 0)
+
+	worker = s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, `, "funding": true, "funding_interval": "30s"`))
+	worker.venue.baseURL = server.URL
+	now := time.Date(2018, time.August, 1, 0, 0, 10, 0, time.UTC)
+	worker.collectFunding(now)
+	worker.collectFunding(now.Add(10 * time.Second))
+	worker.collectFunding(now.Add(30 * time.Second))
+	c.Assert(requests, Equals, 2)
+
+	eos := readBucket(c, "FUNDING_EOS/1Min/FUNDING")
+	c.Assert(eos.GetEpoch(), DeepEquals, []int64{now.Unix(), now.Add(30 * time.Second).Unix()})
+	c.Assert(eos.GetByName("MarkPrice"), DeepEquals, []float64{0.4215, 0.4215})
+	c.Assert(eos.GetByName("IndexPrice"), DeepEquals, []float64{0.4212, 0.4212})
+	c.Assert(eos.GetByName("FundingRate"), DeepEquals, []float64{0.0001, 0.0001})
+	c.Assert(eos.GetByName("NextFundingTime"), DeepEquals, []int64{1533110400, 1533110400})
+	c.Assert(worker.BucketKeys(), HasLen, 4)
+
+	for _, config := range []string{
+		`{"symbols": ["EOS"], "funding": true}`,
+		`{"symbols": ["EOS"], "funding": true, "venue": "binanceus"}`,
+		`{"symbols": ["EOS"], "funding": true, "venue": "binancefutures", "funding_interval": "10ms"}`,
+		`{"symbols": ["EOS"], "venue": "binancefutures", "attribute_group": "FUNDING"}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf(config))
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// gap is a window of candles of a symbol given up on after window_retries
+// failed requests, see windowFailed
+type gap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// windowFailed counts a failed backfill request of symbol between start and
+// end, and returns true once window_retries of them failed in a row, after
+// recording the window as a gap of the symbol so that the backfill goes on
+// past it.  Without window_retries the window is retried until it succeeds.
+func (bn *BinanceFetcher) windowFailed(symbol string, start, end time.Time) bool {
+	if bn.windowRetries <= 0 {
+		return false
+	}
+	bn.windowFailures[symbol]++
+	if bn.windowFailures[symbol] < bn.windowRetries {
+		return false
+	}
+	delete(bn.windowFailures, symbol)
+	glog.Errorf("Giving up on %s between %v and %v after %d failed requests, leaving a gap", symbol, start, end, bn.windowRetries)
+	bn.mu.Lock()
+	bn.gaps[symbol] = append(bn.gaps[symbol], gap{Start: start, End: end})
+	bn.mu.Unlock()
+	return true
+}
+
+// windowFetched resets the failed requests of symbol counted by windowFailed
+func (bn *BinanceFetcher) windowFetched(symbol string) {
+	delete(bn.windowFailures, symbol)
+}
+
+// skipGaps returns the time of the candle after the last gap of symbol if
+// start is within it, so that the window given up is not requested again
+func (bn *BinanceFetcher) skipGaps(symbol string, start time.Time) time.Time {
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	gaps := bn.gaps[symbol]
+	if len(gaps) == 0 {
+		return start
+	}
+	if after := gaps[len(gaps)-1].End.Add(bn.timeframeOf(symbol).Duration); start.Before(after) {
+		return after
+	}
+	return start
+}
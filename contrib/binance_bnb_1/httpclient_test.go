@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestProxy(c *C) {
+	info, err := ioutil.ReadFile(filepath.Join("testdata", "exchangeInfo.json"))
+	c.Assert(err, IsNil)
+	proxied := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write(info)
+	}))
+	defer proxy.Close()
+
+	worker := s.newWorker(c, newFixtureClient(c), fmt.Sprintf(`{"symbols": ["EOS"], "proxy_url": %q}`, proxy.URL))
+	worker.venue.baseURL = "http://binance.invalid"
+	var m ExchangeInfo
+	c.Assert(getJson(worker.venue.jsonHTTPClient(), worker.venue.exchangeInfoURL(), &m), IsNil)
+	c.Assert(m.Symbols, HasLen, 5)
+	// the go-binance requests go through it as well, the body is not klines
+	client := &binanceClient{worker.venue.newClient()}
+	client.Klines(context.Background(), "EOSBNB", "1m", 0, 0)
+	c.Assert(proxied, HasLen, 2)
+	c.Assert(proxied[0], Equals, "http://binance.invalid/api/v1/exchangeInfo")
+	c.Assert(strings.HasPrefix(proxied[1], "http://binance.invalid/api/v1/klines?"), Equals, true)
+
+	for _, config := range []string{
+		`{"symbols": ["EOS"], "proxy_url": "proxy:3128"}`,
+		`{"symbols": ["EOS"], "ca_file": "testdata/missing.pem"}`,
+		`{"symbols": ["EOS"], "ca_file": "testdata/exchangeInfo.json"}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf(config))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/alpacahq/marketstore/catalog"
+	"github.com/alpacahq/marketstore/executor"
+)
+
+var errExecutorNotInitialized = errors.New("marketstore executor not initialized")
+
+// catalogDir returns the catalog of the marketstore instance the plugin is
+// loaded into, or an error rather than a nil dereference if the executor is
+// not set up yet
+func catalogDir() (*catalog.Directory, error) {
+	if executor.ThisInstance == nil || executor.ThisInstance.CatalogDir == nil {
+		return nil, errExecutorNotInitialized
+	}
+	return executor.ThisInstance.CatalogDir, nil
+}
+
+// checkWriter returns an error if executor.WriteCSM cannot be called yet
+func checkWriter() error {
+	if _, err := catalogDir(); err != nil {
+		return err
+	}
+	if executor.ThisInstance.TXNPipe == nil || executor.ThisInstance.WALFile == nil {
+		return errExecutorNotInitialized
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/golang/glog"
+)
+
+// defaultCacheMaxBytes bounds the kline cache unless cache_max_bytes is set
+const defaultCacheMaxBytes = 100 << 20
+
+// klineCache keeps the klines of the backfill requests in a directory, a
+// JSON file per symbol, interval and window, so that a backfill run again
+// does not request them from the API.  Once it holds maxBytes, the files
+// least recently used are removed.
+type klineCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	size     int64
+}
+
+func newKlineCache(dir string, maxBytes int64) (*klineCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid cache_max_bytes %d", maxBytes)
+	}
+	k := &klineCache{dir: dir, maxBytes: maxBytes}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range files {
+		k.size += fi.Size()
+	}
+	return k, nil
+}
+
+// path is the file of the klines of the request
+func (k *klineCache) path(pair, interval string, startTime, endTime int64) string {
+	return filepath.Join(k.dir, fmt.Sprintf("%s_%s_%d_%d.json", pair, interval, startTime, endTime))
+}
+
+// get returns the cached klines of the request, false on a miss
+func (k *klineCache) get(pair, interval string, startTime, endTime int64) ([]*binance.Kline, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	path := k.path(pair, interval, startTime, endTime)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var rates []*binance.Kline
+	if err := json.Unmarshal(data, &rates); err != nil {
+		glog.Warningf("Ignoring the invalid cached klines %s: %v", path, err)
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return rates, true
+}
+
+// put caches the klines of the request, then evicts the files least recently
+// used beyond maxBytes
+func (k *klineCache) put(pair, interval string, startTime, endTime int64, rates []*binance.Kline) {
+	data, err := json.Marshal(rates)
+	if err != nil {
+		glog.Errorf("Cannot cache the klines of %s: %v", pair, err)
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := os.MkdirAll(k.dir, 0755); err != nil {
+		glog.Errorf("Cannot cache the klines of %s: %v", pair, err)
+		return
+	}
+	path := k.path(pair, interval, startTime, endTime)
+	if fi, err := os.Stat(path); err == nil {
+		k.size -= fi.Size()
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		glog.Errorf("Cannot cache the klines of %s: %v", pair, err)
+		return
+	}
+	k.size += int64(len(data))
+	if k.size > k.maxBytes {
+		k.evict()
+	}
+}
+
+// evict removes the files least recently used until the cache holds at most
+// maxBytes
+func (k *klineCache) evict() {
+	files, err := ioutil.ReadDir(k.dir)
+	if err != nil {
+		glog.Errorf("Cannot evict cached klines: %v", err)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	k.size = 0
+	for _, fi := range files {
+		k.size += fi.Size()
+	}
+	for _, fi := range files {
+		if k.size <= k.maxBytes {
+			return
+		}
+		if !strings.HasSuffix(fi.Name(), ".json") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(k.dir, fi.Name())); err != nil {
+			glog.Errorf("Cannot evict cached klines: %v", err)
+			return
+		}
+		k.size -= fi.Size()
+	}
+}
+
+// cachedKlines is klines through the kline cache with cache_dir.  Only the
+// windows whose candles are all closed are cached, as the others change.
+func (bn *BinanceFetcher) cachedKlines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	closed := endTime > 0 && !candleClose(time.Unix(0, endTime*int64(time.Millisecond)), bn.timeframeOf(symbol)).After(bn.clock.Now())
+	if bn.cache == nil || !closed {
+		return bn.klines(ctx, symbol, interval, startTime, endTime)
+	}
+	pair := symbol + bn.baseCurrency
+	if rates, ok := bn.cache.get(pair, interval, startTime, endTime); ok {
+		return rates, nil
+	}
+	rates, err := bn.klines(ctx, symbol, interval, startTime, endTime)
+	if err == nil {
+		bn.cache.put(pair, interval, startTime, endTime, rates)
+	}
+	return rates, err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestKlineCache(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	config := `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "%s_{base}",
+        "request_span": 20,
+        "advance_step": 20,
+        "cache_dir": %q%s
+        }`
+	run := func(name, dir, extra string) *requestRecorder {
+		client := &requestRecorder{klinesClient: &fixtureClient{klines: map[string][]*binance.Kline{
+			"EOSBNB": syntheticKlines(start, 61, time.Minute),
+		}}}
+		worker := s.newWorker(c, client, fmt.Sprintf(config, name, dir, extra))
+		worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+		worker.Run()
+		c.Assert(readBucket(c, name+"_EOS/1Min/OHLCV").GetEpoch(), HasLen, 61)
+		return client
+	}
+
+	dir := c.MkDir()
+	c.Assert(run("CACHEMISS", dir, "").ranges, HasLen, 3)
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 3)
+	var cached []*binance.Kline
+	data, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(data, &cached), IsNil)
+	c.Assert(cached, HasLen, 21)
+
+	// rebuilding the buckets does not request the API
+	c.Assert(run("CACHEHIT", dir, "").ranges, HasLen, 0)
+
+	// the files least recently used are evicted beyond cache_max_bytes
+	dir = c.MkDir()
+	c.Assert(run("CACHEBOUND", dir, `, "cache_max_bytes": 1`).ranges, HasLen, 3)
+	files, err = ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "cache_dir": "/tmp", "cache_max_bytes": -1}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// leaseTimeframe is the timeframe of the lease buckets.  Each renewal
+// overwrites the row of the day.
+const leaseTimeframe = "1D"
+
+// newLeaseOwner returns an identifier of the worker unlikely to be taken by
+// another one, in this process or another
+func newLeaseOwner() int64 {
+	r := rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())<<32))
+	return r.Int63()
+}
+
+// leaseKey returns the bucket of the lease on the bucket of symbol, e.g.
+// BINANCE_BNB_EOS/1D/OHLCV_1Min_LEASE for BINANCE_BNB_EOS/1Min/OHLCV
+func (bn *BinanceFetcher) leaseKey(symbol string) *io.TimeBucketKey {
+	return io.NewTimeBucketKey(bn.bucketName(symbol) + "/" + leaseTimeframe + "/" +
+		bn.attributeGroup + "_" + bn.baseTimeframe.String + "_LEASE")
+}
+
+// acquireLeases claims the buckets of the symbols until now plus the lease
+// TTL, failing if another worker holds an unexpired lease on one of them
+func (bn *BinanceFetcher) acquireLeases(now time.Time) error {
+	for _, symbol := range bn.symbols {
+		tbk := bn.leaseKey(symbol)
+		cs, err := bn.store.read(tbk, 0, math.MaxInt64, 1)
+		if err != nil {
+			return err
+		}
+		if cs == nil || cs.Len() == 0 {
+			continue
+		}
+		owner := cs.GetByName("Owner").([]int64)[0]
+		expires := cs.GetByName("Expires").([]int64)[0]
+		if owner != bn.leaseOwner && expires > now.Unix() {
+			return fmt.Errorf("%s is written by another worker until %v, its lease is in %s",
+				bn.bucketName(symbol), time.Unix(expires, 0).UTC(), tbk)
+		}
+	}
+	return bn.writeLeases(now, now.Add(bn.leaseTTL))
+}
+
+// writeLeases writes the leases of the symbols expiring at expires
+func (bn *BinanceFetcher) writeLeases(now, expires time.Time) error {
+	day := utils.TruncateToTimeframe(now.UTC(), utils.NewTimeframe(leaseTimeframe)).Unix()
+	csm := io.NewColumnSeriesMap()
+	for _, symbol := range bn.symbols {
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{day})
+		cs.AddColumn("Owner", []int64{bn.leaseOwner})
+		cs.AddColumn("Expires", []int64{expires.Unix()})
+		csm.AddColumnSeries(*bn.leaseKey(symbol), cs)
+	}
+	return bn.store.write(csm)
+}
+
+// holdLeases renews the leases every third of their TTL until stop is closed,
+// then releases them and closes released.  Another worker claiming the
+// buckets meanwhile is an error that stops the renewal.
+func (bn *BinanceFetcher) holdLeases(stop <-chan struct{}, released chan<- struct{}) {
+	defer close(released)
+	ticker := time.NewTicker(bn.leaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			if err := bn.writeLeases(bn.clock.Now(), time.Unix(0, 0)); err != nil {
+				glog.Errorf("Cannot release the leases: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := bn.acquireLeases(bn.clock.Now()); err != nil {
+				glog.Errorf("Cannot renew the leases: %v", err)
+				return
+			}
+		}
+	}
+}
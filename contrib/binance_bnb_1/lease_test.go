@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestLease(c *C) {
+	config := `{
+        "symbols": ["TRX"],
+        "base_timeframe": "%s",
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "LEASE_{base}",
+        "lease_ttl": "1m"
+        }`
+	worker := s.newWorker(c, newFixtureClient(c, "TRXBNB"), fmt.Sprintf(config, "1Min"))
+	_, err := NewBgWorker(getConfig(fmt.Sprintf(config, "1Min")))
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "LEASE_TRX is written by another worker"), Equals, true)
+	// another timeframe is another bucket
+	_, err = NewBgWorker(getConfig(fmt.Sprintf(config, "5Min")))
+	c.Assert(err, IsNil)
+
+	// released once Run returns
+	worker.Run()
+	second := s.newWorker(c, newFixtureClient(c, "TRXBNB"), fmt.Sprintf(config, "1Min"))
+
+	// the lease of a crashed worker expires
+	now := time.Now()
+	c.Assert(second.writeLeases(now, now.Add(-time.Second)), IsNil)
+	second.leaseOwner++
+	c.Assert(second.acquireLeases(now), IsNil)
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["TRX"], "lease_ttl": "1s"}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	binance "github.com/adshao/go-binance"
+)
+
+// keyedMutex serializes the holders of the same key, leaving the other keys
+// free to proceed
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock blocks until key is free and returns the function releasing it
+func (k *keyedMutex) lock(key string) (unlock func()) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*sync.Mutex{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		k.locks[key] = l
+	}
+	k.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// klines requests the candles of symbol, one request per symbol at a time
+// across the live loop and the requested backfills, and audits them.  A
+// request fails after request_timeout, to be retried like a network error.
+func (bn *BinanceFetcher) klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	defer bn.symbolRequests.lock(symbol)()
+	ctx = withRequestTimeout(ctx, bn.requestTimeout)
+	rates, err := bn.client.Klines(ctx, symbol+bn.baseCurrency, interval, startTime, endTime)
+	if err == nil && bn.audit != nil {
+		bn.audit.record(symbol, rates, bn.epochSource)
+	}
+	return rates, err
+}
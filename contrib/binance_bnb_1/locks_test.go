@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSymbolSerialization(c *C) {
+	client := &inFlightClient{klinesClient: newFixtureClient(c, "EOSBNB", "TRXBNB"), inFlight: map[string]int{}}
+	worker := s.newWorker(c, client, `{"symbols": ["EOS", "TRX"], "bucket_name_template": "SERIAL_{base}"}`)
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	// overlapping backfills of EOS wait on each other, not on TRX
+	var wg sync.WaitGroup
+	for _, symbol := range []string{"EOS", "EOS", "EOS", "TRX"} {
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+			worker.backfill(backfillRequest{symbol: symbol, start: start, end: start.Add(2 * time.Hour)})
+		}(symbol)
+	}
+	wg.Wait()
+	c.Assert(client.maxPerSymbol, Equals, 1)
+	c.Assert(client.maxTotal > 1, Equals, true)
+
+	epoch := readBucket(c, "SERIAL_EOS/1Min/OHLCV").GetEpoch()
+	for i := 1; i < len(epoch); i++ {
+		c.Assert(epoch[i] > epoch[i-1], Equals, true)
+	}
+}
+
+// inFlightClient records the most requests in flight at once, in total and
+// for a single symbol
+type inFlightClient struct {
+	klinesClient
+	mu           sync.Mutex
+	inFlight     map[string]int
+	total        int
+	maxTotal     int
+	maxPerSymbol int
+}
+
+func (f *inFlightClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	f.mu.Lock()
+	f.inFlight[symbol]++
+	f.total++
+	if f.inFlight[symbol] > f.maxPerSymbol {
+		f.maxPerSymbol = f.inFlight[symbol]
+	}
+	if f.total > f.maxTotal {
+		f.maxTotal = f.total
+	}
+	f.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	klines, err := f.klinesClient.Klines(ctx, symbol, interval, startTime, endTime)
+
+	f.mu.Lock()
+	f.inFlight[symbol]--
+	f.total--
+	f.mu.Unlock()
+	return klines, err
+}
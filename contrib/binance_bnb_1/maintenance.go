@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// systemStatusInterval is how often the system status is checked
+	// outside of maintenance
+	systemStatusInterval = time.Minute
+	// defaultMaintenanceSleep is the wait between the checks during
+	// maintenance without maintenance_sleep
+	defaultMaintenanceSleep = 5 * time.Minute
+	// systemStatusWeight is the request weight of /sapi/v1/system/status
+	systemStatusWeight = 1
+)
+
+// SystemStatus is the response of /sapi/v1/system/status, whose status is 0
+// normally and 1 during system maintenance
+type SystemStatus struct {
+	Status int    `json:"status"`
+	Msg    string `json:"msg"`
+}
+
+// systemStatus returns the system status of the venue
+func (bn *BinanceFetcher) systemStatus() (SystemStatus, error) {
+	bn.limiter.reserve(systemStatusWeight)
+	var status SystemStatus
+	err := getJson(bn.venue.jsonHTTPClient(), bn.venue.systemStatusURL(), &status)
+	return status, err
+}
+
+// waitMaintenance checks the system status of the venue with system_status
+// and, while it reports maintenance, sleeps maintenance_sleep between the
+// checks instead of requesting candles that would fail.  The maintenance
+// gauge is 1 meanwhile.  A failed check lets the pass go ahead.  It returns
+// false if the worker shuts down meanwhile.
+func (bn *BinanceFetcher) waitMaintenance() bool {
+	if !bn.checkSystemStatus || bn.clock.Now().Sub(bn.systemStatusAt) < systemStatusInterval {
+		return true
+	}
+	inMaintenance := false
+	for {
+		bn.systemStatusAt = bn.clock.Now()
+		status, err := bn.systemStatus()
+		if err != nil {
+			glog.Warningf("Cannot check the system status: %v", err)
+			break
+		}
+		if status.Status == 0 {
+			break
+		}
+		if !inMaintenance {
+			glog.Warningf("Exchange maintenance (%s), checking again every %v", status.Msg, bn.maintenanceSleep)
+			setGauge(bn.metricKey("maintenance"), 1)
+			inMaintenance = true
+		}
+		if !bn.sleep(bn.maintenanceSleep) {
+			return false
+		}
+	}
+	if inMaintenance {
+		glog.Infof("Exchange maintenance is over, resuming")
+		setGauge(bn.metricKey("maintenance"), 0)
+	}
+	return true
+}
+
+// maintenanceConfig returns maintenance_sleep, validating system_status
+// against the venue
+func maintenanceConfig(config *FetcherConfig, v venue) (time.Duration, error) {
+	if !config.SystemStatus {
+		return 0, nil
+	}
+	if v.systemStatusPath == "" {
+		return 0, fmt.Errorf("system_status is not supported by the venue")
+	}
+	if config.MaintenanceSleep == "" {
+		return defaultMaintenanceSleep, nil
+	}
+	d, err := time.ParseDuration(config.MaintenanceSleep)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid maintenance_sleep %q", config.MaintenanceSleep)
+	}
+	return d, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestMaintenance(c *C) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/sapi/v1/system/status")
+		requests++
+		if requests <= 2 {
+			w.Write([]byte(`{"status": 1, "msg": "system maintenance"}`))
+			return
+		}
+		w.Write([]byte(`{"status": 0, "msg": "normal"}`))
+	}))
+	defer server.Close()
+
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "system_status": true, "maintenance_sleep": "2m"}`)
+	worker.venue.baseURL = server.URL
+	clk := &fakeClock{now: time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)}
+	worker.clock = clk
+	c.Assert(worker.waitMaintenance(), Equals, true)
+	c.Assert(requests, Equals, 3)
+	c.Assert(clk.slept, DeepEquals, []time.Duration{2 * time.Minute, 2 * time.Minute})
+	c.Assert(metrics.Get(worker.metricKey("maintenance")).String(), Equals, "0")
+	// checked once a minute
+	c.Assert(worker.waitMaintenance(), Equals, true)
+	c.Assert(requests, Equals, 3)
+
+	for _, config := range []string{
+		`{"symbols": ["EOS"], "system_status": true, "maintenance_sleep": "0s"}`,
+		`{"symbols": ["EOS"], "system_status": true, "venue": "binancefutures"}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf(config))
+	}
+}
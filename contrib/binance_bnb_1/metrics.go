@@ -0,0 +1,41 @@
+package main
+
+import (
+	"expvar"
+)
+
+// metrics holds the fetcher gauges.  They are published through expvar, so
+// they show up on /debug/vars of the default HTTP mux next to /heartbeat.
+var metrics = metricsMap("binance")
+
+func metricsMap(name string) *expvar.Map {
+	// several Binance plugins can be loaded into the same process
+	if m, ok := expvar.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	return expvar.NewMap(name)
+}
+
+func setGauge(key string, value int64) {
+	v := new(expvar.Int)
+	v.Set(value)
+	metrics.Set(key, v)
+}
+
+// limiterState returns the state of the request weight limiter of the
+// worker, shared with the other workers of the venue
+func (bn *BinanceFetcher) limiterState() limiterState {
+	return bn.limiter.state()
+}
+
+// publishLimiterState publishes limiterState as the limiter metric
+func (bn *BinanceFetcher) publishLimiterState() {
+	metrics.Set(bn.metricKey("limiter"), expvar.Func(func() interface{} {
+		return bn.limiterState()
+	}))
+}
+
+// metricKey scopes a metric name to this worker's quote currency and timeframe
+func (bn *BinanceFetcher) metricKey(name string) string {
+	return bn.baseCurrency + "/" + bn.baseTimeframe.String + "/" + name
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"time"
+)
+
+// phase is the state of the Run loop
+type phase int
+
+const (
+	// phaseBackfill requests request_span intervals at a time from
+	// query_start, advancing by advance_step and pausing for backfill_sleep
+	// between the passes
+	phaseBackfill phase = iota
+	// phaseLive waits for each candle to close and requests the candles
+	// since the previous pass
+	phaseLive
+)
+
+func (p phase) String() string {
+	if p == phaseLive {
+		return "live"
+	}
+	return "backfill"
+}
+
+// nextPhase returns the phase of the pass ending at end.  The backfill turns
+// live once end is within catchup_tolerance intervals of now, and live never
+// turns back.
+func (bn *BinanceFetcher) nextPhase(p phase, end, now time.Time) phase {
+	if p == phaseLive {
+		return phaseLive
+	}
+	frontier := now.Add(-time.Duration(bn.catchupTolerance) * bn.passTimeframe().Duration)
+	if end.After(frontier) {
+		return phaseLive
+	}
+	return phaseBackfill
+}
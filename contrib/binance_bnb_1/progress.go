@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// progressLog reports how far a backfill got every progress_interval
+type progressLog struct {
+	interval time.Duration
+	// floor is the start of the backfill and startedAt when it started
+	floor     time.Time
+	startedAt time.Time
+	// last are the time and the fraction done of the last report of each
+	// symbol, the throughput since then gives the ETA
+	last map[string]progressMark
+}
+
+type progressMark struct {
+	at   time.Time
+	done float64
+}
+
+// startProgress starts reporting the progress of a backfill from floor
+func (bn *BinanceFetcher) startProgress(floor time.Time) {
+	bn.progress.floor = floor
+	bn.progress.startedAt = bn.clock.Now().UTC()
+	bn.progress.last = map[string]progressMark{}
+}
+
+// logProgress logs how far the backfill of symbol got, at being the time it
+// is fetched up to, at most once per progress_interval
+func (bn *BinanceFetcher) logProgress(symbol string, at time.Time) {
+	if line := bn.progressLine(symbol, at); line != "" {
+		glog.Info(line)
+	}
+}
+
+// progressLine returns the progress report of symbol, or "" if it is not
+// due yet
+func (bn *BinanceFetcher) progressLine(symbol string, at time.Time) string {
+	p := &bn.progress
+	if p.interval == 0 || p.floor.IsZero() {
+		return ""
+	}
+	now := bn.clock.Now().UTC()
+	mark, ok := p.last[symbol]
+	if !ok {
+		mark = progressMark{at: p.startedAt}
+	}
+	if now.Sub(mark.at) < p.interval {
+		return ""
+	}
+	done := 1.0
+	if total := now.Sub(p.floor); total > 0 {
+		done = float64(at.Sub(p.floor)) / float64(total)
+	}
+	if done < 0 {
+		done = 0
+	} else if done > 1 {
+		done = 1
+	}
+	p.last[symbol] = progressMark{at: now, done: done}
+
+	eta := "unknown"
+	if done > mark.done {
+		rate := float64(now.Sub(mark.at)) / (done - mark.done)
+		eta = time.Duration(rate * (1 - done)).Round(time.Second).String()
+	}
+	bn.mu.Lock()
+	rows := bn.collectionStatus(symbol).rowsWritten
+	bn.mu.Unlock()
+	return fmt.Sprintf("Backfilling %s: at %s, %.0f%% to now, ~%s rows written, ETA %s",
+		symbol, at.Format("2006-01-02 15:04"), done*100, compactCount(rows), eta)
+}
+
+// compactCount formats n with a K or M suffix above a thousand
+func compactCount(n int64) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fK", float64(n)/1000)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestProgressLog(c *C) {
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "progress_interval": "1m"}`)
+	floor := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: floor.Add(10*time.Hour - time.Minute)}
+	worker.clock = clk
+	worker.startProgress(floor)
+	c.Assert(worker.progressLine("EOS", floor.Add(time.Hour)), Equals, "")
+
+	clk.Sleep(time.Minute)
+	worker.collectionStatus("EOS").rowsWritten = 1234567
+	c.Assert(worker.progressLine("EOS", floor.Add(5*time.Hour)), Equals,
+		"Backfilling EOS: at 2018-08-01 05:00, 50% to now, ~1.2M rows written, ETA 1m0s")
+	// once per interval
+	c.Assert(worker.progressLine("EOS", floor.Add(6*time.Hour)), Equals, "")
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "progress_interval": "often"}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/golang/glog"
+)
+
+// trackQuiet counts the live passes in a row in which symbol returned no
+// candles.  Once they reach quiet_intervals the symbol is flagged as quiet,
+// as it may be halted or delisted, and with quarantine_quiet skipped until
+// its exchangeInfo status is refreshed.
+func (bn *BinanceFetcher) trackQuiet(symbol string, empty bool) {
+	if bn.quietIntervals <= 0 {
+		return
+	}
+	bn.mu.Lock()
+	st := bn.collectionStatus(symbol)
+	if !empty {
+		wasQuiet := st.quiet
+		st.emptyIntervals, st.quiet = 0, false
+		bn.mu.Unlock()
+		if wasQuiet {
+			glog.Infof("%s returns candles again", symbol)
+			setGauge(bn.metricKey("quiet_"+symbol), 0)
+		}
+		return
+	}
+	st.emptyIntervals++
+	if st.quiet || st.emptyIntervals < bn.quietIntervals {
+		bn.mu.Unlock()
+		return
+	}
+	st.quiet = true
+	bn.mu.Unlock()
+
+	glog.Warningf("%s returned no candles for %d intervals, it may be halted or delisted", symbol, bn.quietIntervals)
+	setGauge(bn.metricKey("quiet_"+symbol), 1)
+	if bn.quarantineQuiet {
+		glog.Warningf("Quarantining %s until its status is refreshed", symbol)
+		bn.paused[symbol] = true
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestQuietSymbol(c *C) {
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS", "TRX"], "quiet_intervals": 3}`)
+	for i := 0; i < 2; i++ {
+		worker.trackQuiet("EOS", true)
+	}
+	// a pass with candles starts over
+	worker.trackQuiet("EOS", false)
+	for i := 0; i < 2; i++ {
+		worker.trackQuiet("EOS", true)
+	}
+	c.Assert(worker.collectionStatus("EOS").quiet, Equals, false)
+	worker.trackQuiet("EOS", true)
+	c.Assert(worker.collectionStatus("EOS").quiet, Equals, true)
+	c.Assert(worker.paused["EOS"], Equals, false)
+	worker.trackQuiet("EOS", false)
+	c.Assert(worker.collectionStatus("EOS").quiet, Equals, false)
+
+	worker = s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS", "TRX"], "quiet_intervals": 1, "quarantine_quiet": true}`)
+	worker.trackQuiet("TRX", true)
+	c.Assert(worker.paused["TRX"], Equals, true)
+	c.Assert(worker.paused["EOS"], Equals, false)
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "quiet_intervals": -1}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// quoteWorkers collects the pairs of several quote assets, with a
+// BinanceFetcher for each of them.  They share the client and request weight
+// limiter of the venue, and the exchangeInfo their symbols are resolved from.
+type quoteWorkers []*BinanceFetcher
+
+// Run runs the workers until they all return
+func (workers quoteWorkers) Run() {
+	var wg sync.WaitGroup
+	for _, bn := range workers {
+		wg.Add(1)
+		go func(bn *BinanceFetcher) {
+			defer wg.Done()
+			bn.Run()
+		}(bn)
+	}
+	wg.Wait()
+}
+
+// exchangeInfoTTL is how long an exchangeInfo response is reused to resolve
+// the symbols of the workers starting together
+const exchangeInfoTTL = time.Minute
+
+// exchangeInfoAttempts is the number of times cachedExchangeInfo requests
+// exchangeInfo before failing
+const exchangeInfoAttempts = 3
+
+// exchangeInfoClock is the time source of the retries of exchangeInfo, the
+// real one but in tests
+var exchangeInfoClock clock = realClock{}
+
+var (
+	exchangeInfoMu    sync.Mutex
+	exchangeInfoCache = map[string]exchangeInfoEntry{}
+	// exchangeInfoFetch serializes the requests of each URL, so the workers
+	// starting together wait for one response instead of each requesting it
+	exchangeInfoFetch = map[string]*sync.Mutex{}
+)
+
+type exchangeInfoEntry struct {
+	info      *ExchangeInfo
+	fetchedAt time.Time
+}
+
+// cachedExchangeInfo returns the exchangeInfo of the venue, requesting it
+// unless it was less than exchangeInfoTTL ago
+func cachedExchangeInfo(v venue) (*ExchangeInfo, error) {
+	url := v.exchangeInfoURL()
+	fetch := exchangeInfoFetcher(url)
+	fetch.Lock()
+	defer fetch.Unlock()
+	if info := cachedExchangeInfoEntry(url); info != nil {
+		return info, nil
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		info := &ExchangeInfo{}
+		if err = getJson(v.jsonHTTPClient(), url, info); err == nil {
+			err = info.validate()
+		}
+		if err == nil {
+			exchangeInfoMu.Lock()
+			exchangeInfoCache[url] = exchangeInfoEntry{info: info, fetchedAt: time.Now()}
+			exchangeInfoMu.Unlock()
+			return info, nil
+		}
+		if attempt == exchangeInfoAttempts {
+			return nil, err
+		}
+		d := retryDelay(attempt)
+		glog.Warningf("Binance /exchangeInfo API error, retrying in %v: %v", d, err)
+		exchangeInfoClock.Sleep(d)
+	}
+}
+
+// exchangeInfoFetcher returns the mutex serializing the requests of url
+func exchangeInfoFetcher(url string) *sync.Mutex {
+	exchangeInfoMu.Lock()
+	defer exchangeInfoMu.Unlock()
+	fetch, ok := exchangeInfoFetch[url]
+	if !ok {
+		fetch = &sync.Mutex{}
+		exchangeInfoFetch[url] = fetch
+	}
+	return fetch
+}
+
+// cachedExchangeInfoEntry returns the exchangeInfo of url if it was fetched
+// less than exchangeInfoTTL ago, nil otherwise
+func cachedExchangeInfoEntry(url string) *ExchangeInfo {
+	exchangeInfoMu.Lock()
+	defer exchangeInfoMu.Unlock()
+	if e, ok := exchangeInfoCache[url]; ok && time.Since(e.fetchedAt) < exchangeInfoTTL {
+		return e.info
+	}
+	return nil
+}
+
+// validate returns an error if the response lists no symbols, as a truncated
+// or unrelated body decodes into an empty ExchangeInfo
+func (info *ExchangeInfo) validate() error {
+	if len(info.Symbols) == 0 {
+		return errors.New("exchangeInfo lists no symbols")
+	}
+	return nil
+}
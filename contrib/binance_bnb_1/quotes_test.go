@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestBaseCurrencies(c *C) {
+	client := newFixtureClient(c, "EOSBNB")
+	client.klines["EOSBTC"] = client.klines["EOSBNB"][:30]
+	ret, err := NewBgWorker(getConfig(`{
+        "symbols": ["EOS"],
+        "base_currencies": ["BNB", "BTC"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "QUOTES_{quote}_{base}"
+        }`))
+	c.Assert(err, IsNil)
+	workers := ret.(quoteWorkers)
+	c.Assert(workers, HasLen, 2)
+	for _, worker := range workers {
+		worker.client = client
+		worker.statusRefreshedAt = time.Now().UTC()
+	}
+	c.Assert(workers[1].baseCurrency, Equals, "BTC")
+	c.Assert(workers[1].limiter, Equals, workers[0].limiter)
+	bn, _ := workerFor(httptest.NewRequest("POST", backfillPath("BTC"), nil))
+	c.Assert(bn, Equals, workers[1])
+
+	workers.Run()
+	assertKlines(c, readBucket(c, "QUOTES_BNB_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+	assertKlines(c, readBucket(c, "QUOTES_BTC_EOS/1Min/OHLCV"), client.klines["EOSBTC"])
+
+	ret, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "base_currency": "BTC"}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).baseCurrency, Equals, "BTC")
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "base_currency": "BTC", "base_currencies": ["BNB", "BTC"]}`))
+	c.Assert(err, NotNil)
+}
+
+func (s *RunTestSuite) TestExchangeInfoErrors(c *C) {
+	clk := &fakeClock{now: time.Now()}
+	exchangeInfoClock = clk
+	defer func() { exchangeInfoClock = realClock{} }()
+
+	for _, respond := range []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("<html>maintenance</html>"))
+		},
+		// a truncated body decodes into no symbols
+		func(w http.ResponseWriter) {
+			w.Write([]byte(`{"timezone": "UTC"}`))
+		},
+	} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			respond(w)
+		}))
+		v := venues[defaultVenue]
+		v.baseURL = server.URL
+		_, err := getAllSymbols(v, newFixtureClient(c), "BNB", map[string]bool{"TRADING": true}, func(string) bool { return false })
+		server.Close()
+		c.Assert(err, NotNil)
+		c.Assert(requests, Equals, exchangeInfoAttempts)
+	}
+	c.Assert(clk.slept[:2], DeepEquals, []time.Duration{time.Second, 2 * time.Second})
+
+	// the response after a failed one is used
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", "exchangeInfo.json"))
+	}))
+	defer server.Close()
+	v := venues[defaultVenue]
+	v.baseURL = server.URL
+	symbols, err := getAllSymbols(v, newFixtureClient(c, "EOSBNB", "TRXBNB"), "BNB", map[string]bool{"TRADING": true}, func(string) bool { return false })
+	c.Assert(err, IsNil)
+	c.Assert(symbols, DeepEquals, []string{"EOS", "TRX"})
+}
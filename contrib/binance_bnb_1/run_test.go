@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestRunFixtures(c *C) {
+	client := newFixtureClient(c, "EOSBNB", "TRXBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS", "TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00"
+        }`)
+	worker.Run()
+
+	// EOS has a 10 minute gap in the recorded data
+	eos := readBucket(c, "BINANCE_BNB_EOS/1Min/OHLCV")
+	c.Assert(eos.Len(), Equals, 110)
+	assertKlines(c, eos, client.klines["EOSBNB"])
+	c.Assert(eos.GetEpoch()[30]-eos.GetEpoch()[29], Equals, int64(11*60))
+
+	trx := readBucket(c, "BINANCE_BNB_TRX/1Min/OHLCV")
+	assertKlines(c, trx, client.klines["TRXBNB"])
+
+	// fetching the same range again does not duplicate rows
+	worker.Run()
+	assertKlines(c, readBucket(c, "BINANCE_BNB_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+	assertKlines(c, readBucket(c, "BINANCE_BNB_TRX/1Min/OHLCV"), client.klines["TRXBNB"])
+}
+
+func (s *RunTestSuite) TestSymbolStarts(c *C) {
+	client := newFixtureClient(c, "EOSBNB", "TRXBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS", "TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "STARTS_{base}",
+        "symbol_starts": {"TRX": "2018-08-01 00:30"}
+        }`)
+	worker.Run()
+	assertKlines(c, readBucket(c, "STARTS_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+	assertKlines(c, readBucket(c, "STARTS_TRX/1Min/OHLCV"), client.klines["TRXBNB"][30:])
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["TRX"], "symbol_starts": {"TRX": "August"}}`))
+	c.Assert(err, NotNil)
+}
+
+func (s *RunTestSuite) TestReload(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	klines := syntheticKlines(start, 60, time.Minute)
+	changed := make([]*binance.Kline, len(klines))
+	for i, k := range klines {
+		k := *k
+		k.Close = "9.5"
+		changed[i] = &k
+	}
+	run := func(reload, queryEnd string, klines []*binance.Kline) (*BinanceFetcher, *requestRecorder) {
+		client := &requestRecorder{klinesClient: &fixtureClient{klines: map[string][]*binance.Kline{"EOSBNB": klines}}}
+		worker := s.newWorker(c, client, fmt.Sprintf(`{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "%s",
+        "bucket_name_template": "RELOAD%s_{base}",
+        "reload": "%s"
+        }`, queryEnd, strings.ToUpper(reload), reload))
+		worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+		worker.Run()
+		return worker, client
+	}
+
+	for _, reload := range []string{reloadSkip, reloadDedup, reloadOverwrite} {
+		worker, _ := run(reload, "2018-08-01 00:30", klines)
+		stored, err := lastStoredTime(worker.store, worker.tbkFor("EOS"))
+		c.Assert(err, IsNil)
+		c.Assert(stored.After(start), Equals, true)
+
+		_, client := run(reload, "2018-08-01 00:59", changed)
+		closes := readBucket(c, "RELOAD"+strings.ToUpper(reload)+"_EOS/1Min/OHLCV").GetByName("Close").([]float64)
+		c.Assert(closes, HasLen, 60)
+		switch reload {
+		case reloadSkip:
+			// the stored candles are not fetched again but the last one
+			c.Assert(client.ranges[0][0], Equals, timeToMillis(stored))
+			c.Assert(closes[0], Equals, 1.5)
+		case reloadDedup:
+			c.Assert(client.ranges[0][0], Equals, timeToMillis(start))
+			c.Assert(closes[0], Equals, 1.5)
+		case reloadOverwrite:
+			c.Assert(client.ranges[0][0], Equals, timeToMillis(start))
+			c.Assert(closes[0], Equals, 9.5)
+		}
+		c.Assert(closes[59], Equals, 9.5)
+	}
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "reload": "append"}`))
+	c.Assert(err, NotNil)
+}
+
+func (s *RunTestSuite) TestIncrementalRead(c *C) {
+	client := newFixtureClient(c, "EOSBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "SINCE_{base}"
+        }`)
+	worker.Run()
+	all := readBucket(c, "SINCE_EOS/1Min/OHLCV").GetEpoch()
+
+	// a consumer polls for 25 rows at a time newer than the last one seen
+	tbk := io.NewTimeBucketKey("SINCE_EOS/1Min/OHLCV")
+	read := []int64{}
+	lastSeen := int64(0)
+	for {
+		q := planner.NewQuery(executor.ThisInstance.CatalogDir)
+		q.AddTargetKey(tbk)
+		q.SetRange(lastSeen+1, math.MaxInt64)
+		q.SetRowLimit(io.FIRST, 25)
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		reader, err := executor.NewReader(parsed)
+		c.Assert(err, IsNil)
+		csm, _, err := reader.Read()
+		c.Assert(err, IsNil)
+		cs := csm[*tbk]
+		if cs == nil || cs.Len() == 0 {
+			break
+		}
+		for _, e := range cs.GetEpoch() {
+			c.Assert(e > lastSeen, Equals, true)
+			lastSeen = e
+			read = append(read, e)
+		}
+	}
+	c.Assert(read, DeepEquals, all)
+}
+
+func (s *RunTestSuite) TestIncompleteCandleTrim(c *C) {
+	klines := loadKlines(c, filepath.Join("testdata", "klines_TRXBNB_1m.json"))
+
+	cs, err := ratesToColumnSeries(klines, false, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	c.Assert(cs.Len(), Equals, len(klines))
+
+	// the last candle is still forming when polling live
+	cs, err = ratesToColumnSeries(klines, true, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	assertKlines(c, cs, klines[:len(klines)-1])
+
+	// a lone candle is never trimmed
+	cs, err = ratesToColumnSeries(klines[:1], true, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	c.Assert(cs.Len(), Equals, 1)
+
+	cs, err = ratesToColumnSeries(nil, true, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	c.Assert(cs, IsNil)
+}
+
+func (s *RunTestSuite) TestOnError(c *C) {
+	klines := loadKlines(c, filepath.Join("testdata", "klines_TRXBNB_1m.json"))[:3]
+	bad := *klines[1]
+	bad.High = "0.0024x"
+	klines[1] = &bad
+
+	// the candle is dropped
+	cs, err := ratesToColumnSeries(klines, false, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	assertKlines(c, cs, []*binance.Kline{klines[0], klines[2]})
+
+	cs, err = ratesToColumnSeries(klines, false, "open", onErrorAbort)
+	c.Assert(err, NotNil)
+	c.Assert(cs, IsNil)
+
+	// the value is written as 0
+	cs, err = ratesToColumnSeries(klines, false, "open", onErrorZero)
+	c.Assert(err, IsNil)
+	c.Assert(cs.Len(), Equals, 3)
+	c.Assert(cs.GetByName("High").([]float64)[1], Equals, 0.0)
+	c.Assert(cs.GetByName("Low").([]float64)[1], Equals, parseFloat(c, bad.Low))
+
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["TRX"]}`)
+	c.Assert(worker.onError, Equals, onErrorSkip)
+	_, err = NewBgWorker(getConfig(`{"symbols": ["TRX"], "on_error": "ignore"}`))
+	c.Assert(err, NotNil)
+}
+
+func (s *RunTestSuite) TestEpochSource(c *C) {
+	klines := loadKlines(c, filepath.Join("testdata", "klines_TRXBNB_1m.json"))
+
+	open, err := ratesToColumnSeries(klines, true, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	close, err := ratesToColumnSeries(klines, true, "close", onErrorSkip)
+	c.Assert(err, IsNil)
+
+	// the same candles are kept, shifted by one interval
+	c.Assert(close.Len(), Equals, open.Len())
+	c.Assert(close.GetByName("Close"), DeepEquals, open.GetByName("Close"))
+	for i, epoch := range close.GetEpoch() {
+		c.Assert(epoch, Equals, open.GetEpoch()[i]+60)
+		c.Assert(epoch, Equals, (klines[i].CloseTime+1)/1000)
+	}
+
+	ret, err := NewBgWorker(getConfig(`{"symbols": ["BTC"], "epoch_source": "close"}`))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).epochSource, Equals, "close")
+	ret, err = NewBgWorker(getConfig(`{"symbols": ["BTC"], "epoch_source": "middle"}`))
+	c.Assert(err, NotNil)
+	c.Assert(ret, IsNil)
+}
+
+func (s *RunTestSuite) TestGetAllSymbols(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", "exchangeInfo.json"))
+	}))
+	defer server.Close()
+
+	v := venues[defaultVenue]
+	v.baseURL = server.URL
+	client := newFixtureClient(c, "EOSBNB", "TRXBNB")
+
+	none := func(string) bool { return false }
+
+	// VEN is on a break and ETH is only quoted in BTC
+	symbols, err := getAllSymbols(v, client, "BNB", map[string]bool{"TRADING": true}, none)
+	c.Assert(err, IsNil)
+	c.Assert(symbols, DeepEquals, []string{"EOS", "TRX"})
+
+	allowBreak := map[string]bool{"TRADING": true, "BREAK": true}
+	// VEN has no klines to probe
+	symbols, err = getAllSymbols(v, client, "BNB", allowBreak, none)
+	c.Assert(err, IsNil)
+	c.Assert(symbols, DeepEquals, []string{"EOS", "TRX"})
+
+	// symbols with stored data are not probed
+	probed := &probeCounter{klinesClient: client}
+	symbols, err = getAllSymbols(v, probed, "BNB", allowBreak, func(symbol string) bool { return symbol != "TRX" })
+	c.Assert(err, IsNil)
+	c.Assert(symbols, DeepEquals, []string{"EOS", "TRX", "VEN"})
+	c.Assert(probed.symbols, DeepEquals, []string{"TRXBNB"})
+
+	// sorted, unlike exchangeInfo
+	client.klines["VENBNB"] = client.klines["EOSBNB"]
+	symbols, err = getAllSymbols(v, client, "BNB", allowBreak, none)
+	c.Assert(err, IsNil)
+	c.Assert(symbols, DeepEquals, []string{"EOS", "TRX", "VEN"})
+}
+
+// probeCounter records the symbols requested
+type probeCounter struct {
+	klinesClient
+	mu      sync.Mutex
+	symbols []string
+}
+
+func (p *probeCounter) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	p.mu.Lock()
+	p.symbols = append(p.symbols, symbol)
+	p.mu.Unlock()
+	return p.klinesClient.Klines(ctx, symbol, interval, startTime, endTime)
+}
+
+func (s *RunTestSuite) TestGetJsonGzip(c *C) {
+	info, err := ioutil.ReadFile(filepath.Join("testdata", "exchangeInfo.json"))
+	c.Assert(err, IsNil)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write(info)
+	c.Assert(err, IsNil)
+	c.Assert(gz.Close(), IsNil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write(info)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	// the shared client asks for gzip and decodes it
+	var m ExchangeInfo
+	c.Assert(getJson(jsonClient, server.URL, &m), IsNil)
+	c.Assert(compressed.Len() < len(info), Equals, true)
+	c.Assert(m.Symbols, HasLen, 5)
+	c.Assert(m.Symbols[1].Symbol, Equals, "EOSBNB")
+
+	// a client that does not ask for it still gets the body decoded
+	client := &http.Client{Transport: &gzipTransport{http.DefaultTransport}}
+	m = ExchangeInfo{}
+	c.Assert(getJson(client, server.URL, &m), IsNil)
+	c.Assert(m.Symbols, HasLen, 5)
+}
+
+// gzipTransport sets Accept-Encoding itself, which turns off the transparent
+// decoding of the default transport
+type gzipTransport struct {
+	base http.RoundTripper
+}
+
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.WithContext(req.Context())
+	r.Header = http.Header{"Accept-Encoding": {"gzip"}}
+	return t.base.RoundTrip(r)
+}
+
+func (s *RunTestSuite) TestColumns(c *C) {
+	client := newFixtureClient(c, "TRXBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "COLUMNS_{base}",
+        "attribute_group": "CLOSE",
+        "columns": ["Volume", "Close"],
+        "allow_updates": true
+        }`)
+	c.Assert(worker.columns, DeepEquals, []string{"Close", "Volume"})
+	worker.Run()
+
+	// a narrower correction is compared on the written columns only
+	last := len(client.klines["TRXBNB"]) - 1
+	corrected := *client.klines["TRXBNB"][last]
+	corrected.Close = "0.00245700"
+	client.klines["TRXBNB"][last] = &corrected
+	worker.Run()
+
+	cs := readBucket(c, "COLUMNS_TRX/1Min/CLOSE")
+	c.Assert(cs.GetColumnNames(), DeepEquals, []string{"Epoch", "Close", "Volume"})
+	c.Assert(cs.Len(), Equals, len(client.klines["TRXBNB"]))
+	for i, k := range client.klines["TRXBNB"] {
+		c.Assert(cs.GetByName("Close").([]float64)[i], Equals, parseFloat(c, k.Close))
+	}
+
+	for _, config := range []string{
+		`{"symbols": ["TRX"], "columns": ["Close", "Trades"], "attribute_group": "CLOSE"}`,
+		// OHLCV implies all of the columns
+		`{"symbols": ["TRX"], "columns": ["Close"]}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf("%s", config))
+	}
+}
+
+func (s *RunTestSuite) TestCompleteDaysOnly(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	today := start.AddDate(0, 0, 9)
+	config := `{
+        "symbols": ["EOS"],
+        "base_timeframe": "1D",
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-31 00:00",
+        "bucket_name_template": "COMPLETEDAYS%d_{base}",
+        "complete_days_only": true
+        }`
+	// the candle of today is returned by the API whatever the time of day
+	for i, now := range []time.Time{today, today.Add(time.Second), today.Add(12 * time.Hour), today.Add(utils.Day - time.Second)} {
+		client := &fixtureClient{klines: map[string][]*binance.Kline{
+			"EOSBNB": syntheticKlines(start, 10, utils.Day),
+		}}
+		worker := s.newWorker(c, client, fmt.Sprintf(config, i))
+		worker.clock = &fakeClock{now: now}
+		worker.Run()
+		c.Assert(worker.queryEnd.Equal(today), Equals, true)
+		epoch := readBucket(c, fmt.Sprintf("COMPLETEDAYS%d_EOS/1D/OHLCV", i)).GetEpoch()
+		c.Assert(epoch, HasLen, 9)
+		c.Assert(epoch[8], Equals, today.AddDate(0, 0, -1).Unix())
+	}
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "complete_days_only": true}`))
+	c.Assert(err, NotNil)
+}
+
+func (s *RunTestSuite) TestOneshot(c *C) {
+	klines := syntheticKlines(time.Now().UTC().Truncate(time.Minute).Add(-20*time.Minute), 21, time.Minute)
+	// the last candle is still forming when the worker starts
+	klines[20].CloseTime = timeToMillis(time.Now().Add(time.Hour))
+	client := &fixtureClient{klines: map[string][]*binance.Kline{"EOSBNB": klines}}
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "bucket_name_template": "ONESHOT_{base}",
+        "mode": "oneshot",
+        "backfill_sleep": "0s"
+        }`)
+	stored, err := ratesToColumnSeries(klines[:5], false, worker.epochSource, worker.onError)
+	c.Assert(err, IsNil)
+	c.Assert(worker.write("EOS", stored, false), IsNil)
+
+	// resumes from the stored candles and returns once caught up
+	worker.Run()
+	epoch := readBucket(c, "ONESHOT_EOS/1Min/OHLCV").GetEpoch()
+	c.Assert(epoch, HasLen, 20)
+	c.Assert(epoch[19], Equals, klines[19].OpenTime/1000)
+}
+
+func (s *RunTestSuite) TestLiveWindow(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) int64 { return timeToMillis(start.Add(time.Duration(minutes) * time.Minute)) }
+	// half way through the candle of 10:00
+	clk := &fakeClock{now: start.Add(10*time.Hour + 30*time.Second)}
+	client := &requestRecorder{klinesClient: &clockClient{&fixtureClient{klines: map[string][]*binance.Kline{
+		"EOSBNB": syntheticKlines(start, 11*60, time.Minute),
+	}}, clk}}
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 10:03",
+        "bucket_name_template": "LIVEWINDOW_{base}"
+        }`)
+	worker.clock = clk
+	worker.Run()
+
+	// each live pass probes for the forming candle, then requests the same
+	// window up to its open, not up to the time of the request
+	ends := []int64{}
+	for i, r := range client.ranges {
+		if r[1] != 0 {
+			continue
+		}
+		c.Assert(i+1 < len(client.ranges), Equals, true)
+		c.Assert(client.ranges[i+1][0], Equals, r[0])
+		ends = append(ends, client.ranges[i+1][1])
+	}
+	c.Assert(ends, DeepEquals, []int64{at(600), at(601), at(602), at(603)})
+	epoch := readBucket(c, "LIVEWINDOW_EOS/1Min/OHLCV").GetEpoch()
+	c.Assert(epoch, HasLen, 10*60+3)
+	c.Assert(epoch[len(epoch)-1], Equals, start.Add(10*time.Hour+2*time.Minute).Unix())
+}
+
+func (s *RunTestSuite) TestRequestSpan(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	client := &requestRecorder{klinesClient: newFixtureClient(c, "EOSBNB")}
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "SPAN_{base}",
+        "request_span": 20,
+        "advance_step": 15
+        }`)
+	worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+	worker.Run()
+
+	// the windows overlap by 5 intervals, the last one ends at query_end
+	at := func(minutes int) int64 { return timeToMillis(start.Add(time.Duration(minutes) * time.Minute)) }
+	c.Assert(client.ranges, DeepEquals, [][2]int64{
+		{at(0), at(20)}, {at(15), at(35)}, {at(30), at(50)}, {at(45), at(60)},
+	})
+	epoch := readBucket(c, "SPAN_EOS/1Min/OHLCV").GetEpoch()
+	c.Assert(epoch, HasLen, 61)
+	c.Assert(epoch[60], Equals, start.Add(time.Hour).Unix())
+
+	// backfill requests use the same windows
+	client.ranges = nil
+	worker.backfill(backfillRequest{symbol: "EOS", start: start, end: start.Add(time.Hour)})
+	c.Assert(client.ranges, DeepEquals, [][2]int64{
+		{at(0), at(20)}, {at(15), at(35)}, {at(30), at(50)}, {at(45), at(60)},
+	})
+
+	for _, config := range []string{
+		`{"symbols": ["EOS"], "request_span": 500}`,
+		`{"symbols": ["EOS"], "request_span": -1}`,
+		`{"symbols": ["EOS"], "request_span": 100, "advance_step": 101}`,
+		`{"symbols": ["EOS"], "advance_step": 301}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf(config))
+	}
+}
+
+func (s *RunTestSuite) TestWindowRetries(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) int64 { return timeToMillis(start.Add(time.Duration(minutes) * time.Minute)) }
+	client := &requestRecorder{klinesClient: &badWindowClient{
+		klinesClient: newFixtureClient(c, "EOSBNB", "TRXBNB"),
+		symbol:       "TRXBNB",
+		startTime:    at(20),
+	}}
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS", "TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "WINDOW_{base}",
+        "request_span": 20,
+        "advance_step": 20,
+        "window_retries": 3
+        }`)
+	worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+	worker.Run()
+
+	// the window of TRX from 00:20 is requested 3 times, then skipped
+	failed := 0
+	for _, r := range client.ranges {
+		if r[0] == at(20) {
+			failed++
+		}
+	}
+	c.Assert(failed, Equals, 3+3)
+	c.Assert(readBucket(c, "WINDOW_EOS/1Min/OHLCV").GetEpoch(), HasLen, 61)
+	trx := readBucket(c, "WINDOW_TRX/1Min/OHLCV").GetEpoch()
+	c.Assert(trx, HasLen, 41)
+	c.Assert(trx[21], Equals, start.Add(41*time.Minute).Unix())
+	gaps := worker.state(start).Symbols["TRX"].Gaps
+	c.Assert(gaps, HasLen, 1)
+	c.Assert(gaps[0].Start.Equal(start.Add(20*time.Minute)), Equals, true)
+	c.Assert(gaps[0].End.Equal(start.Add(40*time.Minute)), Equals, true)
+	c.Assert(worker.state(start).Symbols["EOS"].Gaps, HasLen, 0)
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "window_retries": -1}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// sanityBounds rejects the candles with values no market produces, such as
+// the ones returned during an exchange incident.  The zero value accepts all.
+type sanityBounds struct {
+	// nonPositive rejects an Open, High, Low or Close of 0 or less
+	nonPositive bool
+	// highBelowLow rejects a High below the Low
+	highBelowLow bool
+	// maxValue rejects any value above it, 0 for no cap
+	maxValue float64
+}
+
+// violation describes why the Open, High, Low, Close and Volume values are
+// rejected, or returns "" if they are not
+func (s *sanityBounds) violation(values []float64) string {
+	if s == nil {
+		return ""
+	}
+	if s.nonPositive {
+		for i, v := range values[:4] {
+			if v <= 0 {
+				return fmt.Sprintf("%s %v is not positive", klineColumns[i], v)
+			}
+		}
+	}
+	if s.highBelowLow && values[1] < values[2] {
+		return fmt.Sprintf("High %v is below Low %v", values[1], values[2])
+	}
+	if s.maxValue > 0 {
+		for i, v := range values {
+			if v > s.maxValue {
+				return fmt.Sprintf("%s %v is above %v", klineColumns[i], v, s.maxValue)
+			}
+		}
+	}
+	return ""
+}
+
+// toColumnSeries converts the klines of symbol like ratesToColumnSeries,
+// dropping the candles out of the sanity bounds and counting them
+func (bn *BinanceFetcher) toColumnSeries(symbol string, rates []*binance.Kline, trimLast bool) (*io.ColumnSeries, error) {
+	cs, rejected, err := buildColumnSeries(rates, trimLast, bn.epochSource, bn.onError, &bn.sanity, bn.includeTradeCount)
+	if rejected > 0 {
+		bn.mu.Lock()
+		st := bn.collectionStatus(symbol)
+		st.rejected += int64(rejected)
+		total := st.rejected
+		bn.mu.Unlock()
+		glog.Warningf("Rejected %d candles of %s out of the sanity bounds", rejected, symbol)
+		setGauge(bn.metricKey("sanity_rejected_"+symbol), total)
+	}
+	return cs, err
+}
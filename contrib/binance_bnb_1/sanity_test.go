@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSanityBounds(c *C) {
+	klines := loadKlines(c, filepath.Join("testdata", "klines_TRXBNB_1m.json"))[:4]
+	zero, inverted, huge := *klines[0], *klines[1], *klines[2]
+	zero.Close = "0"
+	inverted.High, inverted.Low = inverted.Low, "1"
+	huge.High = "1e18"
+	klines[0], klines[1], klines[2] = &zero, &inverted, &huge
+
+	// off by default but for High < Low
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["TRX"]}`)
+	cs, err := worker.toColumnSeries("TRX", klines, false)
+	c.Assert(err, IsNil)
+	assertKlines(c, cs, []*binance.Kline{klines[0], klines[2], klines[3]})
+	c.Assert(worker.collectionStatus("TRX").rejected, Equals, int64(1))
+
+	worker = s.newWorker(c, newFixtureClient(c), `{"symbols": ["TRX"], "sanity_reject_non_positive": true,
+		"sanity_allow_high_below_low": true, "sanity_max_value": 1e12}`)
+	cs, err = worker.toColumnSeries("TRX", klines, false)
+	c.Assert(err, IsNil)
+	assertKlines(c, cs, []*binance.Kline{klines[1], klines[3]})
+	c.Assert(worker.collectionStatus("TRX").rejected, Equals, int64(2))
+
+	// the forming candle is not counted
+	cs, err = worker.toColumnSeries("TRX", klines[:3], true)
+	c.Assert(err, IsNil)
+	assertKlines(c, cs, []*binance.Kline{klines[1]})
+	c.Assert(worker.collectionStatus("TRX").rejected, Equals, int64(3))
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["TRX"], "sanity_max_value": -1}`))
+	c.Assert(err, NotNil)
+}
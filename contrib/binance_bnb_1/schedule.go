@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/golang/glog"
+)
+
+// scheduleCloseDelay is how long after a candle closes the schedule mode
+// fetches it without close_delay, leaving the exchange time to settle it
+const scheduleCloseDelay = 10 * time.Second
+
+// scheduleDelay returns how long after a candle closes the schedule mode
+// fetches it
+func (bn *BinanceFetcher) scheduleDelay() time.Duration {
+	if bn.closeDelay > 0 {
+		return bn.closeDelay
+	}
+	return scheduleCloseDelay
+}
+
+// closedRates splits the candles closed at now from the one still forming,
+// which is nil if there is none
+func closedRates(rates []*binance.Kline, now time.Time) (closed []*binance.Kline, forming *binance.Kline) {
+	closed = []*binance.Kline{}
+	for _, rate := range rates {
+		if rate.CloseTime < timeToMillis(now) {
+			closed = append(closed, rate)
+		} else {
+			forming = rate
+		}
+	}
+	return closed, forming
+}
+
+// runSchedule fetches the candles of daily and coarser timeframes once after
+// each of them closes, instead of polling.  Each pass requests the last two
+// intervals and writes the closed candles, then sleeps until the forming one
+// closes.  With symbol_timeframes, the pass runs when the first forming
+// candle of the timeframes closes.
+func (bn *BinanceFetcher) runSchedule() {
+	for {
+		now := bn.clock.Now().UTC()
+		// Binance candles are aligned to UTC, the forming candle tells when
+		// the next one closes when it is returned
+		closes := map[*utils.Timeframe]time.Time{}
+		for _, tf := range bn.timeframes() {
+			closes[tf] = candleClose(candleOpen(now, tf), tf)
+		}
+		for i, symbol := range bn.symbols {
+			tf := bn.timeframeOf(symbol)
+			if !bn.finishPassOnShutdown && bn.shuttingDown() {
+				glog.Warningf("Shutting down with %d of %d symbols not fetched in this pass", len(bn.symbols)-i, len(bn.symbols))
+				return
+			}
+			if bn.paused[symbol] {
+				continue
+			}
+			start := timeToMillis(now.Add(-2 * tf.Duration))
+			rates, err := bn.klines(context.Background(), symbol, bn.intervalOf(symbol), start, 0)
+			bn.recordFetch(symbol, bn.clock.Now().UTC(), err)
+			if err != nil {
+				if !bn.handleFetchError(symbol, err) {
+					return
+				}
+				continue
+			}
+			bn.transientErrors = 0
+			closed, forming := closedRates(rates, now)
+			if forming != nil {
+				closes[tf] = time.Unix(millisToEpochSec(forming.CloseTime+1), 0).UTC()
+			}
+			cs, err := bn.toColumnSeries(symbol, closed, false)
+			if err != nil {
+				glog.Errorf("Stopping on the conversion error for %s: %v", symbol, err)
+				return
+			}
+			if cs != nil {
+				if err := bn.write(symbol, cs, false); err != nil && !bn.writeFailed(symbol, err) {
+					return
+				}
+			}
+		}
+
+		bn.writeCollectionStatuses(bn.clock.Now().UTC())
+		if bn.shuttingDown() {
+			glog.Infof("Shutting down after a complete pass")
+			return
+		}
+		bn.collectTicker24(bn.clock.Now().UTC())
+
+		if !bn.queryEnd.IsZero() && !now.Before(bn.queryEnd) {
+			glog.Infof("Reached query_end %v", bn.queryEnd)
+			return
+		}
+		var next time.Time
+		var nextTimeframe *utils.Timeframe
+		for tf, at := range closes {
+			if next.IsZero() || at.Before(next) {
+				next, nextTimeframe = at, tf
+			}
+		}
+		glog.Infof("Next %s candles close at %v", nextTimeframe.String, next)
+		if !bn.sleep(next.Add(bn.scheduleDelay()).Sub(bn.clock.Now())) {
+			glog.Infof("Shutting down after a complete pass")
+			return
+		}
+	}
+}
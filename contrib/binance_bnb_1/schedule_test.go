@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/utils"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSchedule(c *C) {
+	// intraday timeframes keep polling
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "mode": "schedule"}`)
+	c.Assert(worker.mode, Equals, modeLoop)
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "mode": "forever"}`))
+	c.Assert(err, NotNil)
+
+	today := time.Now().UTC().Truncate(utils.Day)
+	client := &fixtureClient{klines: map[string][]*binance.Kline{
+		"EOSBNB": syntheticKlines(today.AddDate(0, 0, -3), 4, utils.Day),
+	}}
+	shutdown := make(chan struct{})
+	worker = s.newWorker(c, &shutdownClient{client, shutdown}, `{
+        "symbols": ["EOS"],
+        "base_timeframe": "1D",
+        "bucket_name_template": "SCHEDULE_{base}",
+        "mode": "schedule",
+        "finish_pass_on_shutdown": true
+        }`)
+	c.Assert(worker.mode, Equals, modeSchedule)
+	worker.shutdown = shutdown
+	worker.Run()
+
+	// only the candle closed last is fetched, the forming one is not written
+	cs := readBucket(c, "SCHEDULE_EOS/1D/OHLCV")
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{today.AddDate(0, 0, -1).Unix()})
+	c.Assert(cs.GetByName("Close"), DeepEquals, []float64{1.5})
+}
+
+func (s *RunTestSuite) TestCloseDelay(c *C) {
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "close_delay": "2s"}`)
+	c.Assert(worker.closeDelay, Equals, 2*time.Second)
+	worker = s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "base_timeframe": "1D", "mode": "schedule"}`)
+	c.Assert(worker.closeDelay, Equals, time.Duration(0))
+	c.Assert(worker.scheduleDelay(), Equals, scheduleCloseDelay)
+	worker = s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "base_timeframe": "1D", "mode": "schedule", "close_delay": "1m"}`)
+	c.Assert(worker.scheduleDelay(), Equals, time.Minute)
+
+	for _, config := range []string{
+		`{"symbols": ["EOS"], "close_delay": "-1s"}`,
+		`{"symbols": ["EOS"], "close_delay": "1m"}`,
+		`{"symbols": ["EOS"], "close_delay": "soon"}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf(config))
+	}
+}
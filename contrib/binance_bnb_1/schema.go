@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// schemaMismatch describes how the columns of the stored rows differ from the
+// columns that would be written, or returns "" if they do not or there are
+// no rows
+func schemaMismatch(stored *io.ColumnSeries, columns []string) string {
+	if stored == nil || stored.Len() == 0 {
+		return ""
+	}
+	want := append([]string{"Epoch"}, columns...)
+	names := stored.GetColumnNames()
+	if !reflect.DeepEqual(names, want) {
+		return fmt.Sprintf("columns %v instead of %v", names, want)
+	}
+	for _, name := range columns {
+		if name == tradeNumColumn {
+			if _, ok := stored.GetByName(name).([]int64); !ok {
+				return fmt.Sprintf("column %s of type %T instead of []int64", name, stored.GetByName(name))
+			}
+			continue
+		}
+		if _, ok := stored.GetByName(name).([]float64); !ok {
+			return fmt.Sprintf("column %s of type %T instead of []float64", name, stored.GetByName(name))
+		}
+	}
+	return ""
+}
+
+// Schema returns the columns of the buckets of the worker, Epoch first,
+// which it creates them with
+func (bn *BinanceFetcher) Schema() []io.DataShape {
+	shapes := []io.DataShape{{Name: "Epoch", Type: io.INT64}}
+	for _, name := range bn.storedColumns() {
+		if name == tradeNumColumn {
+			shapes = append(shapes, io.DataShape{Name: name, Type: io.INT64})
+			continue
+		}
+		shapes = append(shapes, io.DataShape{Name: name, Type: io.FLOAT64})
+	}
+	return shapes
+}
+
+// compression hints of the columns, for the storage engines that encode
+// columns
+const (
+	hintNone = "none"
+	// hintDelta stores the differences between consecutive values, which
+	// are constant for the Epoch of candles
+	hintDelta = "delta"
+	// hintFloat is a floating point codec, e.g. XOR of consecutive values
+	hintFloat = "float"
+)
+
+// CompressionHints returns the compression hint of each column of Schema,
+// delta for Epoch and TradeNum and float for the values unless
+// compression_hints says otherwise
+func (bn *BinanceFetcher) CompressionHints() map[string]string {
+	hints := map[string]string{"Epoch": hintDelta}
+	for _, name := range bn.storedColumns() {
+		hints[name] = hintFloat
+	}
+	if bn.includeTradeCount {
+		hints[tradeNumColumn] = hintDelta
+	}
+	for name, hint := range bn.compressionHints {
+		hints[name] = hint
+	}
+	return hints
+}
+
+// checkCompressionHints returns an error if a hint is unknown or names a
+// column that is not written
+func checkCompressionHints(hints map[string]string, columns []string) error {
+	for name, hint := range hints {
+		switch hint {
+		case hintNone, hintDelta, hintFloat:
+		default:
+			return fmt.Errorf("invalid compression hint %q for %s, must be none, delta or float", hint, name)
+		}
+		if name == "Epoch" {
+			continue
+		}
+		written := false
+		for _, column := range columns {
+			written = written || column == name
+		}
+		if !written {
+			return fmt.Errorf("compression hint for %s, which is not written", name)
+		}
+	}
+	return nil
+}
+
+// shapesMismatch describes how the shapes of an existing bucket differ from
+// the declared ones, or returns "" if they do not or are unknown
+func shapesMismatch(existing, declared []io.DataShape) string {
+	if existing == nil {
+		return ""
+	}
+	if len(existing) == len(declared) {
+		equal := true
+		for i := range existing {
+			equal = equal && existing[i].Equal(declared[i])
+		}
+		if equal {
+			return ""
+		}
+	}
+	return fmt.Sprintf("the schema %v instead of %v", existing, declared)
+}
+
+// checkSchemas compares the bucket of each symbol with the columns the worker
+// writes, then creates the missing buckets with its Schema.  A mismatch is an
+// error, unless skip is set and the symbol is dropped with a warning instead.
+func (bn *BinanceFetcher) checkSchemas(skip bool) error {
+	kept := []string{}
+	for _, symbol := range bn.symbols {
+		tbk := bn.tbkFor(symbol)
+		stored, err := bn.store.read(tbk, 0, math.MaxInt64, 1)
+		if err == errExecutorNotInitialized {
+			glog.Warningf("Cannot check the bucket schemas: %v", err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot check the schema of %s: %v", tbk, err)
+		}
+		mismatch := schemaMismatch(stored, bn.storedColumns())
+		if mismatch == "" {
+			existing, err := bn.store.shapes(tbk)
+			if err != nil {
+				return fmt.Errorf("cannot check the schema of %s: %v", tbk, err)
+			}
+			mismatch = shapesMismatch(existing, bn.Schema())
+		}
+		if mismatch == "" {
+			kept = append(kept, symbol)
+			continue
+		}
+		if !skip {
+			return fmt.Errorf("%s has %s, set skip_schema_mismatch to fetch the other symbols", tbk, mismatch)
+		}
+		glog.Warningf("Skipping %s, %s has %s", symbol, tbk, mismatch)
+	}
+	if len(kept) == 0 {
+		return fmt.Errorf("the buckets of all the symbols have another schema")
+	}
+	bn.symbols = kept
+	// the buckets have the declared schema whichever path writes first
+	year := int16(bn.clock.Now().Year())
+	for _, symbol := range kept {
+		tbk := bn.tbkFor(symbol)
+		if err := bn.store.create(tbk, bn.Schema(), bn.CompressionHints(), year); err != nil {
+			return fmt.Errorf("cannot create %s: %v", tbk, err)
+		}
+	}
+	return nil
+}
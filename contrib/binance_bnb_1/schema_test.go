@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSchemaCheck(c *C) {
+	worker := s.newWorker(c, newFixtureClient(c), `{
+        "symbols": ["TRX"],
+        "bucket_name_template": "SCHEMA_{base}",
+        "attribute_group": "PRICES"
+        }`)
+	cs, err := ratesToColumnSeries(syntheticKlines(time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC), 3, time.Minute), false, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	c.Assert(worker.write("TRX", cs, false), IsNil)
+
+	config := `{
+        "symbols": %s,
+        "bucket_name_template": "SCHEMA_{base}",
+        "attribute_group": "PRICES",
+        "columns": ["Close"],
+        "skip_schema_mismatch": %v
+        }`
+	_, err = NewBgWorker(getConfig(fmt.Sprintf(config, `["EOS", "TRX"]`, false)))
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "SCHEMA_TRX/1Min/PRICES"), Equals, true)
+
+	// the symbols without a bucket yet are kept
+	ret, err := NewBgWorker(getConfig(fmt.Sprintf(config, `["EOS", "TRX"]`, true)))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).symbols, DeepEquals, []string{"EOS"})
+	_, err = NewBgWorker(getConfig(fmt.Sprintf(config, `["TRX"]`, true)))
+	c.Assert(err, NotNil)
+
+	// the same columns pass
+	_, err = NewBgWorker(getConfig(`{"symbols": ["TRX"], "bucket_name_template": "SCHEMA_{base}", "attribute_group": "PRICES"}`))
+	c.Assert(err, IsNil)
+}
+
+func (s *RunTestSuite) TestDeclaredSchema(c *C) {
+	worker := s.newWorker(c, newFixtureClient(c), `{
+        "symbols": ["TRX"],
+        "bucket_name_template": "DECLARED_{base}",
+        "attribute_group": "CLOSE",
+        "columns": ["Close"]
+        }`)
+	schema := []io.DataShape{{Name: "Epoch", Type: io.INT64}, {Name: "Close", Type: io.FLOAT64}}
+	c.Assert(worker.Schema(), DeepEquals, schema)
+	// the bucket is created before anything is written
+	shapes, err := worker.store.shapes(io.NewTimeBucketKey("DECLARED_TRX/1Min/CLOSE"))
+	c.Assert(err, IsNil)
+	c.Assert(shapes, DeepEquals, schema)
+
+	// an empty bucket is checked too
+	_, err = NewBgWorker(getConfig(`{
+        "symbols": ["TRX"],
+        "bucket_name_template": "DECLARED_{base}",
+        "attribute_group": "CLOSE",
+        "columns": ["Volume"]
+        }`))
+	c.Assert(err, NotNil)
+}
+
+func (s *RunTestSuite) TestCompressionHints(c *C) {
+	worker := s.newWorker(c, newFixtureClient(c), `{
+        "symbols": ["TRX"],
+        "bucket_name_template": "HINTS_{base}",
+        "attribute_group": "PRICES",
+        "columns": ["Close", "Volume"],
+        "compression_hints": {"Volume": "none"}
+        }`)
+	c.Assert(worker.CompressionHints(), DeepEquals, map[string]string{
+		"Epoch": "delta", "Close": "float", "Volume": "none",
+	})
+
+	for _, hints := range []string{`{"Volume": "zstd"}`, `{"Open": "float"}`} {
+		_, err := NewBgWorker(getConfig(`{"symbols": ["TRX"], "attribute_group": "PRICES",
+            "columns": ["Close", "Volume"], "compression_hints": ` + hints + `}`))
+		c.Assert(err, NotNil, Commentf("%s", hints))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/golang/glog"
+)
+
+// ShardConfig splits the symbols among count identically configured workers,
+// this one fetching those of shard index
+type ShardConfig struct {
+	Index int `json:"index"`
+	Count int `json:"count"`
+}
+
+func (s *ShardConfig) validate() error {
+	if s.Count < 1 || s.Index < 0 || s.Index >= s.Count {
+		return fmt.Errorf("invalid shard index %d of count %d", s.Index, s.Count)
+	}
+	return nil
+}
+
+// symbolShard returns the shard of symbol among count, by a hash that does
+// not depend on the other symbols so that listing a new one moves no other
+func symbolShard(symbol string, count int) int {
+	h := fnv.New32a()
+	h.Write([]byte(symbol))
+	return int(h.Sum32() % uint32(count))
+}
+
+// shardSymbols returns the symbols of the shard, in their order
+func shardSymbols(symbols []string, shard *ShardConfig) []string {
+	selected := []string{}
+	for _, symbol := range symbols {
+		if symbolShard(symbol, shard.Count) == shard.Index {
+			selected = append(selected, symbol)
+		}
+	}
+	return selected
+}
+
+// warnMaxSymbols suggests a shard count when the worker has more symbols
+// than max_symbols
+func warnMaxSymbols(symbols, maxSymbols int, shard *ShardConfig) {
+	if maxSymbols <= 0 || symbols <= maxSymbols {
+		return
+	}
+	count := 1
+	if shard != nil {
+		count = shard.Count
+	}
+	total := symbols * count
+	glog.Warningf("%d symbols are more than max_symbols %d, consider a shard count of %d or more",
+		symbols, maxSymbols, (total+maxSymbols-1)/maxSymbols)
+}
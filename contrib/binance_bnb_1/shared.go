@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// sharedClient is the HTTP client, request weight limiter and Binance client
+// of the workers that reach a venue the same way.  Binance limits the weight
+// per IP, so the workers of the process for different quotes or timeframes
+// draw from a single limiter instead of exceeding the limit together.
+type sharedClient struct {
+	once sync.Once
+	err  error
+	// venue has the shared httpClient and limiter set
+	venue   venue
+	limiter *weightLimiter
+	client  klinesClient
+}
+
+var (
+	sharedMu sync.Mutex
+	// sharedClients maps the venue, proxy_url and ca_file to their client
+	sharedClients = map[string]*sharedClient{}
+)
+
+// getSharedClient returns the client of the venue through the proxy and CA
+// bundle, constructing it for the first worker asking for it.  The shared
+// clients hold no resources to release, so they outlive the workers.
+func getSharedClient(name string, v venue, proxyURL, caFile string) (*sharedClient, error) {
+	key := strings.Join([]string{name, proxyURL, caFile}, "|")
+	sharedMu.Lock()
+	s, ok := sharedClients[key]
+	if !ok {
+		s = &sharedClient{}
+		sharedClients[key] = s
+	}
+	sharedMu.Unlock()
+
+	s.once.Do(func() {
+		httpClient, err := newHTTPClient(proxyURL, caFile)
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.limiter = newWeightLimiter(defaultWeightLimit, defaultWeightWindow)
+		v.httpClient = httpClient
+		v.limiter = s.limiter
+		s.venue = v
+		s.client = &weightedClient{&binanceClient{v.newClient()}, s.limiter}
+	})
+	return s, s.err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	shutdownOnce sync.Once
+	// shutdownRequested is closed once the server starts its graceful
+	// shutdown, which leaves the workers a grace period to stop
+	shutdownRequested = make(chan struct{})
+)
+
+// watchShutdown closes shutdownRequested on the signals the server shuts
+// down gracefully on.  The server keeps handling them.
+func watchShutdown() <-chan struct{} {
+	shutdownOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(shutdownRequested)
+		}()
+	})
+	return shutdownRequested
+}
+
+// shuttingDown tells whether the server is shutting down
+func (bn *BinanceFetcher) shuttingDown() bool {
+	select {
+	case <-bn.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d, returning false early if the server shuts down
+func (bn *BinanceFetcher) sleep(d time.Duration) bool {
+	select {
+	case <-bn.shutdown:
+		return false
+	case <-bn.clock.After(d):
+		return true
+	}
+}
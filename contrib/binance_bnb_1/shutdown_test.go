@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestShutdown(c *C) {
+	config := `{
+        "symbols": ["EOS", "TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "%s_{base}",
+        "finish_pass_on_shutdown": %v
+        }`
+	run := func(name string, finishPass bool) {
+		shutdown := make(chan struct{})
+		client := &shutdownClient{newFixtureClient(c, "EOSBNB", "TRXBNB"), shutdown}
+		worker := s.newWorker(c, client, fmt.Sprintf(config, name, finishPass))
+		worker.shutdown = shutdown
+		worker.Run()
+	}
+
+	// the server shuts down while EOS is fetched, which is still written
+	run("SHUTDOWN", false)
+	c.Assert(readBucket(c, "SHUTDOWN_EOS/1Min/OHLCV").Len() > 0, Equals, true)
+	last, err := findLastTimestamp("TRX", io.NewTimeBucketKey("SHUTDOWN_TRX/1Min/OHLCV"))
+	c.Assert(err, IsNil)
+	c.Assert(last.IsZero(), Equals, true)
+
+	run("FINISHPASS", true)
+	c.Assert(readBucket(c, "FINISHPASS_EOS/1Min/OHLCV").Len() > 0, Equals, true)
+	c.Assert(readBucket(c, "FINISHPASS_TRX/1Min/OHLCV").Len() > 0, Equals, true)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/golang/glog"
+)
+
+// siblingTimeframes returns the last candle of each bucket of symbol in
+// another timeframe than its own, with the same bucket name and attribute
+// group.  Reconfiguring the base timeframe leaves the bucket of the
+// previous one behind.
+func (bn *BinanceFetcher) siblingTimeframes(symbol string) (map[string]time.Time, error) {
+	name := bn.bucketName(symbol)
+	timeframes, err := bn.store.timeframes(name, bn.attributeGroup)
+	if err != nil {
+		return nil, err
+	}
+	siblings := map[string]time.Time{}
+	for _, tf := range timeframes {
+		if tf == bn.timeframeOf(symbol).String {
+			continue
+		}
+		last, err := lastStoredTime(bn.store, candleKey(name, utils.NewTimeframe(tf), bn.attributeGroup))
+		if err != nil {
+			return nil, err
+		}
+		if !last.IsZero() {
+			siblings[tf] = last
+		}
+	}
+	return siblings, nil
+}
+
+// warnSiblingTimeframes logs the buckets of the symbols in other timeframes,
+// which may be stranded by a change of base_timeframe unless another worker
+// or a trigger writes them
+func (bn *BinanceFetcher) warnSiblingTimeframes() {
+	for _, symbol := range bn.symbols {
+		siblings, err := bn.siblingTimeframes(symbol)
+		if err != nil {
+			glog.Warningf("Cannot list the other timeframes of %s: %v", symbol, err)
+			return
+		}
+		for tf, last := range siblings {
+			glog.Warningf("%s also has %s candles in %s up to %v, which this worker collecting its %s candles does not write",
+				symbol, tf, candleKey(bn.bucketName(symbol), utils.NewTimeframe(tf), bn.attributeGroup).GetItemKey(),
+				last, bn.timeframeOf(symbol).String)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSiblingTimeframes(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	cs, err := ratesToColumnSeries(syntheticKlines(start, 3, time.Minute), false, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	config := `{"symbols": ["EOS"], "bucket_name_template": "SIBLING_{base}", "base_timeframe": "%s"}`
+
+	worker := s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, "1Min"))
+	c.Assert(worker.write("EOS", cs, false), IsNil)
+	siblings, err := worker.siblingTimeframes("EOS")
+	c.Assert(err, IsNil)
+	c.Assert(siblings, HasLen, 0)
+
+	worker = s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, "5Min"))
+	siblings, err = worker.siblingTimeframes("EOS")
+	c.Assert(err, IsNil)
+	c.Assert(siblings, HasLen, 1)
+	c.Assert(siblings["1Min"].Equal(start.Add(2*time.Minute)), Equals, true)
+}
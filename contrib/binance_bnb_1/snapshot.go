@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// snapshotSymbol stands for all the symbols in the name of the snapshot
+// bucket
+const snapshotSymbol = "ALL"
+
+// snapshotKey is the bucket of the latest candle of every symbol, e.g.
+// BINANCE_BNB_ALL/1Min/SNAPSHOT.  Its records are variable length, so that an
+// interval holds a row per symbol.
+func (bn *BinanceFetcher) snapshotKey() *io.TimeBucketKey {
+	return io.NewTimeBucketKey(bn.bucketName(snapshotSymbol) + "/" + bn.baseTimeframe.String + "/SNAPSHOT")
+}
+
+// checkSnapshotColumns returns an error if the columns lack those of the
+// snapshot
+func checkSnapshotColumns(columns []string) error {
+	for _, name := range []string{"Close", "Volume"} {
+		if columnIndex(columns, name) < 0 {
+			return fmt.Errorf("snapshot needs the %s column", name)
+		}
+	}
+	return nil
+}
+
+func columnIndex(columns []string, name string) int {
+	for i, c := range columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeSnapshot writes the newest candle written of each symbol to the
+// SNAPSHOT bucket with snapshot set, once per interval, with now as Epoch:
+// SymbolIndex is the position of the symbol in the symbols of the worker and
+// CandleEpoch the time of its candle, along with its Close and Volume.  The
+// rows of the last interval are the latest candles of all the symbols.
+func (bn *BinanceFetcher) writeSnapshot(now time.Time) {
+	if !bn.snapshot {
+		return
+	}
+	if err := bn.store.ready(); err != nil {
+		glog.Errorf("Cannot write the snapshot: %v", err)
+		return
+	}
+	tbk := bn.snapshotKey()
+	interval := now.Truncate(bn.baseTimeframe.Duration)
+	if bn.snapshotAt.IsZero() {
+		// a restart within the interval does not write it twice
+		last, err := lastStoredTime(bn.store, tbk)
+		if err != nil {
+			glog.Errorf("Cannot read the last snapshot: %v", err)
+			return
+		}
+		bn.snapshotAt = last.Truncate(bn.baseTimeframe.Duration)
+	}
+	if !interval.After(bn.snapshotAt) {
+		return
+	}
+
+	closeAt, volumeAt := columnIndex(bn.columns, "Close"), columnIndex(bn.columns, "Volume")
+	var epochs, indexes, candles []int64
+	var closes, volumes []float64
+	bn.mu.Lock()
+	for i, symbol := range bn.symbols {
+		row, ok := bn.lastRows[symbol]
+		if !ok {
+			continue
+		}
+		epochs = append(epochs, now.Unix())
+		indexes = append(indexes, int64(i))
+		candles = append(candles, row.epoch)
+		closes = append(closes, row.values[closeAt])
+		volumes = append(volumes, row.values[volumeAt])
+	}
+	bn.mu.Unlock()
+	if len(epochs) == 0 {
+		return
+	}
+
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epochs)
+	cs.AddColumn("SymbolIndex", indexes)
+	cs.AddColumn("CandleEpoch", candles)
+	cs.AddColumn("Close", closes)
+	cs.AddColumn("Volume", volumes)
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	if err := bn.store.write(csm); err != nil {
+		glog.Errorf("Cannot write the snapshot: %v", err)
+		return
+	}
+	bn.snapshotAt = interval
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSnapshot(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	worker := s.newWorker(c, newFixtureClient(c), `{
+        "symbols": ["EOS", "TRX", "ADA"],
+        "bucket_name_template": "SNAP_{base}",
+        "snapshot": true
+        }`)
+	keys := worker.BucketKeys()
+	c.Assert(keys[len(keys)-1].GetItemKey(), Equals, "SNAP_ALL/1Min/SNAPSHOT")
+	for symbol, n := range map[string]int{"EOS": 60, "TRX": 30} {
+		cs, err := ratesToColumnSeries(syntheticKlines(start, n, time.Minute), false, "open", onErrorSkip)
+		c.Assert(err, IsNil)
+		c.Assert(worker.write(symbol, cs, false), IsNil)
+	}
+
+	now := start.Add(time.Hour + 30*time.Second)
+	worker.writeSnapshot(now)
+	snapshot := readBucket(c, "SNAP_ALL/1Min/SNAPSHOT")
+	// ADA has no candle yet
+	c.Assert(snapshot.GetByName("SymbolIndex").([]int64), DeepEquals, []int64{0, 1})
+	c.Assert(snapshot.GetByName("CandleEpoch").([]int64), DeepEquals, []int64{
+		start.Add(59 * time.Minute).Unix(), start.Add(29 * time.Minute).Unix(),
+	})
+	c.Assert(snapshot.GetByName("Close").([]float64), DeepEquals, []float64{1.5, 1.5})
+	c.Assert(snapshot.GetByName("Volume").([]float64), DeepEquals, []float64{10, 10})
+
+	// once per interval
+	worker.writeSnapshot(now.Add(10 * time.Second))
+	c.Assert(readBucket(c, "SNAP_ALL/1Min/SNAPSHOT").Len(), Equals, 2)
+	worker.writeSnapshot(now.Add(time.Minute))
+	c.Assert(readBucket(c, "SNAP_ALL/1Min/SNAPSHOT").Len(), Equals, 4)
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "columns": ["Open", "Close"], "snapshot": true}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// stagingSuffix is appended to the symbol of the bucket of the candles to
+// get its staging bucket
+const stagingSuffix = "_STAGING"
+
+// stagingKey is the key of the staging bucket of the candles of symbol, e.g.
+// BINANCE_BNB_EOS_STAGING/1Min/OHLCV
+func (bn *BinanceFetcher) stagingKey(symbol string) *io.TimeBucketKey {
+	tbk := bn.liveKey(symbol)
+	tbk.SetItemInCategory("Symbol", tbk.GetItemInCategory("Symbol")+stagingSuffix)
+	return tbk
+}
+
+// promoteStaged replaces the bucket of each symbol with its staging bucket
+// once the staging bucket holds increasing candles one base timeframe apart,
+// without a gap given up by window_retries, that all match the API in a
+// verified sample.  A symbol failing a check is logged and left staged, for
+// its bucket to stay as it was.
+func (bn *BinanceFetcher) promoteStaged(rng *rand.Rand) {
+	for _, symbol := range bn.symbols {
+		staging, live := bn.stagingKey(symbol), bn.liveKey(symbol)
+		stored, err := bn.store.read(staging, planner.MinEpoch, planner.MaxEpoch, 0)
+		if err != nil {
+			glog.Errorf("Cannot read %s to promote it: %v", staging.String(), err)
+			continue
+		}
+		if stored == nil || stored.Len() == 0 {
+			glog.Warningf("Not promoting %s, it is empty", staging.String())
+			continue
+		}
+		epoch := stored.GetEpoch()
+		increasing := true
+		for i := 1; i < len(epoch); i++ {
+			if epoch[i] <= epoch[i-1] {
+				increasing = false
+				break
+			}
+		}
+		if !increasing {
+			glog.Warningf("Not promoting %s, its candles are not in increasing order", staging.String())
+			continue
+		}
+		// the gaps are those of this run, a restart or an earlier run may have
+		// left others in the bucket
+		if missing := bn.missingCandle(symbol, epoch); missing != 0 {
+			glog.Warningf("Not promoting %s, it misses the candle at %v", staging.String(), time.Unix(missing, 0).UTC())
+			continue
+		}
+		bn.mu.Lock()
+		gaps := len(bn.gaps[symbol])
+		bn.mu.Unlock()
+		if gaps > 0 {
+			glog.Warningf("Not promoting %s, it has %d gaps", staging.String(), gaps)
+			continue
+		}
+		if res := bn.verifySymbol(symbol, rng); res.mismatched > 0 || res.errors > 0 {
+			glog.Warningf("Not promoting %s, %d candles mismatched the API and %d could not be verified",
+				staging.String(), res.mismatched, res.errors)
+			continue
+		}
+		if err := bn.store.promote(staging, live); err != nil {
+			glog.Errorf("Cannot promote %s to %s: %v", staging.String(), live.String(), err)
+			continue
+		}
+		glog.Infof("Promoted %d candles of %s to %s", len(epoch), symbol, live.String())
+	}
+}
+
+// missingCandle returns the open time of the first candle of symbol missing
+// between the increasing epochs, 0 if they are contiguous.  The epochs may be
+// open or close times, see klineEpoch.
+func (bn *BinanceFetcher) missingCandle(symbol string, epoch []int64) int64 {
+	tf := bn.timeframeOf(symbol)
+	for i := 1; i < len(epoch); i++ {
+		next := candleClose(candleOpen(time.Unix(epoch[i-1], 0), tf), tf)
+		if candleOpen(time.Unix(epoch[i], 0), tf).After(next) {
+			return next.Unix()
+		}
+	}
+	return 0
+}
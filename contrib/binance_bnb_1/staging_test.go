@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/planner"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestStaging(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	config := `{
+        "symbols": ["EOS", "TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "STAGED_{base}",
+        "request_span": 20,
+        "advance_step": 20,
+        "window_retries": 1,
+        "staging": true
+        }`
+	client := &badWindowClient{
+		klinesClient: newFixtureClient(c, "EOSBNB", "TRXBNB"),
+		symbol:       "TRXBNB",
+		startTime:    timeToMillis(start.Add(20 * time.Minute)),
+	}
+	worker := s.newWorker(c, client, config)
+	worker.clock = &fakeClock{now: start.Add(24 * time.Hour)}
+	c.Assert(worker.tbkFor("EOS").String(), Equals, "STAGED_EOS_STAGING/1Min/OHLCV")
+	worker.Run()
+
+	// EOS is verified and promoted, TRX has a gap and stays staged
+	c.Assert(readBucket(c, "STAGED_EOS/1Min/OHLCV").GetEpoch(), HasLen, 61)
+	cs, err := readRange(worker.stagingKey("EOS"), planner.MinEpoch, planner.MaxEpoch)
+	c.Assert(err, IsNil)
+	c.Assert(cs, IsNil)
+	cs, err = readRange(worker.liveKey("TRX"), planner.MinEpoch, planner.MaxEpoch)
+	c.Assert(err, IsNil)
+	c.Assert(cs, IsNil)
+	c.Assert(readBucket(c, "STAGED_TRX_STAGING/1Min/OHLCV").GetEpoch(), HasLen, 41)
+
+	// nor those missing candles, even without the gaps of the run
+	worker = s.newWorker(c, newFixtureClient(c, "TRXBNB"), strings.Replace(config, `"EOS", `, "", 1))
+	worker.verifySamples = 1000
+	worker.promoteStaged(rand.New(rand.NewSource(1)))
+	cs, err = readRange(worker.liveKey("TRX"), planner.MinEpoch, planner.MaxEpoch)
+	c.Assert(err, IsNil)
+	c.Assert(cs, IsNil)
+	c.Assert(worker.missingCandle("TRX", readBucket(c, "STAGED_TRX_STAGING/1Min/OHLCV").GetEpoch()), Equals, start.Add(20*time.Minute).Unix())
+
+	// the candles of a symbol that differ from the API are not promoted
+	trx := newFixtureClient(c, "TRXBNB")
+	corrected := *trx.klines["TRXBNB"][10]
+	corrected.Close = "0.00245700"
+	trx.klines["TRXBNB"][10] = &corrected
+	worker = s.newWorker(c, trx, strings.Replace(config, `"EOS", `, "", 1))
+	worker.verifySamples = 1000
+	worker.promoteStaged(rand.New(rand.NewSource(1)))
+	cs, err = readRange(worker.liveKey("TRX"), planner.MinEpoch, planner.MaxEpoch)
+	c.Assert(err, IsNil)
+	c.Assert(cs, IsNil)
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "staging": true}`))
+	c.Assert(err, NotNil)
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "query_end": "2018-08-01 01:00", "staging": true, "remote_endpoint": "http://localhost:5993/rpc"}`))
+	c.Assert(err, NotNil)
+}
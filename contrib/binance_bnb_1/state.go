@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// symbolState is the collection state of a symbol in the /state response
+type symbolState struct {
+	// LastCandle is the time of the last candle written, zero if none
+	LastCandle time.Time `json:"last_candle"`
+	// LagSeconds is how long ago the candle after the last one closed
+	LagSeconds  float64   `json:"lag_seconds"`
+	LastSuccess time.Time `json:"last_success"`
+	Errors      int64     `json:"errors"`
+	RowsWritten int64     `json:"rows_written"`
+	Quiet       bool      `json:"quiet"`
+	// Gaps are the backfill windows given up, see windowFailed
+	Gaps []gap `json:"gaps,omitempty"`
+	// Span is the learned span of the backfill requests in intervals, with
+	// adaptive_span
+	Span int `json:"span,omitempty"`
+}
+
+// workerState is the state of a worker in the /state response
+type workerState struct {
+	Quote     string                 `json:"quote"`
+	Timeframe string                 `json:"timeframe"`
+	Mode      string                 `json:"mode"`
+	Phase     string                 `json:"phase"`
+	Suspended bool                   `json:"suspended"`
+	Limiter   limiterState           `json:"limiter"`
+	Symbols   map[string]symbolState `json:"symbols"`
+}
+
+// setPhase records the phase of the Run loop for the state
+func (bn *BinanceFetcher) setPhase(p phase) {
+	bn.mu.Lock()
+	bn.phase = p
+	bn.mu.Unlock()
+}
+
+// state returns the state of the worker at now, read under its locks
+func (bn *BinanceFetcher) state(now time.Time) workerState {
+	bn.suspension.mu.Lock()
+	suspended := bn.suspension.suspended
+	bn.suspension.mu.Unlock()
+	ws := workerState{
+		Quote:     bn.baseCurrency,
+		Timeframe: bn.baseTimeframe.String,
+		Mode:      bn.mode,
+		Suspended: suspended,
+		Limiter:   bn.limiterState(),
+		Symbols:   map[string]symbolState{},
+	}
+
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	ws.Phase = bn.phase.String()
+	for _, symbol := range bn.symbols {
+		st := bn.collectionStatus(symbol)
+		ss := symbolState{
+			LastSuccess: st.lastSuccess,
+			Errors:      st.errors,
+			RowsWritten: st.rowsWritten,
+			Quiet:       st.quiet,
+			Gaps:        bn.gaps[symbol],
+			Span:        bn.spans[symbol],
+		}
+		if last, ok := bn.lastWritten[symbol]; ok {
+			ss.LastCandle = time.Unix(last, 0).UTC()
+			if closed := ss.LastCandle.Add(2 * bn.timeframeOf(symbol).Duration); now.After(closed) {
+				ss.LagSeconds = now.Sub(closed).Seconds()
+			}
+		}
+		ws.Symbols[symbol] = ss
+	}
+	return ws
+}
+
+// stateHandler serves the state of the workers as JSON
+func stateHandler(workers []*BinanceFetcher) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		states := []workerState{}
+		for _, bn := range workers {
+			states = append(states, bn.state(bn.clock.Now().UTC()))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(states); err != nil {
+			glog.Errorf("Cannot write the state: %v", err)
+		}
+	})
+	return mux
+}
+
+// serveState serves the state of the workers on /state at addr, e.g.
+//
+//	curl localhost:6061/state
+func serveState(addr string, workers []*BinanceFetcher) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cannot serve the state on %s: %v", addr, err)
+	}
+	glog.Infof("Serving the state of the Binance fetcher on %s/state", l.Addr())
+	go func() {
+		if err := http.Serve(l, stateHandler(workers)); err != nil {
+			glog.Errorf("Stopped serving the state: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestState(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS", "TRX"], "bucket_name_template": "STATE_{base}"}`)
+	worker.clock = &fakeClock{now: start.Add(10 * time.Minute)}
+	cs, err := ratesToColumnSeries(syntheticKlines(start, 3, time.Minute), false, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	c.Assert(worker.write("EOS", cs, false), IsNil)
+	worker.setPhase(phaseLive)
+
+	rec := httptest.NewRecorder()
+	stateHandler([]*BinanceFetcher{worker}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/state", nil))
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	var states []workerState
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &states), IsNil)
+	c.Assert(states, HasLen, 1)
+	c.Assert(states[0].Quote, Equals, "BNB")
+	c.Assert(states[0].Phase, Equals, "live")
+	c.Assert(states[0].Mode, Equals, modeLoop)
+	eos := states[0].Symbols["EOS"]
+	c.Assert(eos.LastCandle.Equal(start.Add(2*time.Minute)), Equals, true)
+	// the candle of 00:03 closed at 00:04
+	c.Assert(eos.LagSeconds, Equals, float64(6*60))
+	c.Assert(eos.RowsWritten, Equals, int64(3))
+	c.Assert(states[0].Symbols["TRX"].LastCandle.IsZero(), Equals, true)
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "state_addr": "localhost:-1"}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// statusRefreshInterval is how often symbol statuses are checked for pausing
+// and resuming symbols unless status_refresh_interval is set, raised to
+// min_refresh_interval
+const statusRefreshInterval = 10 * time.Minute
+
+// defaultMinRefreshInterval is min_refresh_interval unless it is set, so that
+// refreshes cannot pile up on exchangeInfo
+const defaultMinRefreshInterval = time.Hour
+
+// statusRefresh spaces the refreshes of the symbol statuses of a worker
+type statusRefresh struct {
+	mu sync.Mutex
+	// interval is status_refresh_interval, and minInterval the shortest
+	// time between two attempts, min_refresh_interval
+	interval    time.Duration
+	minInterval time.Duration
+	attemptedAt time.Time
+	// running is set while a refresh is in progress
+	running bool
+}
+
+// start returns whether a refresh may start at now, the last successful
+// one at refreshedAt, and marks it in progress if so.  A refresh is not
+// started while another one is in progress, nor less than minInterval after
+// the previous attempt, so that a failing exchangeInfo is not requested on
+// every pass.
+func (r *statusRefresh) start(now, refreshedAt time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running || now.Sub(refreshedAt) < r.interval || now.Sub(r.attemptedAt) < r.minInterval {
+		return false
+	}
+	r.running, r.attemptedAt = true, now
+	return true
+}
+
+func (r *statusRefresh) done() {
+	r.mu.Lock()
+	r.running = false
+	r.mu.Unlock()
+}
+
+// statusRefreshConfig returns status_refresh_interval and
+// min_refresh_interval
+func statusRefreshConfig(config *FetcherConfig) (time.Duration, time.Duration, error) {
+	interval, minInterval := statusRefreshInterval, defaultMinRefreshInterval
+	if config.MinRefreshInterval != "" {
+		d, err := time.ParseDuration(config.MinRefreshInterval)
+		if err != nil || d < time.Second {
+			return 0, 0, fmt.Errorf("invalid min_refresh_interval %q, must be at least 1s", config.MinRefreshInterval)
+		}
+		minInterval = d
+	}
+	if interval < minInterval {
+		interval = minInterval
+	}
+	if config.StatusRefreshInterval != "" {
+		d, err := time.ParseDuration(config.StatusRefreshInterval)
+		if err != nil || d < minInterval {
+			return 0, 0, fmt.Errorf("invalid status_refresh_interval %q, must be at least min_refresh_interval %v",
+				config.StatusRefreshInterval, minInterval)
+		}
+		interval = d
+	}
+	return interval, minInterval, nil
+}
+
+// symbolStatuses returns the exchangeInfo status of each base asset quoted
+// in quoteAsset
+func symbolStatuses(info *ExchangeInfo, quoteAsset string) map[string]string {
+	statuses := map[string]string{}
+	for _, symbol := range info.Symbols {
+		if symbol.QuoteAsset == quoteAsset {
+			statuses[symbol.BaseAsset] = symbol.Status
+		}
+	}
+	return statuses
+}
+
+// refreshStatuses pauses the symbols whose status is not allowed, e.g.
+// during exchange maintenance, and resumes them once it is again.  Symbols
+// missing from exchangeInfo are left as they are.  The request weight limit
+// and the server time offset are updated along the way.  Refreshes are
+// spaced by status_refresh_interval, see statusRefresh.
+func (bn *BinanceFetcher) refreshStatuses(now time.Time) {
+	if !bn.statusRefresh.start(now, bn.statusRefreshedAt) {
+		return
+	}
+	defer bn.statusRefresh.done()
+	info := ExchangeInfo{}
+	sent := bn.clock.Now()
+	err := getJson(bn.venue.jsonHTTPClient(), bn.venue.exchangeInfoURL(), &info)
+	if err == nil {
+		err = info.validate()
+	}
+	if err != nil {
+		glog.Errorf("Binance /exchangeInfo API error after %v: %v", bn.clock.Now().Sub(sent), err)
+		return
+	}
+	defer func() {
+		glog.Infof("Refreshed the statuses of the %s symbols in %v", bn.baseCurrency, bn.clock.Now().Sub(sent))
+	}()
+	bn.observeServerTime(info.ServerTime, sent, bn.clock.Now())
+	bn.statusRefreshedAt = now
+	if limit, window, ok := requestWeightLimit(&info); ok {
+		bn.limiter.setLimit(limit, window)
+	}
+	statuses := symbolStatuses(&info, bn.baseCurrency)
+	for _, symbol := range bn.symbols {
+		status, ok := statuses[symbol]
+		if !ok {
+			continue
+		}
+		switch {
+		case !bn.allowedStatuses[status] && !bn.paused[symbol]:
+			glog.Warningf("Pausing %s while its status is %s", symbol, status)
+			bn.paused[symbol] = true
+		case bn.allowedStatuses[status] && bn.paused[symbol]:
+			glog.Infof("Resuming %s, its status is %s", symbol, status)
+			delete(bn.paused, symbol)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSymbolStatuses(c *C) {
+	info, err := ioutil.ReadFile(filepath.Join("testdata", "exchangeInfo.json"))
+	c.Assert(err, IsNil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(info)
+	}))
+	defer server.Close()
+
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS", "VEN", "TRX", "XRP"]}`)
+	worker.venue.baseURL = server.URL
+	worker.limiter.setLimit(100, time.Second)
+	interval := worker.statusRefresh.interval
+	c.Assert(interval, Equals, time.Hour)
+	now := worker.statusRefreshedAt.Add(interval)
+
+	// VEN is paused during the break, XRP is not listed and stays
+	worker.refreshStatuses(now)
+	c.Assert(worker.paused, DeepEquals, map[string]bool{"VEN": true})
+	c.Assert(worker.limiter.limit, Equals, 1200)
+	c.Assert(worker.limiter.window, Equals, time.Minute)
+
+	// statuses are not checked again before the interval
+	info = bytes.Replace(info, []byte(`"BREAK"`), []byte(`"TRADING"`), 1)
+	worker.refreshStatuses(now.Add(time.Minute))
+	c.Assert(worker.paused, DeepEquals, map[string]bool{"VEN": true})
+
+	worker.refreshStatuses(now.Add(interval))
+	c.Assert(worker.paused, DeepEquals, map[string]bool{})
+}
+
+func (s *RunTestSuite) TestStatusRefreshInterval(c *C) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "min_refresh_interval": "5m"}`)
+	c.Assert(worker.statusRefresh.interval, Equals, statusRefreshInterval)
+	c.Assert(worker.statusRefresh.minInterval, Equals, 5*time.Minute)
+	worker.venue.baseURL = server.URL
+	worker.limiter.setLimit(100, time.Second)
+	now := worker.statusRefreshedAt.Add(statusRefreshInterval)
+
+	// a failing exchangeInfo is not requested again before the minimum
+	for i := 0; i < 5; i++ {
+		worker.refreshStatuses(now.Add(time.Duration(i) * time.Minute))
+	}
+	c.Assert(requests, Equals, 1)
+	worker.refreshStatuses(now.Add(5 * time.Minute))
+	c.Assert(requests, Equals, 2)
+
+	// nor while a refresh is in progress
+	worker.statusRefresh.running = true
+	worker.refreshStatuses(now.Add(time.Hour))
+	c.Assert(requests, Equals, 2)
+	worker.statusRefresh.running = false
+	worker.refreshStatuses(now.Add(time.Hour))
+	c.Assert(requests, Equals, 3)
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["EOS"], "status_refresh_interval": "1m"}`))
+	c.Assert(err, NotNil)
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "min_refresh_interval": "10ms"}`))
+	c.Assert(err, NotNil)
+	worker = s.newWorker(c, newFixtureClient(c), `{
+        "symbols": ["EOS"],
+        "status_refresh_interval": "1m",
+        "min_refresh_interval": "30s"
+        }`)
+	c.Assert(worker.statusRefresh.interval, Equals, time.Minute)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/frontend"
+	"github.com/alpacahq/marketstore/frontend/client"
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// store is where the fetcher reads and writes its buckets
+type store interface {
+	// ready returns an error if the store cannot be written to yet
+	ready() error
+	write(csm io.ColumnSeriesMap) error
+	// read returns the rows of the bucket between start and end epochs
+	// inclusive, only the last limit of them if limit is positive, or nil
+	// if the bucket does not exist
+	read(tbk *io.TimeBucketKey, start, end int64, limit int) (*io.ColumnSeries, error)
+	// readFirst returns the first limit rows of the bucket, or nil if it
+	// does not exist
+	readFirst(tbk *io.TimeBucketKey, limit int) (*io.ColumnSeries, error)
+	// shapes returns the columns of the bucket, Epoch first, or nil if it
+	// does not exist or the store cannot tell
+	shapes(tbk *io.TimeBucketKey) ([]io.DataShape, error)
+	// create creates the bucket with the shapes if it does not exist, or
+	// leaves it to the first write if the store cannot.  hints are the
+	// compression hints of the columns, see CompressionHints.
+	create(tbk *io.TimeBucketKey, shapes []io.DataShape, hints map[string]string, year int16) error
+	// timeframes returns the timeframes of the buckets of the name and
+	// attribute group, or nil if the store cannot tell
+	timeframes(name, attributeGroup string) ([]string, error)
+	// promote replaces the bucket live with the bucket staging, see
+	// executor.PromoteBucket
+	promote(staging, live *io.TimeBucketKey) error
+}
+
+// variableAttributeGroups are the attribute groups of the buckets holding
+// any number of rows per interval, e.g. trades, which are written as
+// variable length records.  The others hold a row per interval.
+var variableAttributeGroups = map[string]bool{
+	"TRADE":    true,
+	"TRADES":   true,
+	"TICKER":   true,
+	"TICKS":    true,
+	"QUOTE":    true,
+	"SNAPSHOT": true,
+	"FUNDING":  true,
+}
+
+// isVariableLength returns whether the records of the bucket are variable
+// length, from its attribute group
+func isVariableLength(tbk *io.TimeBucketKey) bool {
+	return variableAttributeGroups[strings.ToUpper(tbk.GetItemInCategory("AttributeGroup"))]
+}
+
+// recordType returns the record type of the bucket, see isVariableLength
+func recordType(tbk *io.TimeBucketKey) io.EnumRecordType {
+	if isVariableLength(tbk) {
+		return io.VARIABLE
+	}
+	return io.FIXED
+}
+
+// newStore returns the store of the remote_endpoint, or the marketstore
+// instance the plugin is loaded into if it is empty
+func newStore(endpoint string) (store, error) {
+	if endpoint == "" {
+		return localStore{}, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote_endpoint %q", endpoint)
+	}
+	cl, err := client.NewClient(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return &remoteStore{cl}, nil
+}
+
+// localStore goes through the executor of the marketstore instance the
+// plugin is loaded into
+type localStore struct{}
+
+func (localStore) ready() error {
+	return checkWriter()
+}
+
+// write writes the fixed and the variable length buckets of csm with a
+// WriteCSM each, failing if a bucket exists with the other record type
+func (localStore) write(csm io.ColumnSeriesMap) error {
+	if err := checkWriter(); err != nil {
+		return err
+	}
+	cDir := executor.ThisInstance.CatalogDir
+	fixed, variable := io.NewColumnSeriesMap(), io.NewColumnSeriesMap()
+	for tbk, cs := range csm {
+		tbk := tbk
+		rt := recordType(&tbk)
+		if tbi, err := cDir.GetLatestTimeBucketInfoFromKey(&tbk); err == nil && tbi.GetRecordType() != rt {
+			return fmt.Errorf("%s holds %s records, cannot write %s ones", tbk.String(),
+				recordTypeName(tbi.GetRecordType()), recordTypeName(rt))
+		}
+		if rt == io.VARIABLE {
+			variable[tbk] = cs
+		} else {
+			fixed[tbk] = cs
+		}
+	}
+	if len(fixed) > 0 {
+		if err := executor.WriteCSM(fixed, false); err != nil {
+			return err
+		}
+	}
+	if len(variable) > 0 {
+		return executor.WriteCSM(variable, true)
+	}
+	return nil
+}
+
+func recordTypeName(rt io.EnumRecordType) string {
+	if rt == io.VARIABLE {
+		return "variable length"
+	}
+	return "fixed length"
+}
+
+func (s localStore) read(tbk *io.TimeBucketKey, start, end int64, limit int) (*io.ColumnSeries, error) {
+	return s.query(tbk, start, end, limit, io.LAST)
+}
+
+func (s localStore) readFirst(tbk *io.TimeBucketKey, limit int) (*io.ColumnSeries, error) {
+	return s.query(tbk, 0, math.MaxInt64, limit, io.FIRST)
+}
+
+func (localStore) query(tbk *io.TimeBucketKey, start, end int64, limit int, direction io.DirectionEnum) (*io.ColumnSeries, error) {
+	cDir, err := catalogDir()
+	if err != nil {
+		return nil, err
+	}
+	query := planner.NewQuery(cDir)
+	query.AddTargetKey(tbk)
+	query.SetRange(start, end)
+	if limit > 0 {
+		query.SetRowLimit(direction, limit)
+	}
+	parsed, err := query.Parse()
+	if err != nil {
+		// the bucket does not exist yet
+		return nil, nil
+	}
+	reader, err := executor.NewReader(parsed)
+	if err != nil {
+		return nil, err
+	}
+	csm, _, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return csm[*tbk], nil
+}
+
+func (localStore) shapes(tbk *io.TimeBucketKey) ([]io.DataShape, error) {
+	cDir, err := catalogDir()
+	if err != nil {
+		return nil, err
+	}
+	tbi, err := cDir.GetLatestTimeBucketInfoFromKey(tbk)
+	if err != nil {
+		// the bucket does not exist yet
+		return nil, nil
+	}
+	return tbi.GetDataShapesWithEpoch(), nil
+}
+
+// create does not apply the hints, the catalog stores fixed width columns
+// without encodings for now
+func (localStore) create(tbk *io.TimeBucketKey, shapes []io.DataShape, hints map[string]string, year int16) error {
+	cDir, err := catalogDir()
+	if err != nil {
+		return err
+	}
+	if _, err := cDir.GetLatestTimeBucketInfoFromKey(tbk); err == nil {
+		return nil
+	}
+	tf, err := tbk.GetTimeFrame()
+	if err != nil {
+		return err
+	}
+	tbi := io.NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(cDir.GetPath()),
+		"Created By binancefeeder", year, shapes, recordType(tbk))
+	return cDir.AddTimeBucket(tbk, tbi)
+}
+
+func (localStore) timeframes(name, attributeGroup string) ([]string, error) {
+	cDir, err := catalogDir()
+	if err != nil {
+		return nil, err
+	}
+	symbolDir := cDir.GetSubDirWithItemName(name)
+	if symbolDir == nil {
+		return nil, nil
+	}
+	timeframes := []string{}
+	for _, tfDir := range symbolDir.GetListOfSubDirs() {
+		if tfDir.GetSubDirWithItemName(attributeGroup) != nil {
+			timeframes = append(timeframes, tfDir.GetName())
+		}
+	}
+	sort.Strings(timeframes)
+	return timeframes, nil
+}
+
+func (localStore) promote(staging, live *io.TimeBucketKey) error {
+	if err := checkWriter(); err != nil {
+		return err
+	}
+	return executor.PromoteBucket(staging, live)
+}
+
+// remoteStore goes through the RPC API of another marketstore server
+type remoteStore struct {
+	client *client.Client
+}
+
+func (r *remoteStore) ready() error {
+	return nil
+}
+
+func (r *remoteStore) write(csm io.ColumnSeriesMap) error {
+	req := &frontend.MultiWriteRequest{}
+	for tbk, cs := range csm {
+		nds, err := io.NewNumpyDataset(cs)
+		if err != nil {
+			return err
+		}
+		nmds, err := io.NewNumpyMultiDataset(nds, tbk)
+		if err != nil {
+			return err
+		}
+		req.Requests = append(req.Requests, frontend.WriteRequest{Data: nmds, IsVariableLength: isVariableLength(&tbk)})
+	}
+	resp, err := r.client.DoRPC("Write", req)
+	if err != nil {
+		return err
+	}
+	if resp, ok := resp.(*frontend.MultiWriteResponse); ok && len(resp.Responses) > 0 {
+		return fmt.Errorf("remote write failed: %s", resp.Responses[0].Error)
+	}
+	return nil
+}
+
+// shapes cannot tell, the RPC API does not describe buckets
+func (r *remoteStore) shapes(tbk *io.TimeBucketKey) ([]io.DataShape, error) {
+	return nil, nil
+}
+
+// create leaves the bucket to the first write, the RPC API cannot create
+// buckets
+func (r *remoteStore) create(tbk *io.TimeBucketKey, shapes []io.DataShape, hints map[string]string, year int16) error {
+	return nil
+}
+
+// timeframes cannot tell, the RPC API only lists the symbols
+func (r *remoteStore) timeframes(name, attributeGroup string) ([]string, error) {
+	return nil, nil
+}
+
+// promote fails, the RPC API cannot rename buckets
+func (r *remoteStore) promote(staging, live *io.TimeBucketKey) error {
+	return fmt.Errorf("cannot promote %s, not supported with remote_endpoint", staging.String())
+}
+
+func (r *remoteStore) read(tbk *io.TimeBucketKey, start, end int64, limit int) (*io.ColumnSeries, error) {
+	return r.query(tbk, start, end, limit, false)
+}
+
+func (r *remoteStore) readFirst(tbk *io.TimeBucketKey, limit int) (*io.ColumnSeries, error) {
+	return r.query(tbk, 0, math.MaxInt64, limit, true)
+}
+
+func (r *remoteStore) query(tbk *io.TimeBucketKey, start, end int64, limit int, fromStart bool) (*io.ColumnSeries, error) {
+	req := frontend.QueryRequest{
+		Destination: tbk.String(),
+		EpochStart:  &start,
+		EpochEnd:    &end,
+	}
+	if limit > 0 {
+		req.LimitRecordCount = &limit
+		req.LimitFromStart = &fromStart
+	}
+	resp, err := r.client.DoRPC("Query", &frontend.MultiQueryRequest{
+		Requests: []frontend.QueryRequest{req},
+	})
+	if err != nil {
+		// the bucket does not exist yet
+		if strings.Contains(err.Error(), "No files returned from query parse") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	csm, ok := resp.(*io.ColumnSeriesMap)
+	if !ok || csm == nil {
+		return nil, nil
+	}
+	return (*csm)[*tbk], nil
+}
+
+// lastStoredTime returns the time of the last row of the bucket, or the zero
+// time if there is none
+func lastStoredTime(s store, tbk *io.TimeBucketKey) (time.Time, error) {
+	cs, err := s.read(tbk, 0, math.MaxInt64, 1)
+	if err != nil || cs == nil || cs.Len() == 0 {
+		return time.Time{}, err
+	}
+	return cs.GetTimeIn(utils.InstanceConfig.Timezone)[0], nil
+}
+
+// firstStoredTime returns the time of the first row of the bucket, or the
+// zero time if there is none
+func firstStoredTime(s store, tbk *io.TimeBucketKey) (time.Time, error) {
+	cs, err := s.readFirst(tbk, 1)
+	if err != nil || cs == nil || cs.Len() == 0 {
+		return time.Time{}, err
+	}
+	return cs.GetTimeIn(utils.InstanceConfig.Timezone)[0], nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/frontend"
+	"github.com/alpacahq/marketstore/plugins/trigger"
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestRemoteEndpoint(c *C) {
+	// a marketstore server storing to the instance of the suite
+	rpcServer, _ := frontend.NewServer()
+	server := httptest.NewServer(rpcServer)
+	defer server.Close()
+
+	worker := s.newWorker(c, newFixtureClient(c, "EOSBNB"), fmt.Sprintf(`{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "REMOTE_{base}",
+        "remote_endpoint": %q
+        }`, server.URL))
+	_, ok := worker.store.(*remoteStore)
+	c.Assert(ok, Equals, true)
+	worker.Run()
+
+	tbk := io.NewTimeBucketKey("REMOTE_EOS/1Min/OHLCV")
+	cs := readBucket(c, "REMOTE_EOS/1Min/OHLCV")
+	c.Assert(cs.Len() > 0, Equals, true)
+	last, err := lastStoredTime(worker.store, tbk)
+	c.Assert(err, IsNil)
+	c.Assert(last.Unix(), Equals, cs.GetEpoch()[cs.Len()-1])
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "remote_endpoint": "storage:5993"}`))
+	c.Assert(err, NotNil)
+}
+
+func (s *RunTestSuite) TestRecordTypes(c *C) {
+	c.Assert(isVariableLength(io.NewTimeBucketKey("BINANCE_BNB_EOS/1Min/OHLCV")), Equals, false)
+	c.Assert(isVariableLength(io.NewTimeBucketKey("BINANCE_BNB_EOS/1Min/Trades")), Equals, true)
+
+	// a bucket keeps the record type it was created with
+	tbk := io.NewTimeBucketKey("RECTYPE_EOS/1Min/TICKS")
+	shapes := []io.DataShape{{Name: "Epoch", Type: io.INT64}, {Name: "Price", Type: io.FLOAT64}}
+	cDir := executor.ThisInstance.CatalogDir
+	tbi := io.NewTimeBucketInfo(*utils.TimeframeFromString("1Min"), tbk.GetPathToYearFiles(cDir.GetPath()),
+		"fixed", 2018, shapes, io.FIXED)
+	c.Assert(cDir.AddTimeBucket(tbk, tbi), IsNil)
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC).Unix()})
+	cs.AddColumn("Price", []float64{1})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	err := localStore{}.write(csm)
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "fixed length"), Equals, true)
+}
+
+// countingTrigger reports each Fire on a channel
+type countingTrigger struct {
+	fired chan []trigger.Record
+}
+
+func (t *countingTrigger) Fire(keyPath string, records []trigger.Record) {
+	t.fired <- records
+}
+
+func (s *RunTestSuite) TestTriggersFireOnWrite(c *C) {
+	t := &countingTrigger{fired: make(chan []trigger.Record, 10)}
+	executor.ThisInstance.TriggerMatchers = []*trigger.TriggerMatcher{
+		trigger.NewMatcher(t, "TRIGGER_TRX/1Min/OHLCV"),
+	}
+	defer func() { executor.ThisInstance.TriggerMatchers = nil }()
+
+	client := newFixtureClient(c, "TRXBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "TRIGGER_{base}"
+        }`)
+	worker.Run()
+
+	// the hour is fetched and written at once
+	var records []trigger.Record
+	select {
+	case records = <-t.fired:
+	case <-time.After(5 * time.Second):
+		c.Fatal("trigger did not fire")
+	}
+	klines := client.klines["TRXBNB"]
+	c.Assert(records, HasLen, len(klines))
+	for i, record := range records {
+		epoch := io.IndexToTime(record.Index(), time.Minute, 2018).Unix()
+		c.Assert(epoch, Equals, klines[i].OpenTime/1000)
+	}
+
+	select {
+	case <-t.fired:
+		c.Fatal("trigger fired twice for one write")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// nothing new is written, so the trigger does not fire again
+	worker.Run()
+	select {
+	case <-t.fired:
+		c.Fatal("trigger fired without a write")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func (s *RunTestSuite) TestExecutorNotInitialized(c *C) {
+	client := newFixtureClient(c, "TRXBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "UNINITIALIZED_{base}"
+        }`)
+	cs, err := ratesToColumnSeries(client.klines["TRXBNB"], false, "open", onErrorSkip)
+	c.Assert(err, IsNil)
+	tbk := io.NewTimeBucketKey("UNINITIALIZED_TRX/1Min/OHLCV")
+
+	instance := executor.ThisInstance
+	executor.ThisInstance = nil
+	defer func() { executor.ThisInstance = instance }()
+
+	_, err = findLastTimestamp("TRX", tbk)
+	c.Assert(err, Equals, errExecutorNotInitialized)
+	_, err = readRange(tbk, 0, 1)
+	c.Assert(err, Equals, errExecutorNotInitialized)
+	c.Assert(worker.write("TRX", cs, false), Equals, errExecutorNotInitialized)
+	// returns instead of panicking
+	worker.Run()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// symbol_source prefixes.  The default source lists the symbols of the venue
+// through exchangeInfo.
+const (
+	symbolSourceAPI    = "api"
+	symbolSourceFile   = "file:"
+	symbolSourceBucket = "bucket:"
+)
+
+// loadSymbols returns the symbols of a file: or bucket: symbol_source,
+// reading the bucket from st
+func loadSymbols(st store, source string) ([]string, error) {
+	var (
+		symbols []string
+		err     error
+	)
+	switch {
+	case strings.HasPrefix(source, symbolSourceFile):
+		symbols, err = readSymbolFile(strings.TrimPrefix(source, symbolSourceFile))
+	case strings.HasPrefix(source, symbolSourceBucket):
+		symbols, err = readSymbolBucket(st, strings.TrimPrefix(source, symbolSourceBucket))
+	default:
+		return nil, fmt.Errorf("invalid symbol_source %q", source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read symbol_source %q: %v", source, err)
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("no symbols in symbol_source %q", source)
+	}
+	return symbols, nil
+}
+
+// readSymbolFile reads one symbol per line, skipping blank lines and the
+// ones starting with #
+func readSymbolFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	symbols := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		symbols = append(symbols, line)
+	}
+	return symbols, scanner.Err()
+}
+
+// readSymbolBucket reads the symbol list of the latest row of a metadata
+// bucket, which has a column per symbol, e.g. SYMBOLS/1D/UNIVERSE with EOS
+// and TRX columns.  The symbols whose column is not zero in that row are
+// listed, in column order.
+func readSymbolBucket(st store, key string) ([]string, error) {
+	if strings.Count(key, "/") != 2 {
+		return nil, fmt.Errorf("invalid bucket key %q", key)
+	}
+	tbk := io.NewTimeBucketKey(key)
+	cs, err := st.read(tbk, planner.MinEpoch, planner.MaxEpoch, 0)
+	if err != nil {
+		return nil, err
+	}
+	if cs == nil || cs.Len() == 0 {
+		return nil, fmt.Errorf("%s has no rows", key)
+	}
+	last := cs.Len() - 1
+	symbols := []string{}
+	for _, name := range cs.GetColumnNames() {
+		if name == "Epoch" || name == "Nanoseconds" {
+			continue
+		}
+		v := reflect.ValueOf(cs.GetByName(name)).Index(last)
+		if v.Interface() != reflect.Zero(v.Type()).Interface() {
+			symbols = append(symbols, name)
+		}
+	}
+	return symbols, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/alpacahq/marketstore/executor"
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSymbolSource(c *C) {
+	path := filepath.Join(c.MkDir(), "symbols.txt")
+	c.Assert(ioutil.WriteFile(path, []byte("# curated\nEOS\n\n  TRX \n"), 0644), IsNil)
+	worker := s.newWorker(c, newFixtureClient(c), fmt.Sprintf(`{"symbol_source": "file:%s"}`, path))
+	c.Assert(worker.symbols, DeepEquals, []string{"EOS", "TRX"})
+
+	// the latest row lists the symbols
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{1533081600, 1533168000})
+	cs.AddColumn("EOS", []float64{1, 1})
+	cs.AddColumn("TRX", []float64{1, 0})
+	cs.AddColumn("VEN", []float64{0, 1})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*io.NewTimeBucketKey("SYMBOLS/1D/UNIVERSE"), cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+	worker = s.newWorker(c, newFixtureClient(c), `{"symbol_source": "bucket:SYMBOLS/1D/UNIVERSE"}`)
+	c.Assert(worker.symbols, DeepEquals, []string{"EOS", "VEN"})
+
+	for _, config := range []string{
+		`{"symbol_source": "bucket:MISSING/1D/UNIVERSE"}`,
+		`{"symbol_source": "bucket:SYMBOLS"}`,
+		`{"symbol_source": "file:testdata/missing.txt"}`,
+		`{"symbol_source": "ftp://symbols"}`,
+		`{"symbols": ["EOS"], "symbol_source": "api"}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf(config))
+	}
+}
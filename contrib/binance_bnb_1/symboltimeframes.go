@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils"
+)
+
+// symbolTimeframes parses symbol_timeframes into the timeframes and Binance
+// intervals of the symbols overriding base, leaving out those of base, and
+// checks that each timeframe has a Binance interval
+func symbolTimeframes(config *FetcherConfig, base *utils.Timeframe) (map[string]*utils.Timeframe, map[string]string, error) {
+	timeframes := map[string]*utils.Timeframe{}
+	intervals := map[string]string{}
+	for symbol, timeframe := range config.SymbolTimeframes {
+		tf, err := parseTimeframe(timeframe)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid symbol_timeframes of %s: %v", symbol, err)
+		}
+		interval, err := toBinanceInterval(tf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid symbol_timeframes of %s: %v", symbol, err)
+		}
+		if tf.String == base.String {
+			continue
+		}
+		timeframes[symbol] = tf
+		intervals[symbol] = interval
+	}
+	return timeframes, intervals, nil
+}
+
+// timeframeOf returns the timeframe symbol is collected in, that of
+// symbol_timeframes or base_timeframe
+func (bn *BinanceFetcher) timeframeOf(symbol string) *utils.Timeframe {
+	if tf, ok := bn.symbolTimeframes[symbol]; ok {
+		return tf
+	}
+	return bn.baseTimeframe
+}
+
+// intervalOf returns the Binance kline interval of the timeframe of symbol
+func (bn *BinanceFetcher) intervalOf(symbol string) string {
+	if interval, ok := bn.symbolIntervals[symbol]; ok {
+		return interval
+	}
+	return bn.interval
+}
+
+// passTimeframe returns the finest timeframe of the symbols, that the passes
+// of Run follow and request_span and advance_step count.  The symbols of
+// coarser timeframes are requested from the open of the candle containing
+// the start of the pass, see symbolWindow.
+func (bn *BinanceFetcher) passTimeframe() *utils.Timeframe {
+	timeframes := bn.timeframes()
+	pass := timeframes[0]
+	for _, tf := range timeframes[1:] {
+		if tf.Duration < pass.Duration {
+			pass = tf
+		}
+	}
+	return pass
+}
+
+// passInterval returns the Binance kline interval of passTimeframe
+func (bn *BinanceFetcher) passInterval() string {
+	pass := bn.passTimeframe()
+	if pass.String == bn.baseTimeframe.String {
+		return bn.interval
+	}
+	interval, _ := toBinanceInterval(pass)
+	return interval
+}
+
+// symbolWindow returns the window of a pass of the pass timeframe from start
+// to end for symbol: its start moves back to the open of the candle of the symbol containing it, so
+// that the candles coarser than the pass are requested whole.  ok is false
+// when no candle of the symbol closes in the live window, for the symbol to
+// be skipped in that pass.
+func (bn *BinanceFetcher) symbolWindow(symbol string, pass *utils.Timeframe, start, end time.Time, live bool) (time.Time, time.Time, bool) {
+	tf := bn.timeframeOf(symbol)
+	if tf.String == pass.String {
+		return start, end, true
+	}
+	start = candleOpen(start, tf)
+	if live {
+		// the candle of the symbol forming at end is trimmed
+		end = candleOpen(end, tf)
+		return start, end, end.After(start)
+	}
+	return start, end, true
+}
+
+// timeframes returns the timeframes the symbols are collected in, in the
+// order of the symbols, or base_timeframe without symbols
+func (bn *BinanceFetcher) timeframes() []*utils.Timeframe {
+	timeframes := []*utils.Timeframe{}
+	seen := map[string]bool{}
+	for _, symbol := range bn.symbols {
+		if tf := bn.timeframeOf(symbol); !seen[tf.String] {
+			seen[tf.String] = true
+			timeframes = append(timeframes, tf)
+		}
+	}
+	if len(timeframes) == 0 {
+		return []*utils.Timeframe{bn.baseTimeframe}
+	}
+	return timeframes
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestSymbolTimeframes(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	client := &fixtureClient{klines: map[string][]*binance.Kline{
+		"EOSBNB": syntheticKlines(start, 61, time.Minute),
+		"TRXBNB": syntheticKlines(start, 13, 5*time.Minute),
+	}}
+	config := `{
+        "symbols": [%s],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "TIMEFRAMES_{base}",
+        "symbol_timeframes": {"TRX": "5Min"}
+        }`
+	ret, err := NewBgWorker(getConfig(fmt.Sprintf(config, `"EOS", "TRX"`)))
+	c.Assert(err, IsNil)
+	worker := ret.(*BinanceFetcher)
+	c.Assert(worker.symbols, DeepEquals, []string{"EOS", "TRX"})
+	c.Assert(worker.binanceInterval(), Equals, "1m")
+	c.Assert(worker.timeframeOf("EOS").String, Equals, "1Min")
+	c.Assert(worker.timeframeOf("TRX").String, Equals, "5Min")
+	c.Assert(worker.intervalOf("TRX"), Equals, "5m")
+	c.Assert(worker.passTimeframe().String, Equals, "1Min")
+	c.Assert(worker.tbkFor("TRX").String(), Equals, "TIMEFRAMES_TRX/5Min/OHLCV")
+	worker.client = client
+	worker.statusRefreshedAt = time.Now().UTC()
+	worker.Run()
+	assertKlines(c, readBucket(c, "TIMEFRAMES_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+	assertKlines(c, readBucket(c, "TIMEFRAMES_TRX/5Min/OHLCV"), client.klines["TRXBNB"])
+	cs, err := readRange(io.NewTimeBucketKey("TIMEFRAMES_TRX/1Min/OHLCV"), planner.MinEpoch, planner.MaxEpoch)
+	c.Assert(err, IsNil)
+	c.Assert(cs, IsNil)
+
+	// with only overridden symbols, the passes follow their timeframe
+	ret, err = NewBgWorker(getConfig(fmt.Sprintf(config, `"TRX"`)))
+	c.Assert(err, IsNil)
+	c.Assert(ret.(*BinanceFetcher).passTimeframe().String, Equals, "5Min")
+	c.Assert(ret.(*BinanceFetcher).passInterval(), Equals, "5m")
+
+	// a live pass skips TRX until its candle closes
+	now := time.Date(2018, time.August, 1, 1, 6, 0, 0, time.UTC)
+	_, _, ok := worker.symbolWindow("TRX", worker.passTimeframe(), now.Add(-time.Minute), now, true)
+	c.Assert(ok, Equals, false)
+	from, to, ok := worker.symbolWindow("TRX", worker.passTimeframe(), now.Add(-3*time.Minute), now, true)
+	c.Assert(ok, Equals, true)
+	c.Assert(from, Equals, now.Add(-6*time.Minute))
+	c.Assert(to, Equals, now.Add(-time.Minute))
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS", "TRX"], "base_timeframe": "1D", "complete_days_only": true, "symbol_timeframes": {"TRX": "1H"}}`))
+	c.Assert(err, NotNil)
+
+	for _, timeframes := range []string{`{"TRX": "7Min"}`, `{"TRX": "1Sec"}`, `{"TRX": "soon"}`} {
+		_, err := NewBgWorker(getConfig(`{"symbols": ["EOS", "TRX"], "symbol_timeframes": ` + timeframes + `}`))
+		c.Assert(err, NotNil, Commentf("%s", timeframes))
+	}
+}
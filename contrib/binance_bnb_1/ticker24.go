@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// ticker24Weight is the request weight of /ticker/24hr for all symbols
+const ticker24Weight = 40
+
+// Ticker24 is the rolling 24 hour statistics of a symbol
+type Ticker24 struct {
+	Symbol             string `json:"symbol"`
+	PriceChangePercent string `json:"priceChangePercent"`
+	WeightedAvgPrice   string `json:"weightedAvgPrice"`
+	Count              int64  `json:"count"`
+}
+
+// ticker24Key is the bucket the 24 hour statistics of symbol are written to,
+// e.g. BINANCE_BNB_EOS/1Min/TICKER24
+func (bn *BinanceFetcher) ticker24Key(symbol string) *io.TimeBucketKey {
+	return io.NewTimeBucketKey(bn.bucketName(symbol) + "/1Min/TICKER24")
+}
+
+// collectTicker24 writes the 24 hour statistics of each symbol to its
+// TICKER24 bucket once every ticker24_interval, with the minute of now as
+// Epoch.  All symbols are requested at once.
+func (bn *BinanceFetcher) collectTicker24(now time.Time) {
+	if bn.ticker24Interval <= 0 || now.Sub(bn.ticker24FetchedAt) < bn.ticker24Interval {
+		return
+	}
+	if err := bn.store.ready(); err != nil {
+		glog.Errorf("Cannot write the 24hr ticker: %v", err)
+		return
+	}
+	bn.limiter.reserve(ticker24Weight)
+	var tickers []Ticker24
+	if err := getJson(bn.venue.jsonHTTPClient(), bn.venue.ticker24URL(), &tickers); err != nil {
+		glog.Errorf("Binance /ticker/24hr API error: %v", err)
+		return
+	}
+	bn.ticker24FetchedAt = now
+
+	bySymbol := map[string]Ticker24{}
+	for _, t := range tickers {
+		bySymbol[t.Symbol] = t
+	}
+	csm := io.NewColumnSeriesMap()
+	for _, symbol := range bn.symbols {
+		t, ok := bySymbol[symbol+bn.baseCurrency]
+		if !ok {
+			continue
+		}
+		change, err := strconv.ParseFloat(t.PriceChangePercent, 64)
+		if err != nil {
+			glog.Errorf("Invalid 24hr ticker of %s: %v", symbol, err)
+			continue
+		}
+		avg, err := strconv.ParseFloat(t.WeightedAvgPrice, 64)
+		if err != nil {
+			glog.Errorf("Invalid 24hr ticker of %s: %v", symbol, err)
+			continue
+		}
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{now.Truncate(time.Minute).Unix()})
+		cs.AddColumn("PriceChangePercent", []float64{change})
+		cs.AddColumn("WeightedAvgPrice", []float64{avg})
+		cs.AddColumn("Count", []int64{t.Count})
+		csm.AddColumnSeries(*bn.ticker24Key(symbol), cs)
+	}
+	if !csm.IsEmpty() {
+		if err := bn.store.write(csm); err != nil {
+			glog.Errorf("Cannot write the 24hr ticker: %v", err)
+		}
+	}
+}
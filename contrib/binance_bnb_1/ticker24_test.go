@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestTicker24(c *C) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		c.Check(r.URL.Path, Equals, "/api/v1/ticker/24hr")
+		w.Write([]byte(`[
+			{"symbol": "EOSBNB", "priceChangePercent": "-2.500", "weightedAvgPrice": "0.42", "count": 1200},
+			{"symbol": "TRXBNB", "priceChangePercent": "1.25", "weightedAvgPrice": "0.0024", "count": 800},
+			{"symbol": "ETHBTC", "priceChangePercent": "0.1", "weightedAvgPrice": "0.06", "count": 99}
+		]`))
+	}))
+	defer server.Close()
+
+	config := `{"symbols": ["EOS", "TRX"], "bucket_name_template": "TICKER_{base}"%s}`
+	_, err := NewBgWorker(getConfig(fmt.Sprintf(config, `, "ticker24_interval": "30s"`)))
+	c.Assert(err, NotNil)
+
+	// off by default
+	worker := s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, ""))
+	worker.venue.baseURL = server.URL
+	worker.collectTicker24(time.Now().UTC())
+	c.Assert(requests, Equals, 0)
+
+	worker = s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, `, "ticker24_interval": "5m"`))
+	worker.venue.baseURL = server.URL
+	now := time.Date(2018, time.August, 1, 0, 0, 30, 0, time.UTC)
+	worker.collectTicker24(now)
+	// not again before the interval
+	worker.collectTicker24(now.Add(time.Minute))
+	c.Assert(requests, Equals, 1)
+
+	eos := readBucket(c, "TICKER_EOS/1Min/TICKER24")
+	c.Assert(eos.GetEpoch(), DeepEquals, []int64{now.Truncate(time.Minute).Unix()})
+	c.Assert(eos.GetByName("PriceChangePercent"), DeepEquals, []float64{-2.5})
+	c.Assert(eos.GetByName("WeightedAvgPrice"), DeepEquals, []float64{0.42})
+	c.Assert(eos.GetByName("Count"), DeepEquals, []int64{1200})
+	trx := readBucket(c, "TICKER_TRX/1Min/TICKER24")
+	c.Assert(trx.GetByName("Count"), DeepEquals, []int64{800})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// defaultRequestTimeout bounds a klines request without request_timeout
+const defaultRequestTimeout = 10 * time.Second
+
+// requestTimeoutKey is the context key of the request timeout
+type requestTimeoutKey struct{}
+
+// withRequestTimeout returns ctx carrying the timeout of the requests made
+// with it, see requestContext
+func withRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, timeout)
+}
+
+// requestContext returns the context a request is sent with, which expires
+// after the timeout ctx carries.  It is derived right before the request, so
+// that the wait for the request weight limiter does not count.
+func requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout, ok := ctx.Value(requestTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestRequestTimeout(c *C) {
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer hung.Close()
+
+	worker := s.newWorker(c, newFixtureClient(c), `{"symbols": ["EOS"], "request_timeout": "50ms"}`)
+	worker.venue.baseURL = hung.URL
+	worker.client = &binanceClient{worker.venue.newClient()}
+	started := time.Now()
+	_, err := worker.klines(context.Background(), "EOS", "1m", 0, 0)
+	c.Assert(err, NotNil)
+	c.Assert(time.Since(started) < 5*time.Second, Equals, true)
+	c.Assert(classifyError(err), Equals, actionRetry)
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "request_timeout": "-1s"}`))
+	c.Assert(err, NotNil)
+}
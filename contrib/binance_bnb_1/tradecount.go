@@ -0,0 +1,15 @@
+package main
+
+// tradeNumColumn is the INT64 column of the number of trades of each candle,
+// written with include_trade_count
+const tradeNumColumn = "TradeNum"
+
+// writtenColumns are the columns of the candles written besides Epoch, those
+// of columns followed by TradeNum with include_trade_count
+func (bn *BinanceFetcher) writtenColumns() []string {
+	columns := append([]string{}, bn.columns...)
+	if bn.includeTradeCount {
+		columns = append(columns, tradeNumColumn)
+	}
+	return columns
+}
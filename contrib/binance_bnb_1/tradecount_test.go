@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestIncludeTradeCount(c *C) {
+	client := newFixtureClient(c, "TRXBNB")
+	for _, columns := range []string{`[]`, `["Close"]`} {
+		worker := s.newWorker(c, client, fmt.Sprintf(`{
+            "symbols": ["TRX"],
+            "query_start": "2018-08-01 00:00",
+            "query_end": "2018-08-01 01:00",
+            "bucket_name_template": "TRADES_{base}",
+            "attribute_group": "G%d",
+            "columns": %s,
+            "include_trade_count": true
+            }`, len(columns), columns))
+		schema := worker.Schema()
+		c.Assert(schema[len(schema)-1], Equals, io.DataShape{Name: "TradeNum", Type: io.INT64})
+		c.Assert(worker.CompressionHints()["TradeNum"], Equals, hintDelta)
+		worker.Run()
+
+		cs := readBucket(c, fmt.Sprintf("TRADES_TRX/1Min/G%d", len(columns)))
+		c.Assert(cs.GetColumnNames(), DeepEquals, append([]string{"Epoch"}, worker.writtenColumns()...))
+		c.Assert(cs.Len(), Equals, len(client.klines["TRXBNB"]))
+		tradeNum := cs.GetByName("TradeNum").([]int64)
+		for i, rate := range client.klines["TRXBNB"] {
+			c.Assert(tradeNum[i], Equals, rate.TradeNum)
+		}
+	}
+
+	// the forming candle is trimmed from the trade counts as well
+	cs, _, err := buildColumnSeries(client.klines["TRXBNB"][:3], true, "open", onErrorSkip, nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(cs.GetByName("TradeNum"), DeepEquals, []int64{client.klines["TRXBNB"][0].TradeNum, client.klines["TRXBNB"][1].TradeNum})
+
+	// a bucket without TradeNum has another schema
+	_, err = NewBgWorker(getConfig(`{"symbols": ["TRX"], "bucket_name_template": "TRADES_{base}", "attribute_group": "G2"}`))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// Transform enriches the candles of symbol before they are written, e.g.
+// adding columns computed from the others, and returns the series to write
+type Transform func(symbol string, cs *io.ColumnSeries) (*io.ColumnSeries, error)
+
+// transform is a Transform of the registry with the columns it reads, which
+// must be written, and the float64 columns it adds to the buckets
+type transform struct {
+	fn    Transform
+	reads []string
+	adds  []string
+}
+
+// transforms are the Transforms that the transforms option names
+var transforms = map[string]transform{
+	"vwap": {
+		fn:    vwapTransform,
+		reads: []string{"High", "Low", "Close"},
+		adds:  []string{"VWAP"},
+	},
+	"log_return": {
+		fn:    logReturnTransform,
+		reads: []string{"Open", "Close"},
+		adds:  []string{"LogReturn"},
+	},
+}
+
+// vwapTransform adds the VWAP of each candle, estimated by its typical price
+// (High+Low+Close)/3 as the candles do not hold the traded quote volume
+func vwapTransform(symbol string, cs *io.ColumnSeries) (*io.ColumnSeries, error) {
+	high := cs.GetByName("High").([]float64)
+	low := cs.GetByName("Low").([]float64)
+	close := cs.GetByName("Close").([]float64)
+	vwap := make([]float64, len(close))
+	for i := range close {
+		vwap[i] = (high[i] + low[i] + close[i]) / 3
+	}
+	cs.AddColumn("VWAP", vwap)
+	return cs, nil
+}
+
+// logReturnTransform adds the log return ln(Close/Open) of each candle, which
+// does not depend on the candles written before it.  It is 0 for a candle
+// without an Open.
+func logReturnTransform(symbol string, cs *io.ColumnSeries) (*io.ColumnSeries, error) {
+	open := cs.GetByName("Open").([]float64)
+	close := cs.GetByName("Close").([]float64)
+	logReturn := make([]float64, len(close))
+	for i := range close {
+		if open[i] > 0 && close[i] > 0 {
+			logReturn[i] = math.Log(close[i] / open[i])
+		}
+	}
+	cs.AddColumn("LogReturn", logReturn)
+	return cs, nil
+}
+
+// selectTransforms returns the transforms named, in their order, and the
+// columns they add, checking that the columns they read are written
+func selectTransforms(names, columns []string) ([]Transform, []string, error) {
+	written := map[string]bool{}
+	for _, c := range columns {
+		written[c] = true
+	}
+	fns, adds := []Transform{}, []string{}
+	for _, name := range names {
+		t, ok := transforms[name]
+		if !ok {
+			known := []string{}
+			for n := range transforms {
+				known = append(known, n)
+			}
+			sort.Strings(known)
+			return nil, nil, fmt.Errorf("unknown transform %q, must be one of %s", name, strings.Join(known, ", "))
+		}
+		for _, c := range t.reads {
+			if !written[c] {
+				return nil, nil, fmt.Errorf("transform %s reads the %s column, which is not written", name, c)
+			}
+		}
+		for _, c := range t.adds {
+			if written[c] {
+				return nil, nil, fmt.Errorf("transform %s adds the %s column, which is already written", name, c)
+			}
+			written[c] = true
+		}
+		fns = append(fns, t.fn)
+		adds = append(adds, t.adds...)
+	}
+	return fns, adds, nil
+}
+
+// applyTransforms runs the transforms of the worker on the candles of symbol
+// in order, see Transform
+func (bn *BinanceFetcher) applyTransforms(symbol string, cs *io.ColumnSeries) (*io.ColumnSeries, error) {
+	for _, fn := range bn.transforms {
+		var err error
+		if cs, err = fn(symbol, cs); err != nil {
+			return nil, fmt.Errorf("cannot transform the candles of %s: %v", symbol, err)
+		}
+	}
+	return cs, nil
+}
+
+// storedColumns are the columns of the buckets besides Epoch, those written
+// and those added by the transforms
+func (bn *BinanceFetcher) storedColumns() []string {
+	return append(bn.writtenColumns(), bn.transformColumns...)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestTransforms(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	client := &fixtureClient{klines: map[string][]*binance.Kline{
+		"EOSBNB": syntheticKlines(start, 61, time.Minute),
+	}}
+	worker := s.newWorker(c, client, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "TRANSFORMS_{base}",
+        "transforms": ["vwap", "log_return"]
+        }`)
+	c.Assert(worker.storedColumns(), DeepEquals, []string{"Open", "High", "Low", "Close", "Volume", "VWAP", "LogReturn"})
+	worker.Run()
+
+	cs := readBucket(c, "TRANSFORMS_EOS/1Min/OHLCV")
+	c.Assert(cs.GetColumnNames(), DeepEquals, []string{"Epoch", "Open", "High", "Low", "Close", "Volume", "VWAP", "LogReturn"})
+	c.Assert(cs.Len(), Equals, 61)
+	for i := 0; i < cs.Len(); i++ {
+		c.Assert(cs.GetByName("VWAP").([]float64)[i], Equals, (2+0.5+1.5)/3.0)
+		c.Assert(cs.GetByName("LogReturn").([]float64)[i], Equals, math.Log(1.5))
+	}
+
+	for _, config := range []string{
+		`{"symbols": ["EOS"], "transforms": ["sessions"]}`,
+		// vwap reads High and Low
+		`{"symbols": ["EOS"], "transforms": ["vwap"], "columns": ["Close"], "attribute_group": "CLOSE"}`,
+		`{"symbols": ["EOS"], "transforms": ["vwap", "vwap"]}`,
+	} {
+		_, err := NewBgWorker(getConfig(config))
+		c.Assert(err, NotNil, Commentf("%s", config))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "github.com/alpacahq/marketstore/utils/io"
+
+// writtenRow is the Epoch and the values of the written columns of a row
+type writtenRow struct {
+	epoch  int64
+	values []float64
+}
+
+// newestRow returns the last row of cs
+func newestRow(cs *io.ColumnSeries, columns []string) writtenRow {
+	i := cs.Len() - 1
+	row := writtenRow{epoch: cs.GetEpoch()[i]}
+	for _, name := range columns {
+		row.values = append(row.values, cs.GetByName(name).([]float64)[i])
+	}
+	return row
+}
+
+// unchangedWrite returns whether the newest candle of cs is the last one
+// written for symbol with the same values, in which case the write is
+// skipped with write_only_on_change, without reading the bucket.  Polling
+// coarse timeframes fetches the same last candle until the next one closes.
+func (bn *BinanceFetcher) unchangedWrite(symbol string, cs *io.ColumnSeries) bool {
+	if !bn.writeOnlyOnChange || cs.Len() == 0 {
+		return false
+	}
+	bn.mu.Lock()
+	last, ok := bn.lastRows[symbol]
+	bn.mu.Unlock()
+	if !ok {
+		return false
+	}
+	row := newestRow(cs, bn.columns)
+	if row.epoch != last.epoch {
+		return false
+	}
+	for i, v := range row.values {
+		if v != last.values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// recordLastRow keeps the newest row written of symbol for unchangedWrite and
+// writeSnapshot
+func (bn *BinanceFetcher) recordLastRow(symbol string, cs *io.ColumnSeries) {
+	if !bn.writeOnlyOnChange && !bn.snapshot {
+		return
+	}
+	row := newestRow(cs, bn.columns)
+	bn.mu.Lock()
+	if last, ok := bn.lastRows[symbol]; !ok || row.epoch >= last.epoch {
+		bn.lastRows[symbol] = row
+	}
+	bn.mu.Unlock()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+// countingStore counts the reads and writes
+type countingStore struct {
+	store
+	reads, writes int
+}
+
+func (s *countingStore) read(tbk *io.TimeBucketKey, start, end int64, limit int) (*io.ColumnSeries, error) {
+	s.reads++
+	return s.store.read(tbk, start, end, limit)
+}
+
+func (s *countingStore) write(csm io.ColumnSeriesMap) error {
+	s.writes++
+	return s.store.write(csm)
+}
+
+func (s *RunTestSuite) TestWriteOnlyOnChange(c *C) {
+	start := time.Date(2018, time.August, 1, 0, 0, 0, 0, time.UTC)
+	klines := syntheticKlines(start, 3, time.Minute)
+	convert := func(klines []*binance.Kline) *io.ColumnSeries {
+		cs, err := ratesToColumnSeries(klines, false, "open", onErrorSkip)
+		c.Assert(err, IsNil)
+		return cs
+	}
+	config := `{"symbols": ["EOS"], "bucket_name_template": "%s_{base}", "allow_updates": true%s}`
+
+	worker := s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, "ONCHANGE", `, "write_only_on_change": true`))
+	st := &countingStore{store: worker.store}
+	worker.store = st
+	c.Assert(worker.write("EOS", convert(klines), false), IsNil)
+	c.Assert(st.writes, Equals, 1)
+	// polling returns the last candle again
+	c.Assert(worker.write("EOS", convert(klines[2:]), false), IsNil)
+	c.Assert(st.reads, Equals, 0)
+	c.Assert(st.writes, Equals, 1)
+	changed := *klines[2]
+	changed.Close = "1.75"
+	c.Assert(worker.write("EOS", convert([]*binance.Kline{&changed}), false), IsNil)
+	c.Assert(st.writes, Equals, 2)
+	c.Assert(readBucket(c, "ONCHANGE_EOS/1Min/OHLCV").GetByName("Close").([]float64)[2], Equals, 1.75)
+
+	// otherwise the stored candle is read to compare it
+	worker = s.newWorker(c, newFixtureClient(c), fmt.Sprintf(config, "ALWAYS", ""))
+	st = &countingStore{store: worker.store}
+	worker.store = st
+	c.Assert(worker.write("EOS", convert(klines), false), IsNil)
+	c.Assert(worker.write("EOS", convert(klines[2:]), false), IsNil)
+	c.Assert(st.reads, Equals, 1)
+	c.Assert(st.writes, Equals, 1)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// readRange returns the rows stored in the bucket between start and end
+// epochs inclusive, or nil if there are none
+func readRange(tbk *io.TimeBucketKey, start, end int64) (*io.ColumnSeries, error) {
+	return localStore{}.read(tbk, start, end, 0)
+}
+
+// filterWritten picks the rows of cs to write.  By default every row is
+// kept, and WriteCSM overwrites the stored row at the same epoch in place,
+// so that re-fetched corrections are stored.  With allow_updates, rows at or
+// before the last written epoch of the symbol are kept only if the stored
+// row differs, which is logged, or is missing.  With append_only or the
+// dedup reload policy they are dropped, logging the corrections seen, unless
+// fillGaps finds them missing from the bucket.  The overwrite reload policy
+// keeps every row.
+func (bn *BinanceFetcher) filterWritten(symbol string, tbk *io.TimeBucketKey, cs *io.ColumnSeries, fillGaps bool) (*io.ColumnSeries, error) {
+	bn.mu.Lock()
+	last, ok := bn.lastWritten[symbol]
+	bn.mu.Unlock()
+	epoch := cs.GetEpoch()
+	dropping := bn.appendOnly || bn.reload == reloadDedup
+	if !ok || bn.reload == reloadOverwrite || len(epoch) == 0 || epoch[0] > last || (!dropping && !bn.allowUpdates) {
+		return cs, nil
+	}
+
+	var changed map[int64][]string
+	var missing map[int64]bool
+	if bn.allowUpdates || fillGaps {
+		stored, err := bn.store.read(tbk, epoch[0], last, 0)
+		if err != nil {
+			return nil, err
+		}
+		if stored == nil {
+			stored = io.NewColumnSeries()
+			stored.AddColumn("Epoch", []int64{})
+		}
+		changed, missing = diffRows(stored, cs, bn.columns)
+	}
+	for _, e := range epoch {
+		diff, ok := changed[e]
+		switch {
+		case !ok:
+		case bn.allowUpdates:
+			glog.Infof("Updating %s at %d: %s", symbol, e, strings.Join(diff, ", "))
+		default:
+			glog.Warningf("Dropping the correction of %s at %d: %s", symbol, e, strings.Join(diff, ", "))
+		}
+	}
+
+	return cs.ApplyTimeQual(func(e int64) bool {
+		_, isChanged := changed[e]
+		return e > last || missing[e] || (bn.allowUpdates && isChanged)
+	}), nil
+}
+
+// diffRows returns the differences of the candles of cs from the stored ones by
+// epoch, along with the epochs of those missing from stored.
+func diffRows(stored, cs *io.ColumnSeries, columns []string) (changed map[int64][]string, missing map[int64]bool) {
+	rows := map[int64]int{}
+	for i, e := range stored.GetEpoch() {
+		rows[e] = i
+	}
+
+	changed, missing = map[int64][]string{}, map[int64]bool{}
+	for i, e := range cs.GetEpoch() {
+		j, ok := rows[e]
+		if !ok {
+			missing[e] = true
+			continue
+		}
+		for _, name := range columns {
+			before := stored.GetByName(name).([]float64)[j]
+			after := cs.GetByName(name).([]float64)[i]
+			if before != after {
+				changed[e] = append(changed[e], fmt.Sprintf("%s %v -> %v", name, before, after))
+			}
+		}
+	}
+	return changed, missing
+}
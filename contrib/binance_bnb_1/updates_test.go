@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	binance "github.com/adshao/go-binance"
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestAllowUpdates(c *C) {
+	config := `{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "%s_{base}"%s
+        }`
+	for _, t := range []struct {
+		name, option string
+		updated      bool
+	}{
+		// corrections are written over the stored candles by default
+		{"OVERWRITE", "", true},
+		{"UPDATES", `, "allow_updates": true`, true},
+		{"APPENDONLY", `, "append_only": true`, false},
+	} {
+		client := newFixtureClient(c, "TRXBNB")
+		s.newWorker(c, client, fmt.Sprintf(config, t.name, t.option)).Run()
+		last := len(client.klines["TRXBNB"]) - 1
+		original := *client.klines["TRXBNB"][last]
+
+		// the exchange corrects the last candle written, which the next
+		// run fetches again
+		corrected := original
+		corrected.Close = "0.00245700"
+		client.klines["TRXBNB"][last] = &corrected
+
+		s.newWorker(c, client, fmt.Sprintf(config, t.name, t.option)).Run()
+		expected := append([]*binance.Kline{}, client.klines["TRXBNB"]...)
+		if !t.updated {
+			expected[last] = &original
+		}
+		assertKlines(c, readBucket(c, t.name+"_TRX/1Min/OHLCV"), expected)
+	}
+
+	_, err := NewBgWorker(getConfig(`{"symbols": ["TRX"], "allow_updates": true, "append_only": true}`))
+	c.Assert(err, NotNil)
+}
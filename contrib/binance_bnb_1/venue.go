@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+
+	binance "github.com/adshao/go-binance"
+)
+
+// goBinanceKlinesPath is the klines endpoint go-binance requests
+const goBinanceKlinesPath = "/api/v1/klines"
+
+const defaultVenue = "binance"
+
+// venue describes a Binance-compatible exchange
+type venue struct {
+	baseURL          string
+	exchangeInfoPath string
+	klinesPath       string
+	ticker24Path     string
+	bookTickerPath   string
+	// systemStatusPath is empty if the venue reports no system status
+	systemStatusPath string
+	// premiumIndexPath is empty if the venue has no perpetual futures
+	premiumIndexPath string
+	// bucketPrefix fills the {exchange} placeholder of the bucket name template
+	bucketPrefix string
+	// httpClient carries the requests to the venue, nil for the default ones
+	httpClient *http.Client
+	// limiter is reported the weight used according to the responses
+	limiter *weightLimiter
+}
+
+var venues = map[string]venue{
+	"binance": {
+		baseURL:          "https://api.binance.com",
+		exchangeInfoPath: "/api/v1/exchangeInfo",
+		klinesPath:       "/api/v1/klines",
+		ticker24Path:     "/api/v1/ticker/24hr",
+		bookTickerPath:   "/api/v3/ticker/bookTicker",
+		systemStatusPath: "/sapi/v1/system/status",
+		bucketPrefix:     "BINANCE",
+	},
+	"binanceus": {
+		baseURL:          "https://api.binance.us",
+		exchangeInfoPath: "/api/v3/exchangeInfo",
+		klinesPath:       "/api/v3/klines",
+		ticker24Path:     "/api/v3/ticker/24hr",
+		bookTickerPath:   "/api/v3/ticker/bookTicker",
+		systemStatusPath: "/sapi/v1/system/status",
+		bucketPrefix:     "BINANCEUS",
+	},
+	"binancefutures": {
+		baseURL:          "https://fapi.binance.com",
+		exchangeInfoPath: "/fapi/v1/exchangeInfo",
+		klinesPath:       "/fapi/v1/klines",
+		ticker24Path:     "/fapi/v1/ticker/24hr",
+		bookTickerPath:   "/fapi/v1/ticker/bookTicker",
+		premiumIndexPath: "/fapi/v1/premiumIndex",
+		bucketPrefix:     "BINANCEFUTURES",
+	},
+}
+
+func (v venue) exchangeInfoURL() string {
+	return v.baseURL + v.exchangeInfoPath
+}
+
+func (v venue) ticker24URL() string {
+	return v.baseURL + v.ticker24Path
+}
+
+func (v venue) bookTickerURL() string {
+	return v.baseURL + v.bookTickerPath
+}
+
+func (v venue) systemStatusURL() string {
+	return v.baseURL + v.systemStatusPath
+}
+
+func (v venue) premiumIndexURL() string {
+	return v.baseURL + v.premiumIndexPath
+}
+
+// transport returns the round tripper of the requests to the venue, which
+// reports the weight used to the limiter
+func (v venue) transport() http.RoundTripper {
+	base := http.DefaultTransport
+	if v.httpClient != nil && v.httpClient.Transport != nil {
+		base = v.httpClient.Transport
+	}
+	if v.limiter == nil {
+		return base
+	}
+	return &usedWeightTransport{base: base, limiter: v.limiter}
+}
+
+// jsonHTTPClient returns the client of the getJson requests to the venue
+func (v venue) jsonHTTPClient() *http.Client {
+	if v.httpClient == nil && v.limiter == nil {
+		return jsonClient
+	}
+	return &http.Client{Transport: v.transport(), Timeout: jsonClient.Timeout}
+}
+
+// newClient returns a go-binance client talking to the venue
+func (v venue) newClient() *binance.Client {
+	client := binance.NewClient("", "")
+	client.BaseURL = v.baseURL
+	transport := v.transport()
+	if v.klinesPath != goBinanceKlinesPath {
+		transport = &venueTransport{venue: v, base: transport}
+	}
+	client.HTTPClient = &http.Client{Transport: transport}
+	if v.httpClient != nil {
+		client.HTTPClient.Timeout = v.httpClient.Timeout
+	}
+	return client
+}
+
+// venueTransport rewrites the endpoints go-binance hard-codes
+// to the ones of the venue
+type venueTransport struct {
+	venue venue
+	base  http.RoundTripper
+}
+
+func (t *venueTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != goBinanceKlinesPath {
+		return t.base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request it was given
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	u.Path = t.venue.klinesPath
+	r.URL = &u
+	return t.base.RoundTrip(r)
+}
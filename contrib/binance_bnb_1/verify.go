@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/golang/glog"
+)
+
+const (
+	// defaultVerifySamples is the number of stored candles of each symbol
+	// re-fetched by verify
+	defaultVerifySamples = 20
+	// defaultVerifyTolerance is the relative difference above which a stored
+	// value does not match the API
+	defaultVerifyTolerance = 1e-9
+)
+
+// verifyResult summarizes a verify run
+type verifyResult struct {
+	checked int
+	// mismatched are the sampled candles that differ from the API
+	mismatched int
+	// unavailable are the sampled candles the API did not return
+	unavailable int
+	errors      int
+}
+
+// verify re-fetches a random sample of the stored candles of each symbol and
+// compares them with the bucket, logging each mismatch and a summary.  It
+// reads whole buckets, so it is kept out of the live loop.
+func (bn *BinanceFetcher) verify(rng *rand.Rand) verifyResult {
+	var res verifyResult
+	for _, symbol := range bn.symbols {
+		r := bn.verifySymbol(symbol, rng)
+		res.checked += r.checked
+		res.mismatched += r.mismatched
+		res.unavailable += r.unavailable
+		res.errors += r.errors
+	}
+	glog.Infof("Verified %d stored candles of %d symbols: %d mismatched, %d not returned by the API, %d errors",
+		res.checked, len(bn.symbols), res.mismatched, res.unavailable, res.errors)
+	return res
+}
+
+// verifySymbol re-fetches a random sample of the stored candles of symbol and
+// compares them with its bucket, logging each mismatch
+func (bn *BinanceFetcher) verifySymbol(symbol string, rng *rand.Rand) verifyResult {
+	var res verifyResult
+	interval := bn.intervalOf(symbol)
+	tbk := bn.tbkFor(symbol)
+	stored, err := bn.store.read(tbk, planner.MinEpoch, planner.MaxEpoch, 0)
+	if err != nil {
+		glog.Errorf("Cannot read %s to verify it: %v", tbk.String(), err)
+		res.errors++
+		return res
+	}
+	if stored == nil || stored.Len() == 0 {
+		return res
+	}
+	epoch := stored.GetEpoch()
+	rows := rng.Perm(len(epoch))
+	if len(rows) > bn.verifySamples {
+		rows = rows[:bn.verifySamples]
+	}
+	for _, i := range rows {
+		openTime := epoch[i]
+		if bn.epochSource == "close" {
+			openTime -= int64(bn.timeframeOf(symbol).Duration.Seconds())
+		}
+		ms := openTime * 1000
+		rates, err := bn.klines(context.Background(), symbol, interval, ms, ms)
+		if err != nil {
+			glog.Errorf("Cannot verify %s at %d: %v", symbol, epoch[i], err)
+			res.errors++
+			continue
+		}
+		// the rejected candles are not written, nor compared
+		cs, _, err := buildColumnSeries(rates, false, bn.epochSource, bn.onError, &bn.sanity, false)
+		if err != nil {
+			glog.Errorf("Cannot verify %s at %d: %v", symbol, epoch[i], err)
+			res.errors++
+			continue
+		}
+		res.checked++
+		j := -1
+		if cs != nil {
+			for k, e := range cs.GetEpoch() {
+				if e == epoch[i] {
+					j = k
+				}
+			}
+		}
+		if j < 0 {
+			glog.Warningf("Stored candle of %s at %d is not returned by the API", symbol, epoch[i])
+			res.unavailable++
+			continue
+		}
+		for _, name := range bn.columns {
+			want := cs.GetByName(name).([]float64)[j]
+			got := stored.GetByName(name).([]float64)[i]
+			if math.Abs(got-want) > bn.verifyTolerance*math.Max(math.Abs(got), math.Abs(want)) {
+				glog.Warningf("Stored candle of %s at %d does not match the API: %s %v, expected %v",
+					symbol, epoch[i], name, got, want)
+				res.mismatched++
+				break
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"math/rand"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RunTestSuite) TestVerify(c *C) {
+	client := newFixtureClient(c, "TRXBNB")
+	worker := s.newWorker(c, client, `{
+        "symbols": ["TRX"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 01:00",
+        "bucket_name_template": "VERIFY_{base}",
+        "verify": true,
+        "verify_samples": 1000
+        }`)
+	c.Assert(worker.verifyEnabled, Equals, true)
+	worker.Run()
+	stored := readBucket(c, "VERIFY_TRX/1Min/OHLCV").Len()
+
+	res := worker.verify(rand.New(rand.NewSource(1)))
+	c.Assert(res, Equals, verifyResult{checked: stored})
+
+	// a stored candle differs from the API and another one is gone from it
+	corrected := *client.klines["TRXBNB"][10]
+	corrected.Close = "0.00245700"
+	client.klines["TRXBNB"][10] = &corrected
+	client.klines["TRXBNB"] = append(client.klines["TRXBNB"][:20], client.klines["TRXBNB"][21:]...)
+
+	res = worker.verify(rand.New(rand.NewSource(1)))
+	c.Assert(res, Equals, verifyResult{checked: stored, mismatched: 1, unavailable: 1})
+
+	// a sample checks only some of the candles
+	worker.verifySamples = 5
+	res = worker.verify(rand.New(rand.NewSource(1)))
+	c.Assert(res.checked, Equals, 5)
+}
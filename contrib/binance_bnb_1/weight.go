@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	binance "github.com/adshao/go-binance"
+	"github.com/golang/glog"
+)
+
+const (
+	// defaultWeightLimit and defaultWeightWindow are the REQUEST_WEIGHT
+	// limit used until exchangeInfo is read
+	defaultWeightLimit  = 1200
+	defaultWeightWindow = time.Minute
+	// defaultKlinesLimit is the number of klines returned when the request
+	// does not set a limit, as the fetcher's do not
+	defaultKlinesLimit = 500
+	// defaultWeightWarnFraction is the fraction of the limit used according
+	// to the venue above which a warning is logged
+	defaultWeightWarnFraction = 0.8
+)
+
+// usedWeightHeaders carry the request weight used in the current window, on
+// every response of the venue.  The first one present is read.
+var usedWeightHeaders = []string{"X-MBX-USED-WEIGHT-1M", "X-MBX-USED-WEIGHT"}
+
+// klinesWeight is the request weight of a klines request returning up to
+// limit candles
+func klinesWeight(limit int) int {
+	switch {
+	case limit < 100:
+		return 1
+	case limit < 500:
+		return 2
+	case limit <= 1000:
+		return 5
+	default:
+		return 10
+	}
+}
+
+// requestWeightLimit returns the REQUEST_WEIGHT limit and its window from
+// exchangeInfo
+func requestWeightLimit(info *ExchangeInfo) (limit int, window time.Duration, ok bool) {
+	intervals := map[string]time.Duration{
+		"SECOND": time.Second,
+		"MINUTE": time.Minute,
+		"HOUR":   time.Hour,
+		"DAY":    24 * time.Hour,
+	}
+	for _, rl := range info.RateLimits {
+		interval, known := intervals[rl.Interval]
+		if rl.RateLimitType != "REQUEST_WEIGHT" || !known || rl.Limit <= 0 {
+			continue
+		}
+		if rl.IntervalNum > 1 {
+			interval *= time.Duration(rl.IntervalNum)
+		}
+		return rl.Limit, interval, true
+	}
+	return 0, 0, false
+}
+
+// weightLimiter keeps the request weight spent in each window under the
+// exchange limit, waiting for the next window once it would be exceeded.
+// Windows are aligned to the clock like Binance's.
+type weightLimiter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	used        int
+	now         func() time.Time
+	sleep       func(time.Duration)
+	// observe is called with the used and remaining weight after each reservation
+	observe func(used, remaining int)
+	// warnFraction of the limit used according to the venue logs a warning
+	// once, until the usage falls below it again
+	warnFraction float64
+	warned       bool
+	// observeUsed is called with the weight used according to the venue
+	observeUsed func(used int)
+	// venueUsed is the last weight used reported by the venue
+	venueUsed int
+	// waiting counts the reservations waiting for the next window
+	waiting int
+}
+
+// limiterState is a snapshot of a weightLimiter
+type limiterState struct {
+	// Limit is the weight allowed per Window, the burst the limiter lets
+	// through at once
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+	// RatePerSecond is the weight it sustains over time
+	RatePerSecond float64 `json:"rate_per_second"`
+	Used          int     `json:"used"`
+	Available     int     `json:"available"`
+	// VenueUsed is the weight used in the window according to the venue,
+	// counting the other clients of the IP
+	VenueUsed int `json:"venue_used"`
+	// Waiting is the number of requests waiting for the next window
+	Waiting int `json:"waiting"`
+}
+
+func newWeightLimiter(limit int, window time.Duration) *weightLimiter {
+	return &weightLimiter{
+		limit:   limit,
+		window:  window,
+		now:     time.Now,
+		sleep:   time.Sleep,
+		observe: func(used, remaining int) {},
+
+		warnFraction: defaultWeightWarnFraction,
+		observeUsed:  func(used int) {},
+	}
+}
+
+// setLimit updates the limit, e.g. from exchangeInfo
+func (l *weightLimiter) setLimit(limit int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit != l.limit || window != l.window {
+		glog.Infof("Request weight limit is %d per %v", limit, window)
+	}
+	l.limit = limit
+	l.window = window
+}
+
+// setWarnFraction updates the fraction of the limit used according to the
+// venue above which a warning is logged
+func (l *weightLimiter) setWarnFraction(f float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnFraction = f
+}
+
+// addObservers chains observe and observeUsed after the ones already set,
+// for each of the workers sharing the limiter to publish its metrics
+func (l *weightLimiter) addObservers(observe func(used, remaining int), observeUsed func(used int)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prevObserve, prevObserveUsed := l.observe, l.observeUsed
+	l.observe = func(used, remaining int) {
+		prevObserve(used, remaining)
+		observe(used, remaining)
+	}
+	l.observeUsed = func(used int) {
+		prevObserveUsed(used)
+		observeUsed(used)
+	}
+}
+
+// reserve blocks until weight fits in the current window and spends it
+func (l *weightLimiter) reserve(weight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		now := l.now()
+		if start := now.Truncate(l.window); start.After(l.windowStart) {
+			l.windowStart = start
+			l.used = 0
+		}
+		// a single request heavier than the limit still goes through alone
+		if l.used+weight <= l.limit || l.used == 0 {
+			l.used += weight
+			l.observe(l.used, l.limit-l.used)
+			return
+		}
+		wait := l.windowStart.Add(l.window).Sub(now)
+		glog.Infof("Request weight %d of %d used, waiting %v for the next window", l.used, l.limit, wait)
+		// the responses of the requests in flight report their weight
+		l.waiting++
+		l.mu.Unlock()
+		l.sleep(wait)
+		l.mu.Lock()
+		l.waiting--
+	}
+}
+
+// state returns the configuration and the weight available in the current
+// window
+func (l *weightLimiter) state() limiterState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	used := l.used
+	// the window is over but no reservation started the next one yet
+	if l.now().Truncate(l.window).After(l.windowStart) {
+		used = 0
+	}
+	available := l.limit - used
+	if available < 0 {
+		available = 0
+	}
+	return limiterState{
+		Limit:         l.limit,
+		Window:        l.window.String(),
+		RatePerSecond: float64(l.limit) / l.window.Seconds(),
+		Used:          used,
+		Available:     available,
+		VenueUsed:     l.venueUsed,
+		Waiting:       l.waiting,
+	}
+}
+
+// reportUsed records the weight used in the current window according to the
+// venue, which also counts the requests of other clients sharing the IP
+func (l *weightLimiter) reportUsed(used int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.observeUsed(used)
+	l.venueUsed = used
+	over := float64(used) >= l.warnFraction*float64(l.limit)
+	if over && !l.warned {
+		glog.Warningf("Request weight %d of %d used according to the venue", used, l.limit)
+	}
+	l.warned = over
+}
+
+// usedWeightTransport reports the used weight headers of the responses to
+// the limiter
+type usedWeightTransport struct {
+	base    http.RoundTripper
+	limiter *weightLimiter
+}
+
+func (t *usedWeightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	for _, header := range usedWeightHeaders {
+		if used, err := strconv.Atoi(resp.Header.Get(header)); err == nil {
+			t.limiter.reportUsed(used)
+			break
+		}
+	}
+	return resp, nil
+}
+
+// weightedClient reserves the request weight of each klines request
+type weightedClient struct {
+	klinesClient
+	limiter *weightLimiter
+}
+
+func (c *weightedClient) Klines(ctx context.Context, symbol, interval string, startTime, endTime int64) ([]*binance.Kline, error) {
+	c.limiter.reserve(klinesWeight(defaultKlinesLimit))
+	return c.klinesClient.Klines(ctx, symbol, interval, startTime, endTime)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/golang/glog"
+)
+
+// defaultWriteRetries is the number of times a failed write is retried
+// before on_write_error applies
+const defaultWriteRetries = 3
+
+// storeWithRetries writes csm, retrying a failed write after the delays of
+// retryDelay, as the executor fails writes transiently e.g. while the WAL
+// rotates.  It returns the last error if the retries are exhausted or the
+// server shuts down meanwhile.
+func (bn *BinanceFetcher) storeWithRetries(symbol string, csm io.ColumnSeriesMap) error {
+	for attempt := 1; ; attempt++ {
+		err := bn.store.write(csm)
+		if err == nil || attempt > bn.writeRetries {
+			return err
+		}
+		d := retryDelay(attempt)
+		glog.Warningf("Write of %s failed, retrying in %v: %v", symbol, d, err)
+		if !bn.sleep(d) {
+			return err
+		}
+	}
+}
+
+// writeFailed logs the failed write of symbol and returns whether the
+// worker carries on, which the on_write_error policy decides.  The rows are
+// not marked as written, so that they are fetched again.
+func (bn *BinanceFetcher) writeFailed(symbol string, err error) bool {
+	if bn.onWriteError == onErrorAbort {
+		glog.Errorf("Stopping on the write error for %s: %v", symbol, err)
+		return false
+	}
+	glog.Errorf("Dropped a batch of %s after %d write retries, it is fetched again: %v", symbol, bn.writeRetries, err)
+	return true
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alpacahq/marketstore/utils/io"
+	. "gopkg.in/check.v1"
+)
+
+// failingStore fails the first failures writes
+type failingStore struct {
+	store
+	failures int
+	writes   int
+}
+
+func (f *failingStore) write(csm io.ColumnSeriesMap) error {
+	f.writes++
+	if f.writes <= f.failures {
+		return errors.New("disk full")
+	}
+	return f.store.write(csm)
+}
+
+func (s *RunTestSuite) TestWriteRetries(c *C) {
+	client := newFixtureClient(c, "EOSBNB")
+	clk := &fakeClock{now: time.Date(2018, time.August, 2, 0, 0, 0, 0, time.UTC)}
+	run := func(failures int, config string) *failingStore {
+		worker := s.newWorker(c, client, config)
+		worker.clock = clk
+		fs := &failingStore{store: worker.store, failures: failures}
+		worker.store = fs
+		worker.Run()
+		return fs
+	}
+
+	fs := run(1, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "WRETRY_{base}"
+        }`)
+	c.Assert(fs.writes, Equals, 2)
+	assertKlines(c, readBucket(c, "WRETRY_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+
+	// the batch still failing is dropped and fetched again
+	fs = run(2, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "WSKIP_{base}",
+        "write_retries": 1,
+        "on_write_error": "skip"
+        }`)
+	c.Assert(fs.writes, Equals, 3)
+	assertKlines(c, readBucket(c, "WSKIP_EOS/1Min/OHLCV"), client.klines["EOSBNB"])
+
+	// or stops the worker
+	fs = run(defaultWriteRetries+1, `{
+        "symbols": ["EOS"],
+        "query_start": "2018-08-01 00:00",
+        "query_end": "2018-08-01 02:00",
+        "bucket_name_template": "WABORT_{base}"
+        }`)
+	c.Assert(fs.writes, Equals, defaultWriteRetries+1)
+	last, err := lastStoredTime(fs.store, io.NewTimeBucketKey("WABORT_EOS/1Min/OHLCV"))
+	c.Assert(err, IsNil)
+	c.Assert(last.IsZero(), Equals, true)
+
+	_, err = NewBgWorker(getConfig(`{"symbols": ["EOS"], "on_write_error": "zero"}`))
+	c.Assert(err, NotNil)
+}
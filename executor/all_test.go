@@ -2,6 +2,8 @@ package executor
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/csv"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -9,6 +11,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
 	"testing"
 	"time"
 
@@ -16,6 +19,7 @@ import (
 
 	. "github.com/alpacahq/marketstore/catalog"
 	. "github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/plugins/trigger"
 	"github.com/alpacahq/marketstore/utils"
 	. "github.com/alpacahq/marketstore/utils/io"
 	. "github.com/alpacahq/marketstore/utils/test"
@@ -229,6 +233,108 @@ func (s *TestSuite) TestWriteVariable(c *C) {
 		}
 	}
 }
+
+func (s *TestSuite) TestCompactBucket(c *C) {
+	tbk := NewTimeBucketKey("TEST-COMPACT/1Min/TICK-BIDASK")
+	tf := utils.TimeframeFromString("1Min")
+	dsv := NewDataShapeVector([]string{"Bid", "Ask"}, []EnumElementType{FLOAT32, FLOAT32})
+	tbinfo := NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(s.Rootdir), "Test", int16(2016), dsv, VARIABLE)
+	c.Assert(ThisInstance.CatalogDir.AddTimeBucket(tbk, tbinfo), IsNil)
+	tbi, err := ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	c.Assert(err, IsNil)
+	writer, err := NewWriter(tbi, ThisInstance.TXNPipe, ThisInstance.CatalogDir)
+	c.Assert(err, IsNil)
+
+	row := struct {
+		Epoch    int64
+		Bid, Ask float32
+	}{0, 100, 200}
+	write := func(ts []time.Time, bid float32) {
+		for _, t := range ts {
+			row.Epoch, row.Bid = t.Unix(), bid
+			buffer, _ := Serialize([]byte{}, row)
+			writer.WriteRecords([]time.Time{t}, buffer)
+		}
+		s.WALFile.flushToWAL(ThisInstance.TXNPipe)
+	}
+	start := time.Date(2016, time.December, 31, 2, 59, 0, 0, time.UTC)
+	ts := []time.Time{start, start.Add(time.Second), start.Add(time.Minute)}
+	write(ts, 100)
+	// the first two rows are written again with new values
+	write(ts[:2], 101)
+
+	read := func() *ColumnSeries {
+		cs, err := readBucket(tbk)
+		c.Assert(err, IsNil)
+		return cs
+	}
+	c.Assert(read().Len(), Equals, 5)
+
+	removed, err := CompactBucket(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(removed, Equals, 2)
+	cs := read()
+	c.Assert(cs.Len(), Equals, 3)
+	c.Assert(cs.GetByName("Bid"), DeepEquals, []float32{101, 101, 100})
+	for i, t := range cs.GetTime() {
+		c.Assert(nearestSecond(t.Unix(), int32(t.Nanosecond())), Equals, ts[i].Unix())
+	}
+	c.Assert(ThisInstance.CatalogDir.GetSubDirWithItemName("TEST-COMPACT"+compactSuffix), IsNil)
+
+	// nothing is left to remove
+	removed, err = CompactBucket(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(removed, Equals, 0)
+	c.Assert(read().Len(), Equals, 3)
+}
+
+func (s *TestSuite) TestPromoteBucket(c *C) {
+	tf := utils.TimeframeFromString("1Min")
+	dsv := NewDataShapeVector([]string{"Open", "Close"}, []EnumElementType{FLOAT32, FLOAT32})
+	template := NewTimeBucketInfo(*tf, "", "Test", int16(2017), dsv, FIXED)
+	write := func(key string, start time.Time, n int, value float32) *TimeBucketKey {
+		epoch, values := []int64{}, []float32{}
+		for i := 0; i < n; i++ {
+			epoch = append(epoch, start.Add(time.Duration(i)*time.Minute).Unix())
+			values = append(values, value)
+		}
+		cs := NewColumnSeries()
+		cs.AddColumn("Epoch", epoch)
+		cs.AddColumn("Open", values)
+		cs.AddColumn("Close", values)
+		tbk := NewTimeBucketKey(key)
+		c.Assert(writeBucket(tbk, template, cs), IsNil)
+		return tbk
+	}
+	newYear := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	live := write("TEST-PROMOTE/1Min/OHLC", newYear, 3, 1)
+	// the staging bucket spans another year file
+	staging := write("TEST-PROMOTE_STAGING/1Min/OHLC", newYear.Add(-2*time.Minute), 5, 2)
+
+	c.Assert(PromoteBucket(staging, live), IsNil)
+	cs, err := readBucket(live)
+	c.Assert(err, IsNil)
+	c.Assert(cs.GetEpoch()[0], Equals, newYear.Add(-2*time.Minute).Unix())
+	c.Assert(cs.GetByName("Close"), DeepEquals, []float32{2, 2, 2, 2, 2})
+	c.Assert(ThisInstance.CatalogDir.GetSubDirWithItemName("TEST-PROMOTE_STAGING"), IsNil)
+	_, err = os.Stat(staging.GetPathToYearFiles(s.Rootdir))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = os.Stat(live.GetPathToYearFiles(s.Rootdir) + ".replaced")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// a new live bucket is created
+	staging = write("TEST-PROMOTE_STAGING/1Min/OHLC", newYear, 2, 3)
+	created := NewTimeBucketKey("TEST-PROMOTE-NEW/1Min/OHLC")
+	c.Assert(PromoteBucket(staging, created), IsNil)
+	cs, err = readBucket(created)
+	c.Assert(err, IsNil)
+	c.Assert(cs.GetByName("Close"), DeepEquals, []float32{3, 3})
+
+	staging = write("TEST-PROMOTE_STAGING/1Min/OHLC", newYear, 2, 4)
+	c.Assert(PromoteBucket(staging, NewTimeBucketKey("TEST-PROMOTE/5Min/OHLC")), NotNil)
+	c.Assert(PromoteBucket(staging, staging), NotNil)
+}
+
 func (s *TestSuite) TestFileRead(c *C) {
 	q := NewQuery(s.DataDirectory)
 	q.AddRestriction("Symbol", "NZDUSD")
@@ -485,6 +591,252 @@ func (s *TestSuite) TestLastN(c *C) {
 	}
 }
 
+func (s *TestSuite) TestMultiKeyLastRows(c *C) {
+	start := time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(2002, time.December, 31, 23, 59, 59, 0, time.UTC).Unix()
+	symbols := []string{"EURUSD", "NZDUSD", "USDJPY"}
+
+	// the last bar of each symbol read on its own
+	last := map[string]int64{}
+	for _, symbol := range symbols {
+		q := NewQuery(s.DataDirectory)
+		q.AddTargetKey(NewTimeBucketKey(symbol + "/1Min/OHLC"))
+		q.SetRange(start, end)
+		q.SetRowLimit(LAST, 1)
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		reader, err := NewReader(parsed)
+		c.Assert(err, IsNil)
+		csm, _, err := reader.Read()
+		c.Assert(err, IsNil)
+		epoch := csm[*NewTimeBucketKey(symbol + "/1Min/OHLC")].GetEpoch()
+		c.Assert(epoch, HasLen, 1)
+		last[symbol] = epoch[0]
+	}
+
+	check := func(parsed *ParseResult, err error) {
+		c.Assert(err, IsNil)
+		reader, err := NewReader(parsed)
+		c.Assert(err, IsNil)
+		csm, _, err := reader.Read()
+		c.Assert(err, IsNil)
+		c.Assert(csm, HasLen, len(symbols))
+		for _, symbol := range symbols {
+			cs := csm[*NewTimeBucketKey(symbol + "/1Min/OHLC")]
+			c.Assert(cs, NotNil)
+			c.Assert(cs.GetEpoch(), DeepEquals, []int64{last[symbol]})
+		}
+	}
+
+	// in one query, with the limit applied to each bucket
+	q := NewQuery(s.DataDirectory)
+	q.AddTargetKey(NewTimeBucketKey("EURUSD,NZDUSD,USDJPY/1Min/OHLC"))
+	q.SetRange(start, end)
+	q.SetRowLimit(LAST, 1)
+	check(q.Parse())
+
+	q = NewQuery(s.DataDirectory)
+	q.AddTargetKeys(
+		NewTimeBucketKey("EURUSD/1Min/OHLC"),
+		NewTimeBucketKey("NZDUSD/1Min/OHLC"),
+		NewTimeBucketKey("USDJPY/1Min/OHLC"),
+	)
+	q.SetRange(start, end)
+	q.SetRowLimit(LAST, 1)
+	check(q.Parse())
+}
+
+func (s *TestSuite) TestStreamChunks(c *C) {
+	query := func(limit int32) *ParseResult {
+		q := NewQuery(s.DataDirectory)
+		q.AddRestriction("Symbol", "NZDUSD")
+		q.AddRestriction("AttributeGroup", "OHLC")
+		q.AddRestriction("Timeframe", "1Min")
+		q.SetRange(
+			time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC).Unix(),
+			time.Date(2002, time.December, 31, 23, 59, 59, 0, time.UTC).Unix(),
+		)
+		if limit > 0 {
+			q.SetRowLimit(FIRST, limit)
+		}
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		return parsed
+	}
+
+	scanner, err := NewReader(query(0))
+	c.Assert(err, IsNil)
+	csm, _, err := scanner.Read()
+	c.Assert(err, IsNil)
+
+	// The streamed chunks add up to what Read returns
+	scanner, err = NewReader(query(0))
+	c.Assert(err, IsNil)
+	var epochs []int64
+	chunks := 0
+	err = scanner.StreamChunks(func(key TimeBucketKey, cs *ColumnSeries) error {
+		c.Assert(cs.Len() <= RecordsPerRead, Equals, true)
+		epochs = append(epochs, cs.GetEpoch()...)
+		chunks++
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(chunks > 1, Equals, true)
+	for _, cs := range csm {
+		c.Assert(epochs, DeepEquals, cs.GetEpoch())
+	}
+
+	// The row limit ends the stream
+	scanner, err = NewReader(query(RecordsPerRead + 10))
+	c.Assert(err, IsNil)
+	count := 0
+	err = scanner.StreamChunks(func(key TimeBucketKey, cs *ColumnSeries) error {
+		count += cs.Len()
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, RecordsPerRead+10)
+
+	// An error from the callback stops the stream
+	scanner, err = NewReader(query(0))
+	c.Assert(err, IsNil)
+	chunks = 0
+	err = scanner.StreamChunks(func(key TimeBucketKey, cs *ColumnSeries) error {
+		chunks++
+		return fmt.Errorf("stop")
+	})
+	c.Assert(err, ErrorMatches, "stop")
+	c.Assert(chunks, Equals, 1)
+
+	// Read gathers the chunks and keeps the time of the record before them
+	q := NewQuery(s.DataDirectory)
+	q.AddRestriction("Symbol", "NZDUSD")
+	q.AddRestriction("AttributeGroup", "OHLC")
+	q.AddRestriction("Timeframe", "1Min")
+	start := time.Date(2001, time.January, 15, 12, 0, 0, 0, time.UTC)
+	q.SetRange(start.Unix(), start.Add(3*RecordsPerRead*time.Minute).Unix())
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	scanner, err = NewReader(parsed)
+	c.Assert(err, IsNil)
+	csm, tPrevMap, err := scanner.Read()
+	c.Assert(err, IsNil)
+	for key, cs := range csm {
+		c.Assert(cs.Len(), Equals, 3*RecordsPerRead+1)
+		c.Assert(tPrevMap[key], Equals, start.Add(-time.Minute).Unix())
+	}
+}
+
+func (s *TestSuite) TestExportBucket(c *C) {
+	tbk := NewTimeBucketKey("NZDUSD/1Min/OHLC")
+	start := time.Date(2001, time.January, 15, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2001, time.January, 15, 12, 4, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	err := ExportBucket(tbk, start, end, "csv", &buf)
+	c.Assert(err, IsNil)
+	records, err := csv.NewReader(&buf).ReadAll()
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 6)
+	c.Assert(records[0], DeepEquals, []string{"Epoch", "Open", "High", "Low", "Close"})
+	c.Assert(records[1][0], Equals, "2001-01-15T12:00:00Z")
+	c.Assert(records[5][0], Equals, "2001-01-15T12:04:00Z")
+	for _, record := range records[1:] {
+		for _, value := range record[1:] {
+			_, err := strconv.ParseFloat(value, 32)
+			c.Assert(err, IsNil)
+		}
+	}
+
+	// the Parquet file has its footer between the magic numbers
+	buf.Reset()
+	err = ExportBucket(tbk, start, end, "parquet", &buf)
+	c.Assert(err, IsNil)
+	b := buf.Bytes()
+	c.Assert(string(b[:4]), Equals, "PAR1")
+	c.Assert(string(b[len(b)-4:]), Equals, "PAR1")
+	footer := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	c.Assert(footer < len(b)-12, Equals, true)
+	meta := b[len(b)-8-footer : len(b)-8]
+	for _, name := range []string{"Epoch", "Open", "High", "Low", "Close"} {
+		c.Assert(bytes.Contains(meta, []byte(name)), Equals, true, Commentf(name))
+	}
+
+	err = ExportBucket(tbk, start, end, "xlsx", &buf)
+	c.Assert(err, NotNil)
+}
+
+func (s *TestSuite) TestParquetWriter(c *C) {
+	var buf bytes.Buffer
+	pw := newParquetWriter(&buf)
+	names := []string{"Epoch", "Close"}
+	c.Assert(pw.writeRowGroup(names, []interface{}{parquetTimestamps{60000, 120000}, []float32{1.5, 2.5}}), IsNil)
+	// empty chunks add no row group
+	c.Assert(pw.writeRowGroup(names, []interface{}{parquetTimestamps{}, []float32{}}), IsNil)
+	c.Assert(pw.writeRowGroup(names, []interface{}{parquetTimestamps{180000}, []float32{3.5}}), IsNil)
+	c.Assert(pw.rowGroups, HasLen, 2)
+	c.Assert(pw.rowGroups[0].rows, Equals, int64(2))
+	// the first data page follows the magic number, its values last
+	c.Assert(pw.rowGroups[0].chunks[0].offset, Equals, int64(4))
+	b := buf.Bytes()
+	end := pw.rowGroups[0].chunks[0].offset + pw.rowGroups[0].chunks[0].size
+	c.Assert(binary.LittleEndian.Uint64(b[end-8:end]), Equals, uint64(120000))
+	c.Assert(pw.close(), IsNil)
+
+	// the columns must keep their types and lengths
+	c.Assert(pw.writeRowGroup(names, []interface{}{parquetTimestamps{1}, []float64{1}}), NotNil)
+	c.Assert(pw.writeRowGroup(names, []interface{}{parquetTimestamps{1}, []float32{1, 2}}), NotNil)
+	c.Assert(pw.writeRowGroup([]string{"Epoch"}, []interface{}{[]complex64{1}}), NotNil)
+}
+
+func (s *TestSuite) TestReplayWrites(c *C) {
+	tbk := NewTimeBucketKey("NZDUSD/1Min/OHLC")
+	start := time.Date(2001, time.January, 15, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2001, time.January, 15, 12, 4, 0, 0, time.UTC)
+
+	_, err := ReplayWrites(tbk, start, end)
+	c.Assert(err, NotNil)
+
+	t := &FakeTrigger{fireC: make(chan struct{}, 1)}
+	ThisInstance.TriggerMatchers = []*trigger.TriggerMatcher{trigger.NewMatcher(t, "NZDUSD/1Min/OHLC")}
+	defer func() { ThisInstance.TriggerMatchers = nil }()
+	replayed, err := ReplayWrites(tbk, start, end)
+	c.Assert(err, IsNil)
+	c.Assert(replayed, Equals, 5)
+	c.Assert(t.calledWith, HasLen, 1)
+	c.Assert(t.calledWith[0][0], Equals, "NZDUSD/1Min/OHLC/2001.bin")
+	records := t.calledWith[0][1].([]trigger.Record)
+	c.Assert(records, HasLen, 5)
+	c.Assert(records[0].Index(), Equals, EpochToIndex(start.Unix(), time.Minute))
+
+	_, err = ReplayWrites(tbk, end, start)
+	c.Assert(err, NotNil)
+}
+
+func (s *TestSuite) TestFirstTimestamp(c *C) {
+	tbk := NewTimeBucketKey("FIRSTTS/1Min/OHLCV")
+	first := time.Date(2001, time.March, 2, 10, 30, 0, 0, time.UTC)
+	// the later year is written first
+	for _, epochs := range [][]int64{
+		{first.AddDate(1, 0, 0).Unix(), first.AddDate(1, 0, 0).Add(time.Minute).Unix()},
+		{first.Add(time.Hour).Unix(), first.Unix()},
+	} {
+		cs := NewColumnSeries()
+		cs.AddColumn("Epoch", epochs)
+		cs.AddColumn("Open", []float32{1, 2})
+		csm := NewColumnSeriesMap()
+		csm.AddColumnSeries(*tbk, cs)
+		c.Assert(WriteCSM(csm, false), IsNil)
+	}
+
+	ts, err := FirstTimestamp(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(ts, Equals, first)
+	ts, err = LastTimestamp(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(ts, Equals, first.AddDate(1, 0, 0).Add(time.Minute))
+}
+
 func (s *TestSuite) TestAddSymbolThenWrite(c *C) {
 	d := ThisInstance.CatalogDir
 	dataItemKey := "TEST/1Min/OHLCV"
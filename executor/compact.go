@@ -0,0 +1,180 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// compactSuffix marks the Symbol of the bucket a compaction writes to before
+// it replaces the original
+const compactSuffix = "_COMPACTING"
+
+// CompactBucket rewrites the bucket keeping only the last row read for each
+// time, in time order, and returns the number of rows removed.  The rows are
+// written to a staging bucket first, whose year files replace the original
+// ones only once it reads back with the expected rows in order.  A bucket that has nothing to
+// remove is left untouched, so running it again is a no-op.  Writers of the
+// bucket must be stopped while it runs.
+func CompactBucket(tbk *io.TimeBucketKey) (removed int, err error) {
+	cDir := ThisInstance.CatalogDir
+	tbi, err := cDir.GetLatestTimeBucketInfoFromKey(tbk)
+	if err != nil {
+		return 0, err
+	}
+	staging := io.NewTimeBucketKey(tbk.GetItemKey())
+	staging.SetItemInCategory("Symbol", staging.GetItemInCategory("Symbol")+compactSuffix)
+	// left over by an interrupted compaction
+	if _, err := cDir.GetLatestTimeBucketInfoFromKey(staging); err == nil {
+		if err := cDir.RemoveTimeBucket(staging); err != nil {
+			return 0, err
+		}
+	}
+
+	cs, err := readBucket(tbk)
+	if err != nil {
+		return 0, err
+	}
+	compacted, removed := compactRows(cs)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := writeBucket(staging, tbi, compacted); err != nil {
+		return 0, err
+	}
+	written, err := readBucket(staging)
+	if err != nil {
+		return 0, err
+	}
+	if err := sameTimes(compacted, written); err != nil {
+		return 0, fmt.Errorf("compacted %s does not read back: %v", tbk.String(), err)
+	}
+
+	// swap the staging year files in one by one, each of them holds the
+	// same rows compacted, so the bucket reads the same at any point
+	root := cDir.GetPath()
+	files, err := filepath.Glob(filepath.Join(staging.GetPathToYearFiles(root), "*.bin"))
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		if err := os.Rename(file, filepath.Join(tbk.GetPathToYearFiles(root), filepath.Base(file))); err != nil {
+			return 0, err
+		}
+	}
+	return removed, cDir.RemoveTimeBucket(staging)
+}
+
+// readBucket reads all rows of the bucket
+func readBucket(tbk *io.TimeBucketKey) (*io.ColumnSeries, error) {
+	q := planner.NewQuery(ThisInstance.CatalogDir)
+	q.AddTargetKey(tbk)
+	q.SetRange(planner.MinEpoch, planner.MaxEpoch)
+	parsed, err := q.Parse()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewReader(parsed)
+	if err != nil {
+		return nil, err
+	}
+	csm, _, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	cs := csm[*tbk]
+	if cs == nil {
+		return nil, fmt.Errorf("no rows read from %s", tbk.String())
+	}
+	return cs, nil
+}
+
+// compactRows returns the last row of each time in cs in time order, along
+// with the number of rows dropped
+func compactRows(cs *io.ColumnSeries) (*io.ColumnSeries, int) {
+	times := cs.GetTime()
+	last := map[int64]int{}
+	for i, t := range times {
+		last[t.UnixNano()] = i
+	}
+	keep := make([]int, 0, len(last))
+	for _, i := range last {
+		keep = append(keep, i)
+	}
+	sort.Slice(keep, func(a, b int) bool { return times[keep[a]].Before(times[keep[b]]) })
+
+	ordered := true
+	for i := range keep {
+		ordered = ordered && keep[i] == i
+	}
+	if ordered && len(keep) == len(times) {
+		return cs, 0
+	}
+
+	out := io.NewColumnSeries()
+	for _, name := range cs.GetColumnNames() {
+		col := reflect.ValueOf(cs.GetByName(name))
+		slc := reflect.MakeSlice(col.Type(), 0, len(keep))
+		for _, i := range keep {
+			slc = reflect.Append(slc, col.Index(i))
+		}
+		out.AddColumn(name, slc.Interface())
+	}
+	return out, len(times) - len(keep)
+}
+
+// writeBucket writes cs to a new bucket with the layout of tbi
+func writeBucket(tbk *io.TimeBucketKey, tbi *io.TimeBucketInfo, cs *io.ColumnSeries) error {
+	cDir := ThisInstance.CatalogDir
+	times := cs.GetTime()
+	// the reader adds Nanoseconds to variable records, the writer takes
+	// them from the times instead
+	if cs.Exists("Nanoseconds") {
+		cs.Remove("Nanoseconds")
+	}
+	tf, err := tbk.GetTimeFrame()
+	if err != nil {
+		return err
+	}
+	info := io.NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(cDir.GetPath()),
+		tbi.GetDescription(), int16(times[0].Year()), tbi.GetDataShapes(), tbi.GetRecordType())
+	if err := cDir.AddTimeBucket(tbk, info); err != nil {
+		if !strings.Contains(err.Error(), "Can not overwrite file") && !strings.Contains(err.Error(), "file exists") {
+			return err
+		}
+	}
+	w, err := NewWriter(info, ThisInstance.TXNPipe, cDir)
+	if err != nil {
+		return err
+	}
+	w.WriteRecords(times, cs.ToRowSeries(*tbk).GetData())
+	ThisInstance.WALFile.RequestFlush()
+	return nil
+}
+
+// sameTimes checks that got holds the rows of want, in strictly increasing
+// time order.  Variable records are stored as interval ticks, so their times
+// read back to within a microsecond.
+func sameTimes(want, got *io.ColumnSeries) error {
+	wt, gt := want.GetTime(), got.GetTime()
+	if len(wt) != len(gt) {
+		return fmt.Errorf("%d rows instead of %d", len(gt), len(wt))
+	}
+	for i := range gt {
+		if d := gt[i].Sub(wt[i]); d < -time.Microsecond || d > time.Microsecond {
+			return fmt.Errorf("row %d is at %v instead of %v", i, gt[i], wt[i])
+		}
+		if i > 0 && !gt[i].After(gt[i-1]) {
+			return fmt.Errorf("row %d at %v is not after %v", i, gt[i], gt[i-1])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"encoding/csv"
+	"fmt"
+	goio "io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// ExportBucket writes the rows of the bucket between start and end inclusive
+// to w in the given format, streaming them so that buckets of any size can be
+// exported.  The formats are "csv", a header of the column names followed by
+// one line per row with Epoch rendered as RFC3339 in the instance timezone,
+// and "parquet", a row group per chunk read with Epoch as a timestamp in
+// milliseconds, see parquetWriter.
+func ExportBucket(tbk *io.TimeBucketKey, start, end time.Time, format string, w goio.Writer) error {
+	if format != "csv" && format != "parquet" {
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+
+	q := planner.NewQuery(ThisInstance.CatalogDir)
+	q.AddTargetKey(tbk)
+	q.SetRange(start.Unix(), end.Unix())
+	parsed, err := q.Parse()
+	if err != nil {
+		return err
+	}
+	reader, err := NewReader(parsed)
+	if err != nil {
+		return err
+	}
+	if format == "parquet" {
+		return exportParquet(reader, w)
+	}
+	return exportCSV(reader, w)
+}
+
+// exportCSV writes the rows read as CSV
+func exportCSV(r *reader, w goio.Writer) error {
+	cw := csv.NewWriter(w)
+	header := false
+	err := r.StreamChunks(func(key io.TimeBucketKey, cs *io.ColumnSeries) error {
+		names := cs.GetColumnNames()
+		if !header {
+			if err := cw.Write(names); err != nil {
+				return err
+			}
+			header = true
+		}
+		epoch := cs.GetEpoch()
+		row := make([]string, len(names))
+		for i := range epoch {
+			for j, name := range names {
+				if name == "Epoch" {
+					row[j] = time.Unix(epoch[i], 0).In(utils.InstanceConfig.Timezone).Format(time.RFC3339)
+					continue
+				}
+				row[j] = formatValue(reflect.ValueOf(cs.GetByName(name)).Index(i).Interface())
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportParquet writes the rows read as Parquet
+func exportParquet(r *reader, w goio.Writer) error {
+	pw := newParquetWriter(w)
+	err := r.StreamChunks(func(key io.TimeBucketKey, cs *io.ColumnSeries) error {
+		names := cs.GetColumnNames()
+		columns := make([]interface{}, len(names))
+		for i, name := range names {
+			if name == "Epoch" {
+				epoch := cs.GetEpoch()
+				millis := make(parquetTimestamps, len(epoch))
+				for j, e := range epoch {
+					millis[j] = e * 1000
+				}
+				columns[i] = millis
+				continue
+			}
+			columns[i] = cs.GetByName(name)
+		}
+		return pw.writeRowGroup(names, columns)
+	})
+	if err != nil {
+		return err
+	}
+	return pw.close()
+}
+
+// formatValue renders floats without exponents or trailing zeros
+func formatValue(v interface{}) string {
+	switch f := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(f), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
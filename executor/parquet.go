@@ -0,0 +1,439 @@
+package executor
+
+import (
+	"encoding/binary"
+	"fmt"
+	goio "io"
+	"math"
+)
+
+// parquetMagic starts and ends a Parquet file
+const parquetMagic = "PAR1"
+
+// Parquet physical types
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Parquet converted types, the logical types of the physical ones
+const (
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetUint8           = 11
+	parquetUint16          = 12
+	parquetUint32          = 13
+	parquetUint64          = 14
+	parquetInt8            = 15
+	parquetInt16           = 16
+)
+
+// Parquet encodings, page types and repetition types
+const (
+	parquetPlain    = 0
+	parquetRLE      = 3
+	parquetDataPage = 0
+	parquetRequired = 0
+)
+
+// parquetTimestamps is a column of milliseconds since the Unix epoch, written
+// as timestamps rather than integers
+type parquetTimestamps []int64
+
+// parquetColumn is a column of the schema of a Parquet file
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32 // -1 without one
+}
+
+// parquetChunk is the metadata of a column chunk of a row group
+type parquetChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+// parquetRowGroup is the metadata of a row group
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+	size   int64
+}
+
+// parquetWriter writes the rows given to writeRowGroup as a Parquet file,
+// one row group at a time so that the rows need not fit in memory.  All
+// columns are required and written as a single uncompressed data page of
+// PLAIN values per row group, which every Parquet reader supports.  The
+// footer is written by close.
+type parquetWriter struct {
+	w         goio.Writer
+	offset    int64
+	columns   []parquetColumn
+	rowGroups []parquetRowGroup
+}
+
+func newParquetWriter(w goio.Writer) *parquetWriter {
+	return &parquetWriter{w: w}
+}
+
+func (pw *parquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// writeRowGroup writes the columns as a row group, unless they are empty.
+// The first columns set the schema the later ones must have.
+func (pw *parquetWriter) writeRowGroup(names []string, columns []interface{}) error {
+	if pw.offset == 0 {
+		if err := pw.write([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+	schema := make([]parquetColumn, len(names))
+	values := make([][]byte, len(names))
+	rows := -1
+	for i, name := range names {
+		var n int
+		var err error
+		schema[i], values[i], n, err = encodeParquetColumn(name, columns[i])
+		if err != nil {
+			return err
+		}
+		if rows >= 0 && n != rows {
+			return fmt.Errorf("column %s has %d rows, not %d", name, n, rows)
+		}
+		rows = n
+	}
+	if pw.columns == nil {
+		pw.columns = schema
+	} else if len(schema) != len(pw.columns) {
+		return fmt.Errorf("row group has %d columns, not %d", len(schema), len(pw.columns))
+	} else {
+		for i := range schema {
+			if schema[i] != pw.columns[i] {
+				return fmt.Errorf("column %s does not match the schema of the first row group", schema[i].name)
+			}
+		}
+	}
+	if rows <= 0 {
+		return nil
+	}
+
+	rg := parquetRowGroup{rows: int64(rows)}
+	for _, data := range values {
+		var page thriftWriter
+		page.i32(1, parquetDataPage)
+		page.i32(2, int32(len(data)))
+		page.i32(3, int32(len(data)))
+		page.beginStruct(5)
+		page.i32(1, int32(rows))
+		page.i32(2, parquetPlain)
+		page.i32(3, parquetRLE)
+		page.i32(4, parquetRLE)
+		page.endStruct()
+		page.stop()
+
+		chunk := parquetChunk{
+			offset: pw.offset,
+			size:   int64(len(page.buf) + len(data)),
+			values: int64(rows),
+		}
+		if err := pw.write(page.buf); err != nil {
+			return err
+		}
+		if err := pw.write(data); err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, chunk)
+		rg.size += chunk.size
+	}
+	pw.rowGroups = append(pw.rowGroups, rg)
+	return nil
+}
+
+// close writes the footer
+func (pw *parquetWriter) close() error {
+	if pw.offset == 0 {
+		if err := pw.write([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+	var rows int64
+	for _, rg := range pw.rowGroups {
+		rows += rg.rows
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.beginList(2, thriftStruct, len(pw.columns)+1)
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.endElement()
+	for _, col := range pw.columns {
+		meta.i32(1, col.physicalType)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, col.name)
+		if col.convertedType >= 0 {
+			meta.i32(6, col.convertedType)
+		}
+		meta.endElement()
+	}
+	meta.endList()
+	meta.i64(3, rows)
+	meta.beginList(4, thriftStruct, len(pw.rowGroups))
+	for _, rg := range pw.rowGroups {
+		meta.beginList(1, thriftStruct, len(rg.chunks))
+		for i, chunk := range rg.chunks {
+			col := pw.columns[i]
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, col.physicalType)
+			meta.beginList(2, thriftI32, 2)
+			meta.varint(zigzag(parquetPlain))
+			meta.varint(zigzag(parquetRLE))
+			meta.endList()
+			meta.beginList(3, thriftBinary, 1)
+			meta.varint(uint64(len(col.name)))
+			meta.buf = append(meta.buf, col.name...)
+			meta.endList()
+			meta.i32(4, 0) // uncompressed
+			meta.i64(5, chunk.values)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endElement()
+		}
+		meta.endList()
+		meta.i64(2, rg.size)
+		meta.i64(3, rg.rows)
+		meta.endElement()
+	}
+	meta.endList()
+	meta.binary(6, "marketstore")
+	meta.stop()
+
+	if err := pw.write(meta.buf); err != nil {
+		return err
+	}
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], uint32(len(meta.buf)))
+	if err := pw.write(footer[:]); err != nil {
+		return err
+	}
+	return pw.write([]byte(parquetMagic))
+}
+
+// encodeParquetColumn returns the schema and the PLAIN encoded values of the
+// column, and its number of rows
+func encodeParquetColumn(name string, column interface{}) (parquetColumn, []byte, int, error) {
+	col := parquetColumn{name: name, convertedType: -1}
+	var data []byte
+	var n int
+	switch v := column.(type) {
+	case parquetTimestamps:
+		col.physicalType, col.convertedType = parquetInt64, parquetTimestampMillis
+		for _, x := range v {
+			data = appendUint64(data, uint64(x))
+		}
+		n = len(v)
+	case []int64:
+		col.physicalType = parquetInt64
+		for _, x := range v {
+			data = appendUint64(data, uint64(x))
+		}
+		n = len(v)
+	case []uint64:
+		col.physicalType, col.convertedType = parquetInt64, parquetUint64
+		for _, x := range v {
+			data = appendUint64(data, x)
+		}
+		n = len(v)
+	case []float64:
+		col.physicalType = parquetDouble
+		for _, x := range v {
+			data = appendUint64(data, math.Float64bits(x))
+		}
+		n = len(v)
+	case []float32:
+		col.physicalType = parquetFloat
+		for _, x := range v {
+			data = appendUint32(data, math.Float32bits(x))
+		}
+		n = len(v)
+	case []int32:
+		col.physicalType = parquetInt32
+		for _, x := range v {
+			data = appendUint32(data, uint32(x))
+		}
+		n = len(v)
+	case []uint32:
+		col.physicalType, col.convertedType = parquetInt32, parquetUint32
+		for _, x := range v {
+			data = appendUint32(data, x)
+		}
+		n = len(v)
+	case []int16:
+		col.physicalType, col.convertedType = parquetInt32, parquetInt16
+		for _, x := range v {
+			data = appendUint32(data, uint32(int32(x)))
+		}
+		n = len(v)
+	case []uint16:
+		col.physicalType, col.convertedType = parquetInt32, parquetUint16
+		for _, x := range v {
+			data = appendUint32(data, uint32(x))
+		}
+		n = len(v)
+	case []int8:
+		col.physicalType, col.convertedType = parquetInt32, parquetInt8
+		for _, x := range v {
+			data = appendUint32(data, uint32(int32(x)))
+		}
+		n = len(v)
+	case []uint8:
+		col.physicalType, col.convertedType = parquetInt32, parquetUint8
+		for _, x := range v {
+			data = appendUint32(data, uint32(x))
+		}
+		n = len(v)
+	case []bool:
+		// bit-packed, least significant bit first
+		col.physicalType = parquetBoolean
+		data = make([]byte, (len(v)+7)/8)
+		for i, x := range v {
+			if x {
+				data[i/8] |= 1 << uint(i%8)
+			}
+		}
+		n = len(v)
+	case []string:
+		col.physicalType, col.convertedType = parquetByteArray, parquetUTF8
+		for _, x := range v {
+			data = appendUint32(data, uint32(len(x)))
+			data = append(data, x...)
+		}
+		n = len(v)
+	default:
+		return col, nil, 0, fmt.Errorf("column %s of type %T cannot be written as Parquet", name, column)
+	}
+	return col, data, n, nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// Thrift compact protocol types of the fields written
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Parquet metadata structures in the Thrift compact
+// protocol.  Fields must be written in increasing id order within a struct.
+type thriftWriter struct {
+	buf []byte
+	// lastField is the id of the last field of the struct being written, and
+	// outer those of the enclosing structs
+	lastField int16
+	outer     []int16
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	for v >= 0x80 {
+		t.buf = append(t.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	t.buf = append(t.buf, byte(v))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.lastField; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.lastField = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+// beginStruct starts a struct field, ended by endStruct
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.outer = append(t.outer, t.lastField)
+	t.lastField = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.lastField = t.outer[len(t.outer)-1]
+	t.outer = t.outer[:len(t.outer)-1]
+}
+
+// beginList starts a list field of n elements, ended by endList.  The fields
+// of each struct element are followed by endElement, the values of other
+// elements are appended as they are.
+func (t *thriftWriter) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+	} else {
+		t.buf = append(t.buf, 0xf0|elem)
+		t.varint(uint64(n))
+	}
+	t.outer = append(t.outer, t.lastField)
+	t.lastField = 0
+}
+
+func (t *thriftWriter) endElement() {
+	t.stop()
+	t.lastField = 0
+}
+
+func (t *thriftWriter) endList() {
+	t.lastField = t.outer[len(t.outer)-1]
+	t.outer = t.outer[:len(t.outer)-1]
+}
+
+// stop ends the struct being written
+func (t *thriftWriter) stop() {
+	t.buf = append(t.buf, 0)
+}
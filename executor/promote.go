@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// promoteMu is held by the readers while they read the year files of the
+// buckets, and by PromoteBucket while it replaces a bucket, so that a read
+// sees either all the year files of the bucket or all those promoted
+var promoteMu sync.RWMutex
+
+// PromoteBucket replaces the bucket live with the bucket staging and removes
+// staging, e.g. once a backfill written to staging is verified.  The rows of
+// staging written so far are flushed first.  A live bucket that does not
+// exist is created with the layout of staging.  Both buckets must have the
+// same timeframe, and their writers must be stopped while it runs.
+func PromoteBucket(staging, live *io.TimeBucketKey) error {
+	cDir := ThisInstance.CatalogDir
+	tbi, err := cDir.GetLatestTimeBucketInfoFromKey(staging)
+	if err != nil {
+		return err
+	}
+	stagingTf, err := staging.GetTimeFrame()
+	if err != nil {
+		return err
+	}
+	liveTf, err := live.GetTimeFrame()
+	if err != nil {
+		return err
+	}
+	if stagingTf.Duration != liveTf.Duration {
+		return fmt.Errorf("cannot promote %s of another timeframe to %s", staging.String(), live.String())
+	}
+	ThisInstance.WALFile.RequestFlush()
+
+	promoteMu.Lock()
+	defer promoteMu.Unlock()
+	if _, err := cDir.GetLatestTimeBucketInfoFromKey(live); err != nil {
+		info := io.NewTimeBucketInfo(*liveTf, live.GetPathToYearFiles(cDir.GetPath()),
+			tbi.GetDescription(), tbi.Year, tbi.GetDataShapes(), tbi.GetRecordType())
+		if err := cDir.AddTimeBucket(live, info); err != nil {
+			return err
+		}
+	}
+	return cDir.ReplaceTimeBucket(staging, live)
+}
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/plugins/trigger"
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// ReplayWrites passes the rows of the bucket between start and end inclusive
+// to the triggers registered on it as if they had just been written, so that
+// the buckets a trigger added after the data maintains, such as aggregates,
+// can be built from history.  The rows are read in chunks and not written
+// again.  Each chunk is fired once per year file, and ReplayWrites returns
+// once the triggers have returned, with the number of rows replayed.  It is
+// meant to be run once from a tool, the range must be bounded and variable
+// length buckets are not supported.
+func ReplayWrites(tbk *io.TimeBucketKey, start, end time.Time) (replayed int, err error) {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0, fmt.Errorf("invalid replay range %v - %v", start, end)
+	}
+	tbi, err := ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	if err != nil {
+		return 0, err
+	}
+	if tbi.GetRecordType() == io.VARIABLE {
+		return 0, fmt.Errorf("cannot replay the variable length records of %s", tbk.String())
+	}
+	matchers := []*trigger.TriggerMatcher{}
+	for _, tmatcher := range ThisInstance.TriggerMatchers {
+		if tmatcher.Match(tbk.GetItemKey() + "/") {
+			matchers = append(matchers, tmatcher)
+		}
+	}
+	if len(matchers) == 0 {
+		return 0, fmt.Errorf("no trigger is registered on %s", tbk.String())
+	}
+
+	q := planner.NewQuery(ThisInstance.CatalogDir)
+	q.AddTargetKey(tbk)
+	q.SetRange(start.Unix(), end.Unix())
+	parsed, err := q.Parse()
+	if err != nil {
+		return 0, err
+	}
+	reader, err := NewReader(parsed)
+	if err != nil {
+		return 0, err
+	}
+	err = reader.StreamChunks(func(key io.TimeBucketKey, cs *io.ColumnSeries) error {
+		for _, year := range chunkYears(cs) {
+			rows := cs.ApplyTimeQual(func(epoch int64) bool {
+				return io.ToSystemTimezone(time.Unix(epoch, 0)).Year() == year
+			})
+			records, err := trigger.ColumnSeriesToRecords(key, *rows)
+			if err != nil {
+				return err
+			}
+			keyPath := filepath.Join(key.GetItemKey(), strconv.Itoa(year)+".bin")
+			for _, tmatcher := range matchers {
+				if tmatcher.Match(keyPath) {
+					triggerWg.Add(1)
+					fire(tmatcher.Trigger, keyPath, records)
+				}
+			}
+			replayed += len(records)
+		}
+		return nil
+	})
+	return replayed, err
+}
+
+// chunkYears returns the years of the year files the rows are stored in, in
+// time order
+func chunkYears(cs *io.ColumnSeries) []int {
+	years := []int{}
+	for _, epoch := range cs.GetEpoch() {
+		year := io.ToSystemTimezone(time.Unix(epoch, 0)).Year()
+		if len(years) == 0 || years[len(years)-1] != year {
+			years = append(years, year)
+		}
+	}
+	return years
+}
@@ -2,10 +2,12 @@ package executor
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"reflect"
 	"sort"
 	"time"
 
@@ -204,29 +206,133 @@ func NewReader(pr *planner.ParseResult) (r *reader, err error) {
 	return r, nil
 }
 
+// Read returns the whole result of each key, gathered from the chunks of
+// StreamChunks, along with the time of the record before each result
 func (r *reader) Read() (csm ColumnSeriesMap, tPrevMap map[TimeBucketKey]int64, err error) {
-	csm = NewColumnSeriesMap()
+	chunks := make(map[TimeBucketKey][]*ColumnSeries)
 	tPrevMap = make(map[TimeBucketKey]int64)
+	err = r.stream(func(key TimeBucketKey, cs *ColumnSeries, tPrev int64) error {
+		if _, ok := tPrevMap[key]; !ok {
+			tPrevMap[key] = tPrev
+		}
+		chunks[key] = append(chunks[key], cs)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	csm = NewColumnSeriesMap()
+	for key, list := range chunks {
+		csm[key] = concatChunks(list)
+	}
+	return csm, tPrevMap, nil
+}
+
+// concatChunks joins the chunks of a key in order into one ColumnSeries
+func concatChunks(chunks []*ColumnSeries) *ColumnSeries {
+	if len(chunks) == 1 {
+		return chunks[0]
+	}
+	cs := NewColumnSeries()
+	for _, name := range chunks[0].GetColumnNames() {
+		column := reflect.ValueOf(chunks[0].GetByName(name))
+		for _, chunk := range chunks[1:] {
+			column = reflect.AppendSlice(column, reflect.ValueOf(chunk.GetByName(name)))
+		}
+		cs.AddColumn(name, column.Interface())
+	}
+	cs.SetCandleAttributes(chunks[0].GetCandleAttributes())
+	return cs
+}
+
+// StreamChunks reads the result of each key in chunks of at most RecordsPerRead
+// rows and passes them to fn in ascending time order, so that long ranges can be
+// processed without holding the whole result in memory.  Results limited from the
+// LAST and variable length records are read at once and passed as a single chunk,
+// and a key without rows is passed as a single empty chunk.
+// Streaming stops at the first error returned by fn, which is returned.
+func (r *reader) StreamChunks(fn func(key TimeBucketKey, cs *ColumnSeries) error) error {
+	return r.stream(func(key TimeBucketKey, cs *ColumnSeries, tPrev int64) error {
+		return fn(key, cs)
+	})
+}
+
+// stream is StreamChunks passing fn the time of the record before each chunk
+func (r *reader) stream(fn func(key TimeBucketKey, cs *ColumnSeries, tPrev int64) error) error {
+	promoteMu.RLock()
+	defer promoteMu.RUnlock()
 	catMap := r.pr.GetCandleAttributes()
 	rtMap := r.pr.GetRowType()
 	dsMap := r.pr.GetDataShapes()
 	rlMap := r.pr.GetRowLen()
 	for key, iop := range r.IOPMap {
-		cat := catMap[key]
-		rt := rtMap[key]
-		rlen := rlMap[key]
-		buffer, tPrev, err := r.read(iop)
+		toColumnSeries := func(buffer []byte, tPrev int64) *ColumnSeries {
+			rs := NewRowSeries(key, tPrev, buffer, dsMap[key], rlMap[key], catMap[key], rtMap[key])
+			_, cs := rs.ToColumnSeries()
+			return cs
+		}
+		if iop.Limit.Direction == LAST || iop.RecordType == VARIABLE {
+			buffer, tPrev, err := r.read(iop)
+			if err != nil {
+				return err
+			}
+			if err = fn(key, toColumnSeries(buffer, tPrev), tPrev); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ex := newIoExec(iop)
+		readBuffer := r.readBuffer[:RecordsPerRead*iop.RecordLen]
+		tPrev, err := r.readTprev(ex, iop, readBuffer)
 		if err != nil {
-			return nil, nil, err
+			return err
+		}
+		recordsLeft := int(iop.Limit.Number)
+		flushed := false
+		var chunk []byte
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			if n := len(chunk) / int(iop.RecordLen); n >= recordsLeft {
+				chunk = chunk[:recordsLeft*int(iop.RecordLen)]
+			}
+			recordsLeft -= len(chunk) / int(iop.RecordLen)
+			cs := toColumnSeries(chunk, tPrev)
+			chunkPrev := tPrev
+			epoch := cs.GetEpoch()
+			tPrev = epoch[len(epoch)-1]
+			chunk = nil
+			flushed = true
+			if err := fn(key, cs, chunkPrev); err != nil {
+				return err
+			}
+			if recordsLeft == 0 {
+				return errStreamDone
+			}
+			return nil
+		}
+		for _, fp := range iop.FilePlan {
+			err := ex.streamForward(&chunk, fp, readBuffer, flush)
+			if err == errStreamDone {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		if !flushed {
+			if err := fn(key, toColumnSeries(nil, tPrev), tPrev); err != nil {
+				return err
+			}
 		}
-		tPrevMap[key] = tPrev
-		rs := NewRowSeries(key, tPrev, buffer, dsMap[key], rlen, cat, rt)
-		key, cs := rs.ToColumnSeries()
-		csm[key] = cs
 	}
-	return csm, tPrevMap, err
+	return nil
 }
 
+// errStreamDone stops a forward stream once the row limit is reached
+var errStreamDone = errors.New("stream done")
+
 /*
 bufferMeta stores an indirect index to variable length data records. It's used to read the actual data in a second pass.
 */
@@ -308,33 +414,8 @@ func (r *reader) read(iop *ioplan) (resultBuffer []byte, tPrev int64, err error)
 			}
 		}
 		if GatherTprev {
-			// Set the default tPrev to the base time of the oldest file in the PrevPlan minus one minute
-			prevCount := len(iop.PrevFilePlan)
-			if prevCount > 0 {
-				tPrev = time.Unix(iop.PrevFilePlan[prevCount-1].BaseTime, 0).Add(-time.Duration(time.Minute)).UTC().Unix()
-			}
-			// Scan backward until we find the first previous time
-			// Scan the file at the beginning of the date range unless the range started at the file begin
-			finished = false
-			for _, fp := range iop.PrevFilePlan {
-				var tPrevBuff []byte
-				tPrevBuff, finished, bytesRead, err := ex.readBackward(
-					tPrevBuff,
-					fp,
-					iop.RecordLen,
-					iop.RecordLen,
-					readBuffer,
-					r.fileBuffer)
-				if finished {
-					if bytesRead != 0 {
-						// We found a record, let's grab the tPrev time from it
-						tPrev = int64(binary.LittleEndian.Uint64(tPrevBuff[0:]))
-					}
-					break
-				} else if err != nil {
-					// We did not finish the scan and have an error, return the error
-					return nil, 0, err
-				}
+			if tPrev, err = r.readTprev(ex, iop, readBuffer); err != nil {
+				return nil, 0, err
 			}
 		}
 	} else if direction == LAST {
@@ -425,18 +506,53 @@ func (r *reader) read(iop *ioplan) (resultBuffer []byte, tPrev int64, err error)
 	return resultBuffer, tPrev, err
 }
 
+// readTprev returns the time of the record before the forward scan of iop,
+// or the base time of the oldest file of its PrevFilePlan minus one minute if
+// there is none
+func (r *reader) readTprev(ex *ioExec, iop *ioplan, readBuffer []byte) (tPrev int64, err error) {
+	// Set the default tPrev to the base time of the oldest file in the PrevPlan minus one minute
+	prevCount := len(iop.PrevFilePlan)
+	if prevCount > 0 {
+		tPrev = time.Unix(iop.PrevFilePlan[prevCount-1].BaseTime, 0).Add(-time.Duration(time.Minute)).UTC().Unix()
+	}
+	// Scan backward until we find the first previous time
+	// Scan the file at the beginning of the date range unless the range started at the file begin
+	for _, fp := range iop.PrevFilePlan {
+		var tPrevBuff []byte
+		tPrevBuff, finished, bytesRead, err := ex.readBackward(
+			tPrevBuff,
+			fp,
+			iop.RecordLen,
+			iop.RecordLen,
+			readBuffer,
+			r.fileBuffer)
+		if finished {
+			if bytesRead != 0 {
+				// We found a record, let's grab the tPrev time from it
+				tPrev = int64(binary.LittleEndian.Uint64(tPrevBuff[0:]))
+			}
+			break
+		} else if err != nil {
+			// We did not finish the scan and have an error, return the error
+			return 0, err
+		}
+	}
+	return tPrev, nil
+}
+
 type ioExec struct {
 	plan *ioplan
 }
 
 func (ex *ioExec) packingReader(packedBuffer *[]byte, f io.ReadSeeker, buffer []byte,
-	maxRead int64, fp *ioFilePlan) error {
+	maxRead int64, fp *ioFilePlan, flush func() error) error {
 	// Reads data from file f positioned after the header
 	// Will read records of size recordsize, decoding the index value to determine if this is a null or valid record
 	// The output is a buffer "packedBuffer" that contains only valid records
 	// The index value is converted to a UNIX Epoch timestamp based on the basetime and intervalsecs
 	// buffer is the temporary buffer to store read content from file, and indicates the maximum size to read
 	// maxRead limits the number of bytes to be read from the file
+	// flush, if not nil, is called after each read to consume packedBuffer
 	// Exit conditions:
 	// ==> leftbytes <= 0
 
@@ -488,6 +604,11 @@ func (ex *ioExec) packingReader(packedBuffer *[]byte, f io.ReadSeeker, buffer []
 				}
 			}
 		}
+		if flush != nil {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
 		if leftBytes <= 0 {
 			return nil
 		}
@@ -515,7 +636,7 @@ func (ex *ioExec) readForward(finalBuffer []byte, fp *ioFilePlan, recordLen, byt
 		return finalBuffer, false, err
 	}
 
-	if err = ex.packingReader(&finalBuffer, f, readBuffer, fp.Length, fp); err != nil {
+	if err = ex.packingReader(&finalBuffer, f, readBuffer, fp.Length, fp, nil); err != nil {
 		Log(ERROR, "Read: reading data from %s\n%s", filePath, err)
 		return finalBuffer, false, err
 
@@ -529,6 +650,23 @@ func (ex *ioExec) readForward(finalBuffer []byte, fp *ioFilePlan, recordLen, byt
 	return finalBuffer, false, nil
 }
 
+// streamForward scans the file like readForward, calling flush to consume
+// the records packed into chunk after each read
+func (ex *ioExec) streamForward(chunk *[]byte, fp *ioFilePlan, readBuffer []byte, flush func() error) error {
+	f, err := os.OpenFile(fp.FullPath, os.O_RDONLY, 0666)
+	if err != nil {
+		Log(ERROR, "Read: opening %s\n%s", fp.FullPath, err)
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(fp.Offset, os.SEEK_SET); err != nil {
+		Log(ERROR, "Read: seeking in %s\n%s", fp.FullPath, err)
+		return err
+	}
+	return ex.packingReader(chunk, f, readBuffer, fp.Length, fp, flush)
+}
+
 func (ex *ioExec) readBackward(finalBuffer []byte, fp *ioFilePlan,
 	recordLen, bytesToRead int32, readBuffer []byte, fileBuffer []byte) (
 	result []byte, finished bool, bytesRead int32, err error) {
@@ -563,7 +701,7 @@ func (ex *ioExec) readBackward(finalBuffer []byte, fp *ioFilePlan,
 		if err = ex.packingReader(
 			&fileBuffer,
 			f, readBuffer,
-			maxToRead, fp); err != nil {
+			maxToRead, fp, nil); err != nil {
 
 			Log(ERROR, "Read: reading data from %s\n%s", filePath, err)
 			return nil, false, 0, err
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"math"
+	"time"
+
+	"github.com/alpacahq/marketstore/planner"
+	"github.com/alpacahq/marketstore/utils/io"
+)
+
+// FirstTimestamp returns the time of the earliest row stored in the bucket,
+// or the zero time if it holds none
+func FirstTimestamp(tbk *io.TimeBucketKey) (time.Time, error) {
+	return boundaryTimestamp(tbk, io.FIRST)
+}
+
+// LastTimestamp returns the time of the latest row stored in the bucket, or
+// the zero time if it holds none
+func LastTimestamp(tbk *io.TimeBucketKey) (time.Time, error) {
+	return boundaryTimestamp(tbk, io.LAST)
+}
+
+func boundaryTimestamp(tbk *io.TimeBucketKey, direction io.DirectionEnum) (time.Time, error) {
+	q := planner.NewQuery(ThisInstance.CatalogDir)
+	q.AddTargetKey(tbk)
+	q.SetRange(0, math.MaxInt64)
+	q.SetRowLimit(direction, 1)
+	parsed, err := q.Parse()
+	if err != nil {
+		return time.Time{}, err
+	}
+	reader, err := NewReader(parsed)
+	if err != nil {
+		return time.Time{}, err
+	}
+	csm, _, err := reader.Read()
+	if err != nil {
+		return time.Time{}, err
+	}
+	cs := csm[*tbk]
+	if cs == nil || cs.Len() == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(cs.GetEpoch()[0], 0).UTC(), nil
+}
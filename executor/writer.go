@@ -217,7 +217,10 @@ func WriteBufferToFileIndirect(fp *os.File, buffer offsetIndexBuffer) (err error
 // isVariableLength is set to true if the record content is variable-length type. WriteCSM
 // also verifies the DataShapeVector of the incoming ColumnSeriesMap matches the on-disk
 // DataShapeVector defined by the file header. WriteCSM will create any files if they do
-// not already exist for the given ColumnSeriesMap based on its TimeBucketKey.
+// not already exist for the given ColumnSeriesMap based on its TimeBucketKey. Fixed-length
+// records are written at the offset of their Epoch, so a row with an existing Epoch
+// overwrites the stored one. Once flushed, the written records are passed to the
+// triggers registered on the bucket, one Fire per year file.
 func WriteCSM(csm io.ColumnSeriesMap, isVariableLength bool) (err error) {
 	cDir := ThisInstance.CatalogDir
 	for tbk, cs := range csm {
@@ -89,7 +89,10 @@ func (cl *Client) DoRPC(functionName string, args interface{}) (response interfa
 	case "Write":
 		result := &frontend.MultiWriteResponse{}
 		err = msgpack2.DecodeClientResponse(resp.Body, result)
-
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
 	default:
 		return nil, fmt.Errorf("unsupported RPC response")
 	}
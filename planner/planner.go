@@ -171,6 +171,8 @@ type query struct {
 	Limit       *RowLimit
 	DataDir     *Directory
 	TimeQuals   []TimeQualFunc
+	// targets are the keys of AddTargetKeys, the buckets parsed are limited to
+	targets []*TimeBucketKey
 }
 
 func NewQuery(d *Directory) *query {
@@ -192,6 +194,10 @@ func (q *query) SetRowLimit(direction DirectionEnum, rowLimit int) {
 	q.Limit.Direction = direction
 }
 
+// SetRange restricts the query to the epochs between start and end inclusive.
+// The rows of each bucket are read in ascending Epoch order, so a consumer
+// can poll for the rows since its last read with SetRange(lastSeen+1,
+// math.MaxInt64), which only reads the files from the year of lastSeen on.
 func (q *query) SetRange(start, end int64) {
 	q.Range = new(DateRange)
 	q.SetStart(start)
@@ -210,6 +216,10 @@ func (q *query) SetEnd(end int64) {
 	if q.Range == nil {
 		q.Range = NewDateRange()
 	}
+	// the years of later epochs overflow time.Time
+	if end > MaxEpoch {
+		end = MaxEpoch
+	}
 	q.Range.End = end
 	q.Range.EndYear = int16(ToSystemTimezone(time.Unix(end, 0)).Year())
 }
@@ -227,6 +237,54 @@ func (q *query) AddTargetKey(key *TimeBucketKey) {
 	}
 }
 
+// AddTargetKeys adds each of the keys with AddTargetKey, so that a single
+// query reads several buckets and the row limit applies to each of them.
+// Restrictions are per category, so Parse drops the buckets only matching a
+// combination of the keys, e.g. A/1H for A/1Min and B/1H.  The items shared
+// by the keys are restricted once, for their buckets to be parsed once.
+func (q *query) AddTargetKeys(keys ...*TimeBucketKey) {
+	for _, key := range keys {
+		for _, cat := range key.GetCategories() {
+			for _, item := range key.GetMultiItemInCategory(cat) {
+				if !contains(q.Restriction.getItemList(cat), item) {
+					q.Restriction.AddRestriction(cat, item)
+				}
+			}
+		}
+		q.targets = append(q.targets, key)
+	}
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// isTarget returns whether key matches one of the keys of AddTargetKeys in
+// each of its categories, or true without any
+func (q *query) isTarget(key *TimeBucketKey) bool {
+	if len(q.targets) == 0 {
+		return true
+	}
+	for _, target := range q.targets {
+		matches := true
+		for _, cat := range target.GetCategories() {
+			if !contains(target.GetMultiItemInCategory(cat), key.GetItemInCategory(cat)) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
 func (q *query) AddTimeQual(timeQual TimeQualFunc) {
 	q.TimeQuals = append(q.TimeQuals, timeQual)
 }
@@ -291,6 +349,13 @@ func (q *query) Parse() (pr *ParseResult, err error) {
 		Recurse the directory to produce the QualifiedFiles set
 	*/
 	getFileList(q.DataDir, &pr.QualifiedFiles, "", "")
+	targeted := pr.QualifiedFiles[:0]
+	for _, qf := range pr.QualifiedFiles {
+		if q.isTarget(&qf.Key) {
+			targeted = append(targeted, qf)
+		}
+	}
+	pr.QualifiedFiles = targeted
 	if len(pr.QualifiedFiles) == 0 {
 		return pr, fmt.Errorf("No files returned from query parse")
 	}
@@ -1,12 +1,16 @@
 package planner
 
 import (
+	"math"
+	"path/filepath"
 	"testing"
 	"time"
 
 	. "gopkg.in/check.v1"
 
 	. "github.com/alpacahq/marketstore/catalog"
+	"github.com/alpacahq/marketstore/utils"
+	"github.com/alpacahq/marketstore/utils/io"
 	. "github.com/alpacahq/marketstore/utils/test"
 )
 
@@ -60,3 +64,50 @@ func (s *TestSuite) TestQuery(c *C) {
 	qfs := pr.QualifiedFiles
 	c.Assert(len(qfs), Equals, 54)
 }
+
+func (s *TestSuite) TestSince(c *C) {
+	lastSeen := time.Date(2002, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+	q := NewQuery(s.DataDirectory)
+	q.AddRestriction("AttributeGroup", "OHLC")
+	q.SetRange(lastSeen+1, math.MaxInt64)
+	c.Assert(q.Range.End, Equals, MaxEpoch)
+	pr, err := q.Parse()
+	c.Assert(err, IsNil)
+	c.Assert(pr.Range.Start, Equals, lastSeen+1)
+	c.Assert(pr.Range.StartYear, Equals, int16(2002))
+	// no file is past the end year
+	for _, qf := range pr.QualifiedFiles {
+		c.Assert(qf.File.Year <= pr.Range.EndYear, Equals, true)
+	}
+}
+
+func (s *TestSuite) TestAddTargetKeys(c *C) {
+	rootDir := c.MkDir()
+	d := NewDirectory(rootDir)
+	dsv := io.NewDataShapeVector([]string{"Bid", "Ask"}, []io.EnumElementType{io.FLOAT32, io.FLOAT32})
+	for _, key := range []string{"A/1Min/OHLC", "A/1Min/TICKS", "B/1Min/OHLC", "B/1Min/TICKS"} {
+		tbk := io.NewTimeBucketKey(key)
+		tbinfo := io.NewTimeBucketInfo(*utils.TimeframeFromString("1Min"), filepath.Join(rootDir, key), "Test item", 2016,
+			dsv, io.FIXED)
+		c.Assert(d.AddTimeBucket(tbk, tbinfo), IsNil)
+	}
+	d = NewDirectory(rootDir)
+
+	// A/1Min/TICKS and B/1Min/OHLC match the restrictions but none of the keys
+	q := NewQuery(d)
+	q.AddTargetKeys(io.NewTimeBucketKey("A/1Min/OHLC"), io.NewTimeBucketKey("B/1Min/TICKS"))
+	pr, err := q.Parse()
+	c.Assert(err, IsNil)
+	keys := []string{}
+	for _, qf := range pr.QualifiedFiles {
+		keys = append(keys, qf.Key.String())
+	}
+	c.Assert(keys, DeepEquals, []string{"A/1Min/OHLC:Symbol/Timeframe/AttributeGroup", "B/1Min/TICKS:Symbol/Timeframe/AttributeGroup"})
+
+	// multiple items of a key still match each of them
+	q = NewQuery(d)
+	q.AddTargetKeys(io.NewTimeBucketKey("A,B/1Min/OHLC"))
+	pr, err = q.Parse()
+	c.Assert(err, IsNil)
+	c.Assert(pr.QualifiedFiles, HasLen, 2)
+}
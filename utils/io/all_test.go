@@ -240,6 +240,55 @@ func (s *TestSuite) TestColumnCoercion(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+func (s *TestSuite) TestAddColumnChecked(c *C) {
+	cs := NewColumnSeries()
+	c.Assert(cs.AddColumnChecked("Epoch", []int64{1, 2, 3}), IsNil)
+	c.Assert(cs.AddColumnChecked("Count", []uint64{1, 2, 3}), IsNil)
+	c.Assert(cs.AddColumnChecked("Epoch", []int64{4, 5, 6}), NotNil)
+	c.Assert(cs.AddColumnChecked("Open", nil), NotNil)
+	c.Assert(cs.AddColumnChecked("Open", []float64(nil)), NotNil)
+	c.Assert(cs.AddColumnChecked("Open", []float64{}), NotNil)
+	c.Assert(cs.AddColumnChecked("Open", 1.5), NotNil)
+	c.Assert(cs.AddColumnChecked("Open", []string{"a", "b", "c"}), NotNil)
+	c.Assert(cs.AddColumnChecked("Open", []float64{1, 2}), NotNil)
+	c.Assert(cs.GetColumnNames(), DeepEquals, []string{"Epoch", "Count"})
+}
+
+func (s *TestSuite) TestDiff(c *C) {
+	left := NewColumnSeries()
+	left.AddColumn("Epoch", []int64{1, 2, 3})
+	left.AddColumn("Close", []float64{1.5, 2, 3})
+	left.AddColumn("Volume", []int32{10, 20, 30})
+	right := NewColumnSeries()
+	right.AddColumn("Epoch", []int64{4, 3, 2})
+	right.AddColumn("Close", []float64{4, 3, 2.25})
+	right.AddColumn("Volume", []int32{40, 35, 20})
+
+	diffs, err := left.Diff(right)
+	c.Assert(err, IsNil)
+	c.Assert(diffs, DeepEquals, []EpochDiff{
+		{Epoch: 1, OnlyLeft: true},
+		{Epoch: 2, Fields: []FieldDiff{{Name: "Close", Left: 2, Right: 2.25}}},
+		{Epoch: 3, Fields: []FieldDiff{{Name: "Volume", Left: 30, Right: 35}}},
+		{Epoch: 4, OnlyRight: true},
+	})
+	c.Assert(diffs[1].Fields[0].Delta(), Equals, 0.25)
+
+	diffs, err = left.Diff(left)
+	c.Assert(err, IsNil)
+	c.Assert(diffs, HasLen, 0)
+
+	other := NewColumnSeries()
+	other.AddColumn("Epoch", []int64{1, 2, 3})
+	other.AddColumn("Close", []float32{1.5, 2, 3})
+	other.AddColumn("Volume", []int32{10, 20, 30})
+	_, err = left.Diff(other)
+	c.Assert(err, NotNil)
+	other.Remove("Close")
+	_, err = left.Diff(other)
+	c.Assert(err, NotNil)
+}
+
 func makeTestCS() *ColumnSeries {
 	col1 := []float32{1, 2, 3}
 	col2 := []float64{1, 2, 3}
@@ -561,3 +610,51 @@ func (s *TestSuite) TestApplyTimeQual(c *C) {
 
 	c.Assert(cs.ApplyTimeQual(tq).Len(), Equals, 0)
 }
+
+func (s *TestSuite) TestGetTimeIn(c *C) {
+	cs := makeTestCS()
+	est := time.FixedZone("EST", -5*60*60)
+
+	ts := cs.GetTimeIn(est)
+	c.Assert(ts, HasLen, 3)
+	for i, t := range ts {
+		c.Assert(t.Location(), Equals, est)
+		c.Assert(t.Unix(), Equals, cs.GetEpoch()[i])
+	}
+	c.Assert(cs.GetTime()[0].Location(), Equals, utils.InstanceConfig.Timezone)
+
+	// nanoseconds of variable length records are kept
+	cs.AddColumn("Nanoseconds", []int32{0, 500, 999999999})
+	c.Assert(cs.GetTimeIn(time.UTC)[2], Equals, time.Unix(3, 999999999).UTC())
+
+	// an empty series returns an empty slice
+	c.Assert(NewColumnSeries().GetTimeIn(est), HasLen, 0)
+	c.Assert(NewColumnSeries().GetTimeIn(est), NotNil)
+}
+
+func (s *TestSuite) TestEpochStats(c *C) {
+	first, last, count := makeTestCS().EpochStats()
+	c.Assert([]int64{first, last}, DeepEquals, []int64{1, 3})
+	c.Assert(count, Equals, 3)
+
+	cs := NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{42})
+	first, last, count = cs.EpochStats()
+	c.Assert([]int64{first, last}, DeepEquals, []int64{42, 42})
+	c.Assert(count, Equals, 1)
+
+	// the bounds do not depend on the order of the rows
+	cs = NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{5, 2, 9, 7})
+	first, last, count = cs.EpochStats()
+	c.Assert([]int64{first, last}, DeepEquals, []int64{2, 9})
+	c.Assert(count, Equals, 4)
+
+	empty := NewColumnSeries()
+	empty.AddColumn("Epoch", []int64{})
+	for _, cs := range []*ColumnSeries{NewColumnSeries(), empty} {
+		first, last, count = cs.EpochStats()
+		c.Assert([]int64{first, last}, DeepEquals, []int64{0, 0})
+		c.Assert(count, Equals, 0)
+	}
+}
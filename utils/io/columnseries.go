@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/alpacahq/marketstore/utils"
 )
 
 //go:generate ./generateMethods.sh generatedMethods.go
@@ -64,18 +66,21 @@ func (cs *ColumnSeries) Len() int {
 }
 
 func (cs *ColumnSeries) GetTime() []time.Time {
-	ep := cs.GetColumn("Epoch").([]int64)
+	return cs.GetTimeIn(utils.InstanceConfig.Timezone)
+}
+
+// GetTimeIn returns the Epoch column, with the Nanoseconds column if present,
+// as times in loc.  A series without an Epoch column returns an empty slice.
+func (cs *ColumnSeries) GetTimeIn(loc *time.Location) []time.Time {
+	ep, _ := cs.GetColumn("Epoch").([]int64)
 	ts := make([]time.Time, len(ep))
-	nsi := cs.GetColumn("Nanoseconds")
-	if nsi == nil {
-		for i, secs := range ep {
-			ts[i] = ToSystemTimezone(time.Unix(secs, 0))
-		}
-	} else {
-		ns := nsi.([]int32)
-		for i, secs := range ep {
-			ts[i] = ToSystemTimezone(time.Unix(secs, int64(ns[i])))
+	ns, _ := cs.GetColumn("Nanoseconds").([]int32)
+	for i, secs := range ep {
+		var nsecs int64
+		if ns != nil {
+			nsecs = int64(ns[i])
 		}
+		ts[i] = time.Unix(secs, nsecs).In(loc)
 	}
 	return ts
 }
@@ -110,6 +115,36 @@ func (cs *ColumnSeries) AddColumn(name string, columnData interface{}) (outname
 	cs.columns[name] = columnData
 	return name
 }
+
+// AddColumnChecked adds a column like AddColumn, but returns an error instead
+// of renaming a duplicate name.  It also rejects data that is not a non-empty
+// slice of a type the storage holds, and a length other than that of the
+// columns already added.
+func (cs *ColumnSeries) AddColumnChecked(name string, columnData interface{}) error {
+	if _, ok := cs.columns[name]; ok {
+		return fmt.Errorf("column %s already exists", name)
+	}
+	value := reflect.ValueOf(columnData)
+	if value.Kind() != reflect.Slice {
+		return fmt.Errorf("column %s is not a slice: %T", name, columnData)
+	}
+	if value.Len() == 0 {
+		return fmt.Errorf("column %s is empty", name)
+	}
+	supported := false
+	for _, el := range attributeMap {
+		supported = supported || (el.size > 0 && el.typ == value.Type().Elem().Kind())
+	}
+	if !supported {
+		return fmt.Errorf("column %s has an unsupported type: %T", name, columnData)
+	}
+	if !cs.IsEmpty() && value.Len() != cs.Len() {
+		return fmt.Errorf("column %s has %d rows instead of %d", name, value.Len(), cs.Len())
+	}
+	cs.AddColumn(name, columnData)
+	return nil
+}
+
 func (cs *ColumnSeries) IsEmpty() bool {
 	return len(cs.orderedNames) == 0
 }
@@ -227,6 +262,25 @@ func (cs *ColumnSeries) GetEpoch() []int64 {
 	}
 }
 
+// EpochStats returns the earliest and latest Epoch of the series and its
+// number of rows, all zero for an empty series or one without Epoch
+func (cs *ColumnSeries) EpochStats() (first, last int64, count int) {
+	epoch := cs.GetEpoch()
+	if len(epoch) == 0 {
+		return 0, 0, 0
+	}
+	first, last = epoch[0], epoch[0]
+	for _, e := range epoch[1:] {
+		if e < first {
+			first = e
+		}
+		if e > last {
+			last = e
+		}
+	}
+	return first, last, len(epoch)
+}
+
 func (cs *ColumnSeries) ToRowSeries(itemKey TimeBucketKey) (rs *RowSeries) {
 	dsv := cs.GetDataShapes()
 	data, recordLen := SerializeColumnsToRows(cs, dsv, true)
@@ -325,6 +379,106 @@ func max(x, y int) int {
 	return y
 }
 
+// FieldDiff is a value of a column differing between two series
+type FieldDiff struct {
+	Name        string
+	Left, Right float64
+}
+
+// Delta returns by how much the right value differs from the left one
+func (d FieldDiff) Delta() float64 {
+	return d.Right - d.Left
+}
+
+// EpochDiff is a row differing between two series, see Diff
+type EpochDiff struct {
+	Epoch int64
+	// OnlyLeft and OnlyRight tell that the other series has no row at Epoch,
+	// Fields is empty then
+	OnlyLeft, OnlyRight bool
+	// Fields are the values that differ, in the order of the columns
+	Fields []FieldDiff
+}
+
+// Diff aligns the rows of cs, the left series, and other, the right one, by
+// Epoch and returns the rows that differ, ordered by Epoch: those of the
+// epochs present in one series only, and the values of the others that are
+// not equal.  The series must have the same columns, of numeric or bool
+// types, with a row per Epoch.
+func (cs *ColumnSeries) Diff(other *ColumnSeries) ([]EpochDiff, error) {
+	if !cs.Exists("Epoch") || !other.Exists("Epoch") {
+		return nil, fmt.Errorf("cannot diff series without Epoch")
+	}
+	if len(cs.orderedNames) != len(other.orderedNames) {
+		return nil, fmt.Errorf("cannot diff columns %v with %v", cs.orderedNames, other.orderedNames)
+	}
+	for _, name := range cs.orderedNames {
+		l, r := reflect.ValueOf(cs.columns[name]), reflect.ValueOf(other.GetByName(name))
+		if !r.IsValid() || l.Type() != r.Type() {
+			return nil, fmt.Errorf("cannot diff columns %v with %v", cs.GetDataShapes(), other.GetDataShapes())
+		}
+		if _, ok := numericAt(l, 0); l.Len() > 0 && !ok {
+			return nil, fmt.Errorf("cannot diff column %s of %T", name, cs.columns[name])
+		}
+	}
+
+	rightRows := map[int64]int{}
+	for j, e := range other.GetEpoch() {
+		rightRows[e] = j
+	}
+	diffs := []EpochDiff{}
+	seen := map[int64]bool{}
+	for i, e := range cs.GetEpoch() {
+		seen[e] = true
+		j, ok := rightRows[e]
+		if !ok {
+			diffs = append(diffs, EpochDiff{Epoch: e, OnlyLeft: true})
+			continue
+		}
+		d := EpochDiff{Epoch: e}
+		for _, name := range cs.orderedNames {
+			if name == "Epoch" {
+				continue
+			}
+			left, _ := numericAt(reflect.ValueOf(cs.columns[name]), i)
+			right, _ := numericAt(reflect.ValueOf(other.GetByName(name)), j)
+			if left != right {
+				d.Fields = append(d.Fields, FieldDiff{Name: name, Left: left, Right: right})
+			}
+		}
+		if len(d.Fields) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	for _, e := range other.GetEpoch() {
+		if !seen[e] {
+			diffs = append(diffs, EpochDiff{Epoch: e, OnlyRight: true})
+		}
+	}
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Epoch < diffs[j].Epoch })
+	return diffs, nil
+}
+
+// numericAt returns the i-th element of the column as a float64, false if
+// its type is not numeric or bool
+func numericAt(column reflect.Value, i int) (float64, bool) {
+	v := column.Index(i)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
 // ColumnSeriesUnion takes to column series and creates a union
 // and returns another column series. The values in the union
 // are unique, and right values overwrite left values in when
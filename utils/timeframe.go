@@ -12,6 +12,11 @@ const Day = 24 * time.Hour
 const Week = 7 * Day
 const Year = 365 * Day
 
+// Month is the duration of a month timeframe, that of the shortest month so
+// that the first of each month has its own index in a year.  Months are
+// floored on the calendar, see TruncateToTimeframe.
+const Month = 28 * Day
+
 var timeframeDefs = []Timeframe{
 	{"S", time.Second},
 	{"Sec", time.Second},
@@ -21,6 +26,8 @@ var timeframeDefs = []Timeframe{
 	{"D", Day},
 	{"W", Week},
 	{"Y", Year},
+	// last, as Min contains it
+	{"M", Month},
 }
 
 var Timeframes = []*Timeframe{
@@ -95,6 +102,37 @@ func TimeframeFromDuration(tf time.Duration) *Timeframe {
 	return nil
 }
 
+// TruncateToTimeframe floors t to the start of the timeframe interval it
+// belongs to.  Intervals are counted from the Unix epoch on the wall clock of
+// t's location, so that 1D starts at local midnight, and the start is
+// returned in that location.  An interval starting in a skipped DST hour
+// starts at the end of the gap instead.  Month intervals start on the first
+// of the month, counted from January.
+func TruncateToTimeframe(t time.Time, tf *Timeframe) time.Time {
+	secs := int64(tf.Duration / time.Second)
+	if secs <= 0 {
+		return t
+	}
+	if strings.HasSuffix(tf.String, "M") {
+		months := int(tf.Duration / Month)
+		m := (int(t.Month()) - 1) / months * months
+		return time.Date(t.Year(), time.January+time.Month(m), 1, 0, 0, 0, 0, t.Location())
+	}
+	_, offset := t.Zone()
+	wall := t.Unix() + int64(offset)
+	rem := wall % secs
+	if rem < 0 {
+		rem += secs
+	}
+	wall -= rem
+	start := time.Unix(wall-int64(offset), 0).In(t.Location())
+	// the interval started before a DST transition
+	if _, o := start.Zone(); o != offset {
+		start = time.Unix(wall-int64(o), 0).In(t.Location())
+	}
+	return start
+}
+
 type CandleDuration struct {
 	String     string
 	duration   time.Duration
@@ -40,6 +40,54 @@ func (s *UtilsTestSuite) TestTimeframeFromString(c *C) {
 
 	tf = TimeframeFromString("0H")
 	c.Assert(tf, IsNil)
+
+	tf = TimeframeFromString("1M")
+	c.Assert(tf.String, Equals, "1M")
+	c.Assert(tf.Duration, Equals, Month)
+
+	tf = TimeframeFromString("5Min")
+	c.Assert(tf.Duration, Equals, 5*time.Minute)
+}
+
+func (s *UtilsTestSuite) TestTruncateToTimeframe(c *C) {
+	val := time.Date(2017, 9, 10, 13, 47, 12, 500, time.UTC)
+	for _, t := range []struct {
+		timeframe string
+		expected  time.Time
+	}{
+		{"1Min", time.Date(2017, 9, 10, 13, 47, 0, 0, time.UTC)},
+		{"30Min", time.Date(2017, 9, 10, 13, 30, 0, 0, time.UTC)},
+		{"2H", time.Date(2017, 9, 10, 12, 0, 0, 0, time.UTC)},
+		{"1D", time.Date(2017, 9, 10, 0, 0, 0, 0, time.UTC)},
+		// days counted from 1970-01-01
+		{"3D", time.Date(2017, 9, 9, 0, 0, 0, 0, time.UTC)},
+		// months on the calendar
+		{"1M", time.Date(2017, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"3M", time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		c.Check(TruncateToTimeframe(val, TimeframeFromString(t.timeframe)), Equals, t.expected, Commentf(t.timeframe))
+	}
+	// on a boundary and before the epoch
+	c.Assert(TruncateToTimeframe(val.Truncate(time.Hour), TimeframeFromString("1H")), Equals, val.Truncate(time.Hour))
+	c.Assert(TruncateToTimeframe(time.Date(1969, 12, 31, 23, 59, 30, 0, time.UTC), TimeframeFromString("1Min")),
+		Equals, time.Date(1969, 12, 31, 23, 59, 0, 0, time.UTC))
+
+	ny, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	// local midnight, in EST on the day clocks move to EDT
+	val = time.Date(2018, 3, 11, 12, 0, 0, 0, ny)
+	day := TruncateToTimeframe(val, TimeframeFromString("1D"))
+	c.Assert(day.Equal(time.Date(2018, 3, 11, 0, 0, 0, 0, ny)), Equals, true)
+	c.Assert(day.Location(), Equals, ny)
+	// 02:00 is skipped, so the 2H interval starts at 03:00 EDT
+	val = time.Date(2018, 3, 11, 3, 30, 0, 0, ny)
+	c.Assert(TruncateToTimeframe(val, TimeframeFromString("2H")).Equal(time.Date(2018, 3, 11, 3, 0, 0, 0, ny)), Equals, true)
+	c.Assert(TruncateToTimeframe(val, TimeframeFromString("1H")).Equal(time.Date(2018, 3, 11, 3, 0, 0, 0, ny)), Equals, true)
+	// 01:30 happens twice when clocks move back to EST
+	edt := time.Date(2018, 11, 4, 5, 30, 0, 0, time.UTC).In(ny)
+	est := edt.Add(time.Hour)
+	c.Assert(TruncateToTimeframe(edt, TimeframeFromString("1H")).Equal(edt.Add(-30*time.Minute)), Equals, true)
+	c.Assert(TruncateToTimeframe(est, TimeframeFromString("1H")).Equal(est.Add(-30*time.Minute)), Equals, true)
 }
 
 func (s *UtilsTestSuite) TestCandleDuration(c *C) {